@@ -6,17 +6,23 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"lvlchess/config"
 	"lvlchess/internal/db"
 	"lvlchess/internal/telegram"
 	"lvlchess/internal/utils"
+	"lvlchess/internal/web"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -31,19 +37,30 @@ type checkInitDataResponse struct {
 Main entry point for lvlChess:
 1) Loads configuration from environment (config.LoadConfig).
 2) Initializes logger (utils.InitLogger).
-3) Initializes DB (db.InitDB).
+3) Initializes DB (db.InitDB), applying the pending migrations; "bot migrate ..." only manages the schema.
 4) Creates a Telegram Bot API instance using BOT_TOKEN from config.
 5) Registers telegram.NewHandler (the main callback structure).
-6) Listens for updates in a loop.
+6) Listens for updates in a loop (long polling or webhook, see UPDATES_MODE).
+7) Shuts down gracefully on SIGINT/SIGTERM.
 */
 func main() {
+	// "migrate up|down [N]|status" manages the database schema and exits: it only needs the PG_* settings.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := config.LoadPGConfig(); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		utils.InitLogger()
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	// 1) Load environment-based configuration
 	config.LoadConfig()
 
 	// 2) Initialize a global logger (Zap in production mode)
 	utils.InitLogger() // e.g., logs to stdout, can also set different encoders
 
-	// 3) Initialize a PostgreSQL connection pool + basic schema
+	// 3) Initialize a PostgreSQL connection pool and apply the pending migrations
 	db.InitDB()
 
 	// 4) Build the Telegram bot with the token from config
@@ -61,32 +78,216 @@ func main() {
 	bot.Debug = true
 	utils.Logger.Info(fmt.Sprintf("Authenticated as bot: %s", bot.Self.UserName))
 
-	// Start HTTP‑server for WebApp
-	go func() {
-		// Раздаём React‑билд по запросам к /telegram-game/
-		fs := http.FileServer(http.Dir("frontend/build"))
-		http.Handle("/telegram-game/", http.StripPrefix("/telegram-game/", fs))
+	// In webhook mode Telegram posts the updates to our HTTP server.
+	var webhook *telegram.WebhookReceiver
+	webhookSecrets := telegram.NewWebhookSecrets()
+	if config.Cfg.UpdatesMode == config.UpdatesModeWebhook {
+		webhook = telegram.NewWebhookReceiver(webhookSecrets, config.Cfg.WebhookQueueSize)
+	}
 
-		// Регистрируем эндпоинт проверки initData
-		http.HandleFunc("/api/checkInitData", checkInitDataHandler)
+	// SIGINT/SIGTERM cancel ctx: the bot stops taking updates and shuts down gracefully (see shutdown).
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		addr := ":8080" // порт, который вы экспонируете в Dockerfile
-		utils.Logger.Info("Starting HTTP server on " + addr)
-		if err := http.ListenAndServe(addr, nil); err != nil {
+	// Start HTTP‑server for WebApp
+	server := newHTTPServer(webhook)
+	go func() {
+		utils.Logger.Info("Starting HTTP server on " + server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			utils.Logger.Fatal("HTTP server failed: " + err.Error())
 		}
 	}()
 
-	// 5) Start receiving updates (long-polling by default).
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
+	// Finish games whose clock has run out even if nobody presses a button.
+	telegram.TelegramHandler.StartClockWatcher(ctx, time.Second)
+
+	// 5) Start receiving updates: long polling by default, or the webhook.
+	var updates <-chan tgbotapi.Update
+	if webhook != nil {
+		if err = telegram.SetWebhook(bot, config.Cfg.WebhookURL, webhookSecrets); err != nil {
+			utils.Logger.Fatal("Failed to set the webhook: " + err.Error())
+		}
+		utils.Logger.Info("Receiving updates via webhook at " + config.Cfg.WebhookURL + telegram.WebhookPathPrefix)
+		updates = webhook.Updates()
+	} else {
+		// getUpdates does not work while a webhook is set, e.g. after running in webhook mode.
+		if err = telegram.DeleteWebhook(bot); err != nil {
+			utils.Logger.Fatal("Failed to delete the webhook: " + err.Error())
+		}
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		updates = bot.GetUpdatesChan(u)
+	}
+
+	// 6) Process each incoming update in a loop until a signal arrives: HandleUpdate hands it to the worker
+	// of its room or chat
+	lastUpdateID := processUpdates(ctx, updates)
+
+	shutdown(bot, server, webhook != nil, updates, lastUpdateID)
+}
+
+// newHTTPServer builds the HTTP server on :8080 with the WebApp, the board pictures and, in webhook mode,
+// the Telegram webhook.
+func newHTTPServer(webhook *telegram.WebhookReceiver) *http.Server {
+	mux := http.NewServeMux()
+
+	// Раздаём React‑билд по запросам к /telegram-game/
+	fs := http.FileServer(http.Dir("frontend/build"))
+	mux.Handle("/telegram-game/", http.StripPrefix("/telegram-game/", fs))
+
+	// Регистрируем эндпоинт проверки initData
+	mux.HandleFunc("/api/checkInitData", checkInitDataHandler)
+
+	// Картинка доски комнаты: /board/<room_id>.svg?exp=...&sig=... (ссылку подписывает web.BoardPath)
+	mux.Handle(web.BoardPathPrefix,
+		web.BoardSVGHandler(db.GetRoomsRepo(), db.GetMovesRepo(), telegram.TelegramHandler.Callbacks))
+
+	// Обновления от Telegram в режиме webhook: /telegram/webhook/<secret>
+	if webhook != nil {
+		mux.Handle(telegram.WebhookPathPrefix, webhook)
+	}
+
+	return &http.Server{
+		Addr:    ":8080", // порт, который вы экспонируете в Dockerfile
+		Handler: mux,
+	}
+}
+
+// processUpdates passes the updates to the handler until ctx is cancelled and returns the ID of the last one.
+// Handlers get a context that is not cancelled by the signal, so a move being stored is not cut off midway.
+func processUpdates(ctx context.Context, updates <-chan tgbotapi.Update) int {
+	lastUpdateID := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return lastUpdateID
+		case update, ok := <-updates:
+			if !ok {
+				return lastUpdateID
+			}
+			telegram.TelegramHandler.HandleUpdate(context.WithoutCancel(ctx), update)
+			lastUpdateID = update.UpdateID
+		}
+	}
+}
+
+/*
+shutdown stops the bot after a signal, within SHUTDOWN_TIMEOUT:
+ 1. no new updates are taken: long polling is stopped, the HTTP server (and with it the webhook) shut down;
+ 2. the updates already received are processed, and with long polling confirmed to Telegram, so they
+    are not delivered again after the restart (the rest are);
+ 3. the handler finishes the queued updates, cancels the background tasks (bot replies, analyses,
+    the clock watcher), waits for them and closes the engine;
+ 4. the database pool is closed, unless the handler did not stop in time.
+*/
+func shutdown(bot *tgbotapi.BotAPI, server *http.Server, webhook bool, updates <-chan tgbotapi.Update, lastUpdateID int) {
+	utils.Logger.Info("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), config.Cfg.ShutdownTimeout)
+	defer cancel()
+
+	if !webhook {
+		bot.StopReceivingUpdates()
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		utils.Logger.Error("HTTP server shutdown: " + err.Error())
+	}
 
-	// 6) Process each incoming update in a loop
-	for update := range updates {
-		// Our handleUpdate is context-based if we need cancellation or deadlines
-		telegram.TelegramHandler.HandleUpdate(context.Background(), update)
+	// Drain the updates that are already received.
+	for drained := false; !drained; {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				drained = true
+				break
+			}
+			telegram.TelegramHandler.HandleUpdate(context.Background(), update)
+			lastUpdateID = update.UpdateID
+		default:
+			drained = true
+		}
+	}
+	if !webhook && lastUpdateID > 0 {
+		// An update counts as confirmed once getUpdates is called with a greater offset.
+		confirm := tgbotapi.UpdateConfig{Offset: lastUpdateID + 1, Limit: 1}
+		if _, err := bot.GetUpdates(confirm); err != nil {
+			utils.Logger.Warn("Failed to confirm the processed updates: " + err.Error())
+		}
+	}
+
+	if err := telegram.TelegramHandler.Shutdown(ctx); err != nil {
+		// Background tasks may still be using the pool: it is left to be closed by the exit.
+		utils.Logger.Error("Handler shutdown: " + err.Error())
+		return
+	}
+	db.Close()
+	utils.Logger.Info("Stopped")
+}
+
+// migrateUsage describes the migrate subcommand.
+const migrateUsage = `usage: bot migrate <command>
+  up        apply all pending migrations
+  down [N]  revert the last N applied migrations (default 1)
+  status    list the migrations and when they were applied`
+
+// runMigrate runs the migrate subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	ctx := context.Background()
+	db.Connect()
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx, db.Pool)
+		for _, mig := range applied {
+			fmt.Printf("applied  %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("nothing to apply, the schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Println(migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := db.MigrateDown(ctx, db.Pool, steps)
+		for _, mig := range reverted {
+			fmt.Printf("reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	case "status":
+		states, err := db.MigrationStatus(ctx, db.Pool)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, st := range states {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = "applied " + st.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-25s %s\n", st.Version, st.Name, applied)
+		}
+	default:
+		fmt.Println(migrateUsage)
+		return 2
 	}
+	return 0
 }
 
 func checkInitDataHandler(w http.ResponseWriter, r *http.Request) {
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/go-ozzo/ozzo-validation"
@@ -23,6 +25,8 @@ var Cfg Config
 type Config struct {
 	PGConfig
 	TelegramConfig
+	EngineConfig
+	ServerConfig
 }
 
 // PGConfig fields mapped to environment variables
@@ -35,12 +39,52 @@ type PGConfig struct {
 	// If needed, add more env fields here...
 }
 
+// Values of TelegramConfig.UpdatesMode.
+const (
+	UpdatesModePolling = "polling"
+	UpdatesModeWebhook = "webhook"
+)
+
 // TelegramConfig fields mapped to environment variables
 type TelegramConfig struct {
 	OwnerID       int64  `env:"OWNER_ID"`
 	BotToken      string `env:"BOT_TOKEN"`
 	GameShortName string `env:"GAME_SHORT_NAME"`
 	GameURL       string `env:"GAME_URL"`
+	// ReplayFrameDelay is how long each position stays on screen in the animated GIF replay of a game.
+	ReplayFrameDelay time.Duration `env:"REPLAY_FRAME_DELAY" envDefault:"1s"`
+	// CallbackSecret signs the data of the in-game buttons; without it a key is derived from BOT_TOKEN.
+	CallbackSecret string `env:"CALLBACK_SECRET"`
+	// CallbackTTL is how long an in-game button stays valid after it was sent.
+	CallbackTTL time.Duration `env:"CALLBACK_TTL" envDefault:"720h"`
+	// UpdateWorkers is how many updates are processed in parallel (updates of one room or chat never are).
+	UpdateWorkers int `env:"UPDATE_WORKERS" envDefault:"8"`
+	// UpdatesMode is how updates are received: UpdatesModePolling (getUpdates) or UpdatesModeWebhook.
+	UpdatesMode string `env:"UPDATES_MODE" envDefault:"polling"`
+	// WebhookURL is the public HTTPS address of the HTTP server, e.g. https://chess.example.com;
+	// Telegram posts the updates to WebhookURL/telegram/webhook/<path secret>.
+	WebhookURL string `env:"WEBHOOK_URL"`
+	// WebhookSecret is what the two webhook secrets (of the path and of the X-Telegram-Bot-Api-Secret-Token
+	// header) are derived from; without it they are derived from BOT_TOKEN.
+	WebhookSecret string `env:"WEBHOOK_SECRET"`
+	// WebhookQueueSize is how many received updates may wait to be processed before the webhook answers 503.
+	WebhookQueueSize int `env:"WEBHOOK_QUEUE_SIZE" envDefault:"100"`
+}
+
+// ServerConfig fields mapped to environment variables
+type ServerConfig struct {
+	// ShutdownTimeout is how long the bot waits on SIGINT/SIGTERM for the updates being processed,
+	// the HTTP requests and the background tasks before it exits anyway.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+}
+
+// EngineConfig fields mapped to environment variables.
+// Without UCI_ENGINE_PATH the bot uses its built-in engine.
+type EngineConfig struct {
+	UCIEnginePath    string            `env:"UCI_ENGINE_PATH"`                  // e.g. "stockfish" or "/usr/games/stockfish"
+	UCIEngineArgs    []string          `env:"UCI_ENGINE_ARGS" envSeparator:" "` // Command line arguments of the binary
+	UCIEnginePool    int               `env:"UCI_ENGINE_POOL" envDefault:"2"`   // Number of engine processes
+	UCIEngineOptions map[string]string `env:"UCI_ENGINE_OPTIONS"`               // UCI options, e.g. "Threads:1,Hash:64"
 }
 
 /*
@@ -79,12 +123,23 @@ Validate ensures that critical fields are set (e.g., BotToken).
 By default, Go-ozzo-validation or caarlos0/env can parse them from environment.
 */
 func (c *TelegramConfig) Validate() error {
-	return validation.ValidateStruct(
+	err := validation.ValidateStruct(
 		c,
 		validation.Field(&c.BotToken, validation.Required),
 		validation.Field(&c.GameShortName, validation.Required),
 		validation.Field(&c.GameURL, validation.Required),
+		validation.Field(&c.UpdatesMode, validation.In(UpdatesModePolling, UpdatesModeWebhook)),
+		// Telegram only accepts these characters in a secret token.
+		validation.Field(&c.WebhookSecret, validation.Match(regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`))),
+		validation.Field(&c.WebhookQueueSize, validation.Min(1)),
 	)
+	if err != nil {
+		return err
+	}
+	if c.UpdatesMode == UpdatesModeWebhook && c.WebhookURL == "" {
+		return fmt.Errorf("WEBHOOK_URL is required in %s mode", UpdatesModeWebhook)
+	}
+	return nil
 }
 
 /*
@@ -118,3 +173,18 @@ func LoadConfig() error {
 
 	return nil
 }
+
+// LoadPGConfig is LoadConfig for the commands that only need the database (e.g. "bot migrate"):
+// it parses and validates Cfg.PGConfig only, so the bot settings (BOT_TOKEN etc.) may be absent.
+func LoadPGConfig() error {
+	if err := godotenv.Load(); err != nil {
+		fmt.Printf("Warning: .env load error: %v\n", err)
+	}
+	if err := env.Parse(&Cfg.PGConfig); err != nil {
+		return fmt.Errorf("failed to parse environment variables: %w", err)
+	}
+	if err := Cfg.PGConfig.Validate(); err != nil {
+		return fmt.Errorf("PGConfig config invalid: %w", err)
+	}
+	return nil
+}
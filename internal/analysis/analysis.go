@@ -0,0 +1,216 @@
+/*
+Package analysis reviews finished games with a chess engine: every position of the game is evaluated,
+each move gets its centipawn loss and an accuracy score, and the worst ones are marked as
+inaccuracies, mistakes or blunders together with the engine's preferred move.
+
+The accuracy formulas follow the ones published by Lichess: evaluations are first converted
+into winning chances, and a move's accuracy depends on how much winning chance it gave away.
+*/
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/notnil/chess"
+
+	"lvlchess/internal/engine"
+)
+
+// Judgments of a move, from the mildest to the worst. An empty judgment means the move is fine.
+const (
+	JudgmentNone       = ""
+	JudgmentInaccuracy = "inaccuracy"
+	JudgmentMistake    = "mistake"
+	JudgmentBlunder    = "blunder"
+)
+
+const (
+	// Thresholds of the winning chance (in percentage points) a move may give away
+	// before it is judged as an inaccuracy, a mistake or a blunder.
+	inaccuracyDrop = 5.0
+	mistakeDrop    = 10.0
+	blunderDrop    = 15.0
+
+	// evalCap bounds evaluations (including mates) for centipawn loss, as a lost position
+	// cannot get much more lost.
+	evalCap = 1000
+)
+
+// Move is the analysis of a single ply.
+type Move struct {
+	Ply        int
+	Color      chess.Color
+	SAN        string
+	UCI        string
+	EvalBefore int     // Best achievable evaluation before the move, White's point of view (engine scale)
+	EvalAfter  int     // Evaluation after the move, White's point of view (engine scale)
+	CPLoss     int     // Centipawns lost by the move compared with the engine's choice (capped)
+	Accuracy   float64 // 0..100
+	Judgment   string  // One of the Judgment* values
+	BestSAN    string  // Engine's preferred move, if it differs from the played one
+	BestUCI    string
+}
+
+// Player sums up the analysed moves of one side.
+type Player struct {
+	ACPL         int     // Average centipawn loss
+	Accuracy     float64 // Mean move accuracy, 0..100
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+}
+
+// Report is the result of analysing a whole game.
+type Report struct {
+	Moves []Move
+	White Player
+	Black Player
+}
+
+// Analyze evaluates every position of chGame with eng, each within limits, and builds the report.
+// The game is replayed from its first position so that the engine sees the history of each position.
+func Analyze(ctx context.Context, eng engine.Engine, chGame *chess.Game, limits engine.Limits) (*Report, error) {
+	positions := chGame.Positions()
+	moves := chGame.Moves()
+
+	fenOption, err := chess.FEN(positions[0].String())
+	if err != nil {
+		return nil, fmt.Errorf("Analyze: %w", err)
+	}
+	replay := chess.NewGame(fenOption)
+
+	// evals[i] is the evaluation of positions[i] from White's point of view, best[i] the engine's move there.
+	evals := make([]int, len(positions))
+	best := make([]*chess.Move, len(positions))
+	for i := range positions {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		score, mv, err := evaluate(ctx, eng, replay, limits)
+		if err != nil {
+			return nil, fmt.Errorf("Analyze: ply %d: %w", i, err)
+		}
+		if replay.Position().Turn() == chess.Black {
+			score = -score
+		}
+		evals[i], best[i] = score, mv
+		if i < len(moves) {
+			if err = replay.Move(moves[i]); err != nil {
+				return nil, fmt.Errorf("Analyze: ply %d: %w", i+1, err)
+			}
+		}
+	}
+
+	report := &Report{Moves: make([]Move, 0, len(moves))}
+	for i, mv := range moves {
+		pos := positions[i]
+		color := pos.Turn()
+		m := Move{
+			Ply:        i + 1,
+			Color:      color,
+			SAN:        chess.AlgebraicNotation{}.Encode(pos, mv),
+			UCI:        chess.UCINotation{}.Encode(pos, mv),
+			EvalBefore: evals[i],
+			EvalAfter:  evals[i+1],
+		}
+
+		// Playing the engine's move loses nothing, whatever the (slightly noisy) evaluations say.
+		if best[i] != nil && best[i].String() != mv.String() {
+			// From the mover's point of view.
+			before, after := evals[i], evals[i+1]
+			if color == chess.Black {
+				before, after = -before, -after
+			}
+			m.CPLoss = max(0, capEval(before)-capEval(after))
+			m.BestSAN = chess.AlgebraicNotation{}.Encode(pos, best[i])
+			m.BestUCI = best[i].String()
+			drop := max(0, WinPercent(before)-WinPercent(after))
+			m.Accuracy = moveAccuracy(drop)
+			m.Judgment = judge(drop)
+		} else {
+			m.Accuracy = moveAccuracy(0)
+		}
+		report.Moves = append(report.Moves, m)
+	}
+	report.White, report.Black = Summarize(report.Moves)
+	return report, nil
+}
+
+// Summarize computes the per-player totals of analysed moves.
+func Summarize(moves []Move) (white, black Player) {
+	var lossSum, count [2]int
+	var accuracySum [2]float64
+	for _, m := range moves {
+		side, summary := 0, &white
+		if m.Color == chess.Black {
+			side, summary = 1, &black
+		}
+		lossSum[side] += m.CPLoss
+		accuracySum[side] += m.Accuracy
+		count[side]++
+		switch m.Judgment {
+		case JudgmentInaccuracy:
+			summary.Inaccuracies++
+		case JudgmentMistake:
+			summary.Mistakes++
+		case JudgmentBlunder:
+			summary.Blunders++
+		}
+	}
+	for side, summary := range []*Player{&white, &black} {
+		if count[side] > 0 {
+			summary.ACPL = int(math.Round(float64(lossSum[side]) / float64(count[side])))
+			summary.Accuracy = accuracySum[side] / float64(count[side])
+		}
+	}
+	return white, black
+}
+
+// evaluate returns the score of the game's current position from the side to move's point of view
+// and the engine's best move there (nil if the game is over).
+func evaluate(ctx context.Context, eng engine.Engine, chGame *chess.Game, limits engine.Limits) (int, *chess.Move, error) {
+	pos := chGame.Position()
+	if len(pos.ValidMoves()) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -engine.MateScore, nil, nil
+		}
+		return 0, nil, nil
+	}
+	res, err := eng.Search(ctx, chGame, limits)
+	if err != nil {
+		return 0, nil, err
+	}
+	return res.Score, res.Move, nil
+}
+
+// WinPercent converts an evaluation (engine scale, point of view of a player) into that player's
+// winning chances in percent, 50 being an equal position.
+func WinPercent(score int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(capEval(score))))-1)
+}
+
+// moveAccuracy maps the winning chance given away by a move to an accuracy between 0 and 100.
+func moveAccuracy(drop float64) float64 {
+	return min(100, max(0, 103.1668*math.Exp(-0.04354*drop)-3.1669))
+}
+
+// judge classifies a move by the winning chance it gave away.
+func judge(drop float64) string {
+	switch {
+	case drop >= blunderDrop:
+		return JudgmentBlunder
+	case drop >= mistakeDrop:
+		return JudgmentMistake
+	case drop >= inaccuracyDrop:
+		return JudgmentInaccuracy
+	default:
+		return JudgmentNone
+	}
+}
+
+// capEval limits an evaluation to ±evalCap centipawns; mates count as the cap.
+func capEval(score int) int {
+	return min(evalCap, max(-evalCap, score))
+}
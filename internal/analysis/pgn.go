@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"lvlchess/internal/engine"
+)
+
+// NAG returns the PGN Numeric Annotation Glyph of a judgment: $6 (?!), $2 (?) or $4 (??).
+// It returns "" for a move without a judgment.
+func NAG(judgment string) string {
+	switch judgment {
+	case JudgmentInaccuracy:
+		return "$6"
+	case JudgmentMistake:
+		return "$2"
+	case JudgmentBlunder:
+		return "$4"
+	default:
+		return ""
+	}
+}
+
+// Symbol returns the traditional annotation symbol of a judgment: "?!", "?" or "??".
+func Symbol(judgment string) string {
+	switch judgment {
+	case JudgmentInaccuracy:
+		return "?!"
+	case JudgmentMistake:
+		return "?"
+	case JudgmentBlunder:
+		return "??"
+	default:
+		return ""
+	}
+}
+
+// FormatEval renders an evaluation (engine scale, White's point of view) in pawns, e.g. "+0.35",
+// "-1.20", or as a mate distance in moves, e.g. "#3" / "#-2".
+func FormatEval(score int) string {
+	if engine.IsMate(score) {
+		plies := engine.MateScore - score
+		if score < 0 {
+			plies = engine.MateScore + score
+			return fmt.Sprintf("#-%d", (plies+1)/2)
+		}
+		return fmt.Sprintf("#%d", (plies+1)/2)
+	}
+	return fmt.Sprintf("%+.2f", float64(score)/100)
+}
+
+// PGNComment builds the text of the PGN comment of an analysed move (without braces):
+// the evaluation in the Lichess "[%eval]" format, plus for judged moves a short explanation,
+// e.g. "[%eval -1.20] Mistake. Nf3 was best.". A move that mates gets no evaluation.
+func PGNComment(eval int, judgment, bestSAN string) string {
+	var comment string
+	if eval != engine.MateScore && eval != -engine.MateScore {
+		comment = fmt.Sprintf("[%%eval %s]", pgnEval(eval))
+	}
+	if judgment == JudgmentNone {
+		return comment
+	}
+	label := map[string]string{
+		JudgmentInaccuracy: "Inaccuracy.",
+		JudgmentMistake:    "Mistake.",
+		JudgmentBlunder:    "Blunder.",
+	}[judgment]
+	comment = strings.TrimSpace(comment + " " + label)
+	if bestSAN != "" {
+		comment += " " + bestSAN + " was best."
+	}
+	return comment
+}
+
+// pgnEval formats an evaluation for "[%eval]": pawns without a sign for positive values, "#N" for mates.
+func pgnEval(score int) string {
+	if engine.IsMate(score) {
+		return FormatEval(score)
+	}
+	return fmt.Sprintf("%.2f", float64(score)/100)
+}
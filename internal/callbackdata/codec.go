@@ -0,0 +1,186 @@
+// Package callbackdata encodes the data of the in-game inline keyboard buttons. Telegram limits callback data
+// to 64 bytes and sends it back as is, so the data is packed into a compact binary form and signed:
+//
+//	"~" + base64url( version | action | room UUID (16 bytes) | expiry (uint32 unix seconds) | payload | HMAC )
+//
+// The HMAC-SHA256 tag (truncated to 12 bytes) covers everything before it, so a tampered button is rejected,
+// and a button older than the codec's TTL is rejected as expired.
+package callbackdata
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Prefix starts every encoded callback. It is not a base64url character, so encoded data cannot
+	// be confused with the plain "action:param" callbacks of the menus.
+	Prefix = "~"
+	// Version is the current format version, the first byte of the encoded data.
+	Version = 1
+	// MaxLength is Telegram's limit on the callback data of a button.
+	MaxLength = 64
+	// MaxPayload is the longest payload that keeps the encoded data within MaxLength.
+	MaxPayload = 6
+
+	headerSize = 1 + 1 + 16 + 4 // version, action, room, expiry
+	tagSize    = 12
+)
+
+// Action is what a button does.
+type Action byte
+
+// The actions of the in-game buttons. New actions are appended: the numbers are stored in the sent buttons.
+const (
+	ChooseFigure   Action = iota + 1 // payload: the square of the piece
+	ChoosePromo                      // payload: from and to squares of the promoting pawn
+	Move                             // payload: from and to squares, promotion piece type (0 = none)
+	BackToFigures                    // no payload
+	Resign                           // no payload
+	OfferDraw                        // no payload
+	AcceptDraw                       // no payload
+	DeclineDraw                      // no payload
+	Abort                            // no payload
+	SetTimeControl                   // payload: the time control preset code, e.g. "3+2" (empty = no clock)
+)
+
+var (
+	ErrMalformed = errors.New("callbackdata: malformed data")
+	ErrVersion   = errors.New("callbackdata: unsupported version")
+	ErrSignature = errors.New("callbackdata: bad signature")
+	ErrExpired   = errors.New("callbackdata: expired")
+)
+
+// Data is a decoded callback.
+type Data struct {
+	Action  Action
+	RoomID  string
+	Payload []byte
+	Expires time.Time
+}
+
+// Codec encodes and decodes callbacks signed with its key. Buttons expire ttl after they were created.
+type Codec struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewCodec creates a codec with the given signing key and button lifetime.
+func NewCodec(key []byte, ttl time.Duration) *Codec {
+	return &Codec{key: key, ttl: ttl, now: time.Now}
+}
+
+// IsEncoded reports whether data looks like a callback produced by a Codec.
+func IsEncoded(data string) bool {
+	return strings.HasPrefix(data, Prefix)
+}
+
+// Encode packs an action on a room (its ID must be a UUID) and the payload into callback data.
+func (c *Codec) Encode(action Action, roomID string, payload ...byte) (string, error) {
+	room, err := uuid.Parse(roomID)
+	if err != nil {
+		return "", fmt.Errorf("callbackdata: room ID %q: %w", roomID, err)
+	}
+	if len(payload) > MaxPayload {
+		return "", fmt.Errorf("callbackdata: payload of %d bytes is too long", len(payload))
+	}
+
+	buf := make([]byte, 0, headerSize+len(payload)+tagSize)
+	buf = append(buf, Version, byte(action))
+	buf = append(buf, room[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(c.now().Add(c.ttl).Unix()))
+	buf = append(buf, payload...)
+	buf = append(buf, c.tag(buf)...)
+	return Prefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Decode unpacks callback data produced by Encode, checking its version, signature and expiry.
+func (c *Codec) Decode(data string) (Data, error) {
+	encoded, ok := strings.CutPrefix(data, Prefix)
+	if !ok {
+		return Data{}, ErrMalformed
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(buf) < headerSize+tagSize {
+		return Data{}, ErrMalformed
+	}
+	if buf[0] != Version {
+		return Data{}, ErrVersion
+	}
+	body, tag := buf[:len(buf)-tagSize], buf[len(buf)-tagSize:]
+	if !hmac.Equal(tag, c.tag(body)) {
+		return Data{}, ErrSignature
+	}
+
+	room, _ := uuid.FromBytes(body[2:18])
+	d := Data{
+		Action:  Action(body[1]),
+		RoomID:  room.String(),
+		Payload: body[headerSize:],
+		Expires: time.Unix(int64(binary.BigEndian.Uint32(body[18:22])), 0),
+	}
+	if c.now().After(d.Expires) {
+		return d, ErrExpired
+	}
+	return d, nil
+}
+
+// PeekRoom returns the room ID of encoded callback data without checking its signature or expiry, so it is cheap
+// enough to route every update with. It is not to be trusted: the data must still be decoded before acting on it.
+func PeekRoom(data string) (string, bool) {
+	encoded, ok := strings.CutPrefix(data, Prefix)
+	if !ok {
+		return "", false
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(buf) < headerSize+tagSize || buf[0] != Version {
+		return "", false
+	}
+	room, _ := uuid.FromBytes(buf[2:18])
+	return room.String(), true
+}
+
+// tag computes the truncated HMAC of the encoded body.
+func (c *Codec) tag(body []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(body)
+	return mac.Sum(nil)[:tagSize]
+}
+
+/*
+SignLink signs a link to a resource of the bot outside Telegram (e.g. the board picture of a room) with the codec's
+key: the link is valid until expires. The signed message is domain-separated from the callbacks, so a button tag
+can never pass as a link signature or the other way round.
+*/
+func (c *Codec) SignLink(resource string, expires time.Time) string {
+	return base64.RawURLEncoding.EncodeToString(c.linkTag(resource, expires.Unix()))
+}
+
+// VerifyLink checks a signature made by SignLink for the resource and expiry: ErrSignature if it does not match,
+// ErrExpired if the link is too old.
+func (c *Codec) VerifyLink(resource string, expires time.Time, sig string) error {
+	tag, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(tag, c.linkTag(resource, expires.Unix())) {
+		return ErrSignature
+	}
+	if c.now().After(expires) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// linkTag computes the truncated HMAC of a link.
+func (c *Codec) linkTag(resource string, expires int64) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	fmt.Fprintf(mac, "link\x00%s\x00%d", resource, expires)
+	return mac.Sum(nil)[:tagSize]
+}
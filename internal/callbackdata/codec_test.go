@@ -0,0 +1,210 @@
+package callbackdata
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRoom = "0b8f6a4e-3c1d-4f5e-9a7b-2d6c8e1f0a93"
+
+// newTestCodec returns a codec whose clock is frozen at the returned time.
+func newTestCodec(ttl time.Duration) (*Codec, *time.Time) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCodec([]byte("test key"), ttl)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		payload []byte
+	}{
+		{"no payload", Resign, nil},
+		{"one square", ChooseFigure, []byte{12, 0, 3}},
+		{"move with promotion", Move, []byte{52, 60, 2, 0, 7}},
+		{"time control code", SetTimeControl, []byte("15+10")},
+		{"max payload", Move, make([]byte, MaxPayload)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, now := newTestCodec(time.Hour)
+			data, err := c.Encode(tt.action, testRoom, tt.payload...)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if !IsEncoded(data) {
+				t.Errorf("IsEncoded(%q) = false", data)
+			}
+			d, err := c.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if d.Action != tt.action || d.RoomID != testRoom || string(d.Payload) != string(tt.payload) {
+				t.Errorf("Decode = %+v, want action %d, room %s, payload %v", d, tt.action, testRoom, tt.payload)
+			}
+			if want := now.Add(time.Hour); !d.Expires.Equal(want) {
+				t.Errorf("Expires = %v, want %v", d.Expires, want)
+			}
+		})
+	}
+}
+
+// TestCodecMaxLength checks that the longest button fits into Telegram's limit, whatever the payload bytes.
+func TestCodecMaxLength(t *testing.T) {
+	c, _ := newTestCodec(time.Hour)
+	payload := []byte(strings.Repeat("\xff", MaxPayload))
+	data, err := c.Encode(SetTimeControl, testRoom, payload...)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if len(data) > MaxLength {
+		t.Errorf("len(%q) = %d, want at most %d", data, len(data), MaxLength)
+	}
+}
+
+func TestCodecEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		roomID  string
+		payload []byte
+	}{
+		{"oversize payload", testRoom, make([]byte, MaxPayload+1)},
+		{"room ID is not a UUID", "room-1", nil},
+		{"empty room ID", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCodec(time.Hour)
+			if data, err := c.Encode(Move, tt.roomID, tt.payload...); err == nil {
+				t.Errorf("Encode = %q, want an error", data)
+			}
+		})
+	}
+}
+
+func TestCodecDecodeErrors(t *testing.T) {
+	c, now := newTestCodec(time.Hour)
+	valid, err := c.Encode(Move, testRoom, 12, 28, 0, 0, 1)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(valid, Prefix))
+
+	// mutate returns valid with the byte at i of the decoded data changed by f.
+	mutate := func(i int, f func(byte) byte) string {
+		buf := append([]byte(nil), raw...)
+		buf[i] = f(buf[i])
+		return Prefix + base64.RawURLEncoding.EncodeToString(buf)
+	}
+	flip := func(b byte) byte { return b ^ 0x01 }
+
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"no prefix", strings.TrimPrefix(valid, Prefix), ErrMalformed},
+		{"plain callback", "move:e2e4", ErrMalformed},
+		{"not base64", Prefix + "!!!", ErrMalformed},
+		{"too short", Prefix + base64.RawURLEncoding.EncodeToString(raw[:headerSize]), ErrMalformed},
+		{"wrong version", mutate(0, func(byte) byte { return Version + 1 }), ErrVersion},
+		{"flipped action", mutate(1, flip), ErrSignature},
+		{"flipped room byte", mutate(5, flip), ErrSignature},
+		{"flipped expiry", mutate(20, flip), ErrSignature},
+		{"flipped payload", mutate(headerSize, flip), ErrSignature},
+		{"flipped tag", mutate(len(raw)-1, flip), ErrSignature},
+		{"other key", mustEncode(t, NewCodec([]byte("other key"), time.Hour)), ErrSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decode(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Decode error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		*now = now.Add(time.Hour + time.Second)
+		d, err := c.Decode(valid)
+		if !errors.Is(err, ErrExpired) {
+			t.Fatalf("Decode error = %v, want %v", err, ErrExpired)
+		}
+		// The room is still returned, so the keyboard of an expired button can be refreshed.
+		if d.RoomID != testRoom {
+			t.Errorf("RoomID = %q, want %q", d.RoomID, testRoom)
+		}
+	})
+}
+
+func mustEncode(t *testing.T, c *Codec) string {
+	t.Helper()
+	data, err := c.Encode(Move, testRoom, 12, 28, 0, 0, 1)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return data
+}
+
+func TestCodecLinks(t *testing.T) {
+	c, now := newTestCodec(time.Hour)
+	expires := now.Add(10 * time.Minute)
+	sig := c.SignLink(testRoom, expires)
+
+	tests := []struct {
+		name     string
+		resource string
+		expires  time.Time
+		sig      string
+		at       time.Time
+		want     error
+	}{
+		{"valid", testRoom, expires, sig, *now, nil},
+		{"other resource", "0b8f6a4e-3c1d-4f5e-9a7b-2d6c8e1f0a94", expires, sig, *now, ErrSignature},
+		{"extended expiry", testRoom, expires.Add(time.Hour), sig, *now, ErrSignature},
+		{"not base64", testRoom, expires, "!!!", *now, ErrSignature},
+		{"button tag", testRoom, expires, strings.TrimPrefix(mustEncode(t, c), Prefix), *now, ErrSignature},
+		{"expired", testRoom, expires, sig, expires.Add(time.Second), ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*now = tt.at
+			if err := c.VerifyLink(tt.resource, tt.expires, tt.sig); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyLink error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeekRoom(t *testing.T) {
+	c, now := newTestCodec(time.Hour)
+	valid := mustEncode(t, c)
+	raw, _ := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(valid, Prefix))
+	raw[len(raw)-1] ^= 0x01
+	tampered := Prefix + base64.RawURLEncoding.EncodeToString(raw)
+	*now = now.Add(2 * time.Hour)
+
+	tests := []struct {
+		name   string
+		data   string
+		wantOK bool
+	}{
+		{"valid", valid, true},
+		{"expired and tampered", tampered, true},
+		{"plain callback", "move:e2e4", false},
+		{"not base64", Prefix + "!!!", false},
+		{"too short", Prefix + base64.RawURLEncoding.EncodeToString(raw[:headerSize]), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, ok := PeekRoom(tt.data)
+			if ok != tt.wantOK || ok && room != testRoom {
+				t.Errorf("PeekRoom = %q, %v, want %q, %v", room, ok, testRoom, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"lvlchess/internal/db/models"
+)
+
+// messageKey is the primary key of room_messages.
+type messageKey struct {
+	roomID string
+	chatID int64
+	kind   string
+}
+
+// messagesStore implements repositories.MessageStore on a Store.
+type messagesStore Store
+
+// GetRoomMessage returns the live message of the given kind of a room in a chat, or nil if there is none yet.
+func (s *messagesStore) GetRoomMessage(ctx context.Context, roomID string, chatID int64, kind string) (*models.RoomMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[messageKey{roomID, chatID, kind}]
+	if !ok {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// GetRoomMessages returns every live message of the given kind of a room, in all chats (by chat ID).
+func (s *messagesStore) GetRoomMessages(ctx context.Context, roomID string, kind string) ([]models.RoomMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.RoomMessage
+	for key, m := range s.messages {
+		if key.roomID == roomID && key.kind == kind {
+			result = append(result, m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ChatID < result[j].ChatID })
+	return result, nil
+}
+
+// SaveRoomMessage stores the live message of a room in a chat, replacing the previous one of the same kind.
+// The room must exist (fk_message_room).
+func (s *messagesStore) SaveRoomMessage(ctx context.Context, m *models.RoomMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rooms[m.RoomID]; !ok {
+		return fmt.Errorf("SaveRoomMessage: room_id %s is not a room (fk_message_room)", m.RoomID)
+	}
+	stored := *m
+	stored.UpdatedAt = s.now()
+	s.messages[messageKey{m.RoomID, m.ChatID, m.Kind}] = stored
+	return nil
+}
+
+// DeleteRoomMessages forgets the live messages of a room in a chat.
+func (s *messagesStore) DeleteRoomMessages(ctx context.Context, roomID string, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.messages {
+		if key.roomID == roomID && key.chatID == chatID {
+			delete(s.messages, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
+
+	"github.com/notnil/chess"
+)
+
+// movesStore implements repositories.MoveStore on a Store. The moves are appended by
+// roomsStore.UpdateRoomWithMove.
+type movesStore Store
+
+// GetMovesByRoomID returns every move of the room ordered by ply; nil if no move has been played yet.
+func (s *movesStore) GetMovesByRoomID(ctx context.Context, roomID string) ([]models.RoomMove, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.RoomMove
+	for _, mv := range s.moves[roomID] {
+		result = append(result, cloneMove(mv))
+	}
+	return result, nil
+}
+
+// SaveAnalysis stores the analysis fields of the given moves, matched by room and ply.
+// Moves that are not stored are skipped, as by the UPDATE.
+func (s *movesStore) SaveAnalysis(ctx context.Context, moves []models.RoomMove) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, mv := range moves {
+		stored := s.moves[mv.RoomID]
+		if mv.Ply < 1 || mv.Ply > len(stored) {
+			continue
+		}
+		analysed := cloneMove(mv)
+		target := &stored[mv.Ply-1]
+		target.Eval = analysed.Eval
+		target.CPLoss = analysed.CPLoss
+		target.Accuracy = analysed.Accuracy
+		target.Judgment = analysed.Judgment
+		target.BestSAN = analysed.BestSAN
+	}
+	return nil
+}
+
+// GetLastMove returns the latest move of the room without its analysis, like MovesRepository.GetLastMove,
+// or nil if no move has been played yet.
+func (s *movesStore) GetLastMove(ctx context.Context, roomID string) (*models.RoomMove, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moves := s.moves[roomID]
+	if len(moves) == 0 {
+		return nil, nil
+	}
+	last := moves[len(moves)-1]
+	return &models.RoomMove{
+		RoomID:    last.RoomID,
+		Ply:       last.Ply,
+		UCI:       last.UCI,
+		SAN:       last.SAN,
+		FENAfter:  last.FENAfter,
+		MoverID:   last.MoverID,
+		CreatedAt: last.CreatedAt,
+	}, nil
+}
+
+// CountMoves returns how many plies have been played in the room so far.
+func (s *movesStore) CountMoves(ctx context.Context, roomID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.moves[roomID]), nil
+}
+
+// ReplayGame rebuilds a *chess.Game from the stored moves of the room, see repositories.ReplayMoves.
+func (s *movesStore) ReplayGame(ctx context.Context, roomID, startFEN string) (*chess.Game, error) {
+	moves, err := s.GetMovesByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("ReplayGame: %w", err)
+	}
+	return repositories.ReplayMoves(moves, startFEN)
+}
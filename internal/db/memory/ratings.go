@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/rating"
+)
+
+// ratingKey is the primary key of user_ratings.
+type ratingKey struct {
+	userID   int64
+	category string
+}
+
+// ratingsStore implements repositories.RatingStore on a Store.
+type ratingsStore Store
+
+// GetUserRating returns the rating of a user in the category, or the default (provisional) one
+// if the user has not played a rated game in it.
+func (s *ratingsStore) GetUserRating(ctx context.Context, userID int64, category string) (*models.UserRating, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ur, ok := s.ratings[ratingKey{userID, category}]; ok {
+		found := *ur
+		return &found, nil
+	}
+	def := rating.Default()
+	return &models.UserRating{
+		UserID:     userID,
+		Category:   category,
+		Rating:     def.Rating,
+		RD:         def.RD,
+		Volatility: def.Volatility,
+	}, nil
+}
+
+// GetLeaderboard returns up to limit players of the category with at least models.ProvisionalGames games,
+// best first.
+func (s *ratingsStore) GetLeaderboard(ctx context.Context, category string, limit int) ([]models.UserRating, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.UserRating
+	for key, ur := range s.ratings {
+		if key.category == category && ur.Games >= models.ProvisionalGames {
+			result = append(result, *ur)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Rating != result[j].Rating {
+			return result[i].Rating > result[j].Rating
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	if limit >= 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// GetRatingChangesByRoomID returns the rating changes caused by the room, in the order they were made.
+func (s *ratingsStore) GetRatingChangesByRoomID(ctx context.Context, roomID string) ([]models.RatingChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []models.RatingChange
+	for _, c := range s.history {
+		if c.RoomID == roomID {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// applyRatedResult updates the ratings of both players of a finished rated room in its category and
+// records the changes, like the PostgreSQL repositories do when the room finishes. The caller holds s.mu.
+func (s *Store) applyRatedResult(room *models.Room) {
+	if !room.Rated || room.Result == models.ResultNone || room.WhiteID == nil || room.BlackID == nil ||
+		*room.WhiteID == *room.BlackID {
+		return
+	}
+
+	var score float64
+	switch room.Result {
+	case models.ResultWhiteWon:
+		score = rating.ScoreWin
+	case models.ResultBlackWon:
+		score = rating.ScoreLoss
+	default:
+		score = rating.ScoreDraw
+	}
+
+	category := models.RatingCategoryFor(room)
+	white, black := s.playerRating(*room.WhiteID, category), s.playerRating(*room.BlackID, category)
+	before := []rating.Rating{
+		{Rating: white.Rating, RD: white.RD, Volatility: white.Volatility},
+		{Rating: black.Rating, RD: black.RD, Volatility: black.Volatility},
+	}
+	newWhite, newBlack := rating.Rate(before[0], before[1], score)
+
+	now := s.now()
+	for i, p := range []struct {
+		ur    *models.UserRating
+		after rating.Rating
+	}{{white, newWhite}, {black, newBlack}} {
+		p.ur.Rating, p.ur.RD, p.ur.Volatility = p.after.Rating, p.after.RD, p.after.Volatility
+		p.ur.Games++
+		p.ur.UpdatedAt = now
+		s.history = append(s.history, models.RatingChange{
+			ID:              int64(len(s.history) + 1),
+			UserID:          p.ur.UserID,
+			RoomID:          room.RoomID,
+			Category:        category,
+			RatingBefore:    before[i].Rating,
+			RatingAfter:     p.after.Rating,
+			RDBefore:        before[i].RD,
+			RDAfter:         p.after.RD,
+			VolatilityAfter: p.after.Volatility,
+			CreatedAt:       now,
+		})
+	}
+}
+
+// playerRating returns the stored rating of a user in a category, creating the default one for a first-timer.
+// The caller holds s.mu.
+func (s *Store) playerRating(userID int64, category string) *models.UserRating {
+	key := ratingKey{userID, category}
+	if ur, ok := s.ratings[key]; ok {
+		return ur
+	}
+	def := rating.Default()
+	ur := &models.UserRating{
+		UserID:     userID,
+		Category:   category,
+		Rating:     def.Rating,
+		RD:         def.RD,
+		Volatility: def.Volatility,
+		UpdatedAt:  s.now(),
+	}
+	s.ratings[key] = ur
+	return ur
+}
@@ -0,0 +1,340 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
+)
+
+// roomsStore implements repositories.RoomStore on a Store.
+type roomsStore Store
+
+/*
+CreateRoom stores a new room with the columns the INSERT of RoomsRepository.CreateRoom writes: the second player,
+colors, chat, running clock, result and draw offer always start empty, and the version at 0.
+A taken room ID is reported as ErrUniqueViolation, an unknown first player as a foreign key error.
+*/
+func (s *roomsStore) CreateRoom(ctx context.Context, room *models.Room) error {
+	if err := room.Validate(); err != nil {
+		return fmt.Errorf("room.Validate: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rooms[room.RoomID]; ok {
+		return errors.New(repositories.ErrUniqueViolation)
+	}
+	if _, ok := s.users[room.Player1ID]; !ok {
+		return fmt.Errorf("CreateRoom: player1_id %d is not a user (fk_p1)", room.Player1ID)
+	}
+
+	now := s.now()
+	row := &roomRow{room: models.Room{
+		RoomID:         room.RoomID,
+		RoomTitle:      room.RoomTitle,
+		Player1ID:      room.Player1ID,
+		Status:         room.Status,
+		BoardState:     room.BoardState,
+		IsWhiteTurn:    room.IsWhiteTurn,
+		InitialFEN:     room.InitialFEN,
+		TimeControl:    room.TimeControl,
+		ClockInitial:   room.ClockInitial,
+		ClockIncrement: room.ClockIncrement,
+		DaysPerMove:    room.DaysPerMove,
+		WhiteTimeMs:    room.WhiteTimeMs,
+		BlackTimeMs:    room.BlackTimeMs,
+		Rated:          room.Rated,
+		BotLevel:       room.BotLevel,
+		CreatedAt:      now,
+	}}
+	(*Store)(s).touch(row)
+	s.rooms[room.RoomID] = row
+	return nil
+}
+
+// GetRoomByID returns the room, or an error wrapping repositories.ErrNotFound.
+func (s *roomsStore) GetRoomByID(ctx context.Context, roomID string) (*models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.rooms[roomID]
+	if !ok {
+		return nil, fmt.Errorf("GetRoomByID: %w", repositories.ErrNotFound)
+	}
+	room := cloneRoom(row.room)
+	return &room, nil
+}
+
+// GetRoomByChatID returns the room tied to the group chat (the last written one if there are several),
+// or an error wrapping repositories.ErrNotFound.
+func (s *roomsStore) GetRoomByChatID(ctx context.Context, chatID int64) (*models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rooms := s.selectRooms(func(r *models.Room) bool { return r.ChatID != nil && *r.ChatID == chatID })
+	if len(rooms) == 0 {
+		return nil, fmt.Errorf("GetRoomByChatID: %w", repositories.ErrNotFound)
+	}
+	return &rooms[0], nil
+}
+
+// GetRoomByPlayerIDs returns the unfinished room of the pair, in either order (with p2ID = 0: the room of p1ID
+// still waiting for a second player), or an error wrapping repositories.ErrNotFound.
+func (s *roomsStore) GetRoomByPlayerIDs(ctx context.Context, p1ID, p2ID int64) (*models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rooms := s.selectRooms(func(r *models.Room) bool {
+		if r.Status != models.RoomStatusWaiting && r.Status != models.RoomStatusPlaying {
+			return false
+		}
+		if p2ID == 0 {
+			return r.Player1ID == p1ID && r.Player2ID == nil
+		}
+		return r.Player2ID != nil &&
+			(r.Player1ID == p1ID && *r.Player2ID == p2ID || r.Player1ID == p2ID && *r.Player2ID == p1ID)
+	})
+	if len(rooms) == 0 {
+		return nil, fmt.Errorf("GetRoomByPlayerIDs: %w", repositories.ErrNotFound)
+	}
+	return &rooms[0], nil
+}
+
+/*
+UpdateRoom writes the columns RoomsRepository.UpdateRoom writes (everything but the first player,
+the initial position and the creation time) if the stored room still has room.Version, and bumps the version.
+A missing room or another version is reported as ErrRoomVersionConflict, an unfinished room of the same pair
+as ErrUniqueViolation.
+*/
+func (s *roomsStore) UpdateRoom(ctx context.Context, room *models.Room) error {
+	if err := room.Validate(); err != nil {
+		return fmt.Errorf("UpdateRoom Validate: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.rooms[room.RoomID]
+	if !ok || row.room.Version != room.Version {
+		return fmt.Errorf("UpdateRoom %s: %w", room.RoomID, repositories.ErrRoomVersionConflict)
+	}
+	if room.Status != models.RoomStatusFinished && room.Player2ID != nil {
+		for id, other := range s.rooms {
+			if id != room.RoomID && other.room.Status != models.RoomStatusFinished &&
+				other.room.Player1ID == row.room.Player1ID && equalInt64(other.room.Player2ID, room.Player2ID) {
+				return errors.New(repositories.ErrUniqueViolation)
+			}
+		}
+		if _, ok := s.users[*room.Player2ID]; !ok {
+			return fmt.Errorf("UpdateRoom exec: player2_id %d is not a user (fk_p2)", *room.Player2ID)
+		}
+	}
+
+	r := cloneRoom(*room)
+	stored := &row.room
+	stored.RoomTitle = r.RoomTitle
+	stored.Player2ID = r.Player2ID
+	stored.Status = r.Status
+	stored.BoardState = r.BoardState
+	stored.IsWhiteTurn = r.IsWhiteTurn
+	stored.WhiteID = r.WhiteID
+	stored.BlackID = r.BlackID
+	stored.ChatID = r.ChatID
+	stored.TimeControl = r.TimeControl
+	stored.ClockInitial = r.ClockInitial
+	stored.ClockIncrement = r.ClockIncrement
+	stored.DaysPerMove = r.DaysPerMove
+	stored.WhiteTimeMs = r.WhiteTimeMs
+	stored.BlackTimeMs = r.BlackTimeMs
+	stored.TurnStartedAt = r.TurnStartedAt
+	stored.Result = r.Result
+	stored.Termination = r.Termination
+	stored.DrawOfferedBy = r.DrawOfferedBy
+	stored.Rated = r.Rated
+	stored.BotLevel = r.BotLevel
+	stored.Version++
+	(*Store)(s).touch(row)
+
+	room.Version++
+	return nil
+}
+
+/*
+UpdateRoomWithMove writes the position, clocks and result of a "playing" room that still has room.Version,
+appends the move with the next ply (written back into mv) and, if the move finished the game,
+counts it for both players. Otherwise nothing is written and ErrRoomVersionConflict is returned.
+*/
+func (s *roomsStore) UpdateRoomWithMove(ctx context.Context, room *models.Room, mv *models.RoomMove) error {
+	if err := room.Validate(); err != nil {
+		return fmt.Errorf("UpdateRoomWithMove Validate room: %w", err)
+	}
+	if err := mv.Validate(); err != nil {
+		return fmt.Errorf("UpdateRoomWithMove Validate move: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.rooms[room.RoomID]
+	if !ok || row.room.Status != models.RoomStatusPlaying || row.room.Version != room.Version {
+		return fmt.Errorf("UpdateRoomWithMove %s: %w", room.RoomID, repositories.ErrRoomVersionConflict)
+	}
+	if _, ok := s.rooms[mv.RoomID]; !ok {
+		return fmt.Errorf("UpdateRoomWithMove insert move: room_id %s is not a room (fk_move_room)", mv.RoomID)
+	}
+	if _, ok := s.users[mv.MoverID]; !ok {
+		return fmt.Errorf("UpdateRoomWithMove insert move: mover_id %d is not a user (fk_move_user)", mv.MoverID)
+	}
+
+	r := cloneRoom(*room)
+	stored := &row.room
+	stored.BoardState = r.BoardState
+	stored.IsWhiteTurn = r.IsWhiteTurn
+	stored.Status = r.Status
+	stored.WhiteTimeMs = r.WhiteTimeMs
+	stored.BlackTimeMs = r.BlackTimeMs
+	stored.TurnStartedAt = r.TurnStartedAt
+	stored.DrawOfferedBy = r.DrawOfferedBy
+	stored.Result = r.Result
+	stored.Termination = r.Termination
+	stored.Version++
+	(*Store)(s).touch(row)
+
+	mv.Ply = len(s.moves[mv.RoomID]) + 1
+	mv.CreatedAt = s.now()
+	s.moves[mv.RoomID] = append(s.moves[mv.RoomID], models.RoomMove{
+		RoomID:    mv.RoomID,
+		Ply:       mv.Ply,
+		UCI:       mv.UCI,
+		SAN:       mv.SAN,
+		FENAfter:  mv.FENAfter,
+		MoverID:   mv.MoverID,
+		CreatedAt: mv.CreatedAt,
+	})
+
+	if room.Status == models.RoomStatusFinished {
+		(*Store)(s).updatePlayerStats(room)
+	}
+
+	room.Version++
+	return nil
+}
+
+// GetPlayingRoomsForUser returns the "waiting" and "playing" rooms of the user, last written first.
+func (s *roomsStore) GetPlayingRoomsForUser(ctx context.Context, userID int64) ([]models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.selectRooms(func(r *models.Room) bool {
+		return (r.Status == models.RoomStatusWaiting || r.Status == models.RoomStatusPlaying) && hasPlayer(r, userID)
+	}), nil
+}
+
+// GetFinishedRoomsForUser returns up to limit finished rooms of the user, last written first.
+func (s *roomsStore) GetFinishedRoomsForUser(ctx context.Context, userID int64, limit int) ([]models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rooms := s.selectRooms(func(r *models.Room) bool {
+		return r.Status == models.RoomStatusFinished && hasPlayer(r, userID)
+	})
+	if limit >= 0 && len(rooms) > limit {
+		rooms = rooms[:limit]
+	}
+	return rooms, nil
+}
+
+// GetRoomsWithRunningClock returns the "playing" rooms with a time control whose clocks are running.
+func (s *roomsStore) GetRoomsWithRunningClock(ctx context.Context) ([]models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.selectRooms(func(r *models.Room) bool {
+		return r.Status == models.RoomStatusPlaying && r.HasClock() && r.TurnStartedAt != nil
+	}), nil
+}
+
+/*
+FinishRoom finishes a "playing" room that still has room.Version and room.BoardState, recording the result and
+the final clocks, and counts the game for both players, like RoomsRepository.FinishRoom.
+Otherwise nothing is written and ErrRoomVersionConflict is returned.
+*/
+func (s *roomsStore) FinishRoom(ctx context.Context, room *models.Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.rooms[room.RoomID]
+	if !ok || row.room.Status != models.RoomStatusPlaying || row.room.Version != room.Version ||
+		row.room.BoardState != room.BoardState {
+		return fmt.Errorf("FinishRoom %s: %w", room.RoomID, repositories.ErrRoomVersionConflict)
+	}
+
+	stored := &row.room
+	stored.Status = models.RoomStatusFinished
+	stored.WhiteTimeMs = room.WhiteTimeMs
+	stored.BlackTimeMs = room.BlackTimeMs
+	stored.TurnStartedAt = nil
+	stored.Result = room.Result
+	stored.Termination = room.Termination
+	stored.DrawOfferedBy = nil
+	stored.Version++
+	(*Store)(s).touch(row)
+	(*Store)(s).updatePlayerStats(room)
+
+	room.Status = models.RoomStatusFinished
+	room.TurnStartedAt = nil
+	room.DrawOfferedBy = nil
+	room.Version++
+	return nil
+}
+
+// selectRooms returns copies of the rooms matching keep, last written first. The caller holds s.mu.
+func (s *roomsStore) selectRooms(keep func(r *models.Room) bool) []models.Room {
+	var rows []*roomRow
+	for _, row := range s.rooms {
+		if keep(&row.room) {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].touched > rows[j].touched })
+
+	rooms := make([]models.Room, len(rows))
+	for i, row := range rows {
+		rooms[i] = cloneRoom(row.room)
+	}
+	return rooms
+}
+
+// hasPlayer reports whether the user is one of the players of the room.
+func hasPlayer(r *models.Room, userID int64) bool {
+	return r.Player1ID == userID || r.Player2ID != nil && *r.Player2ID == userID
+}
+
+// updatePlayerStats counts a finished game for both players: total games for each, a win for the winner,
+// and, for rated rooms, new ratings. Games without a result (aborted) are not counted. The caller holds s.mu.
+func (s *Store) updatePlayerStats(room *models.Room) {
+	if room.Result == models.ResultNone || room.WhiteID == nil || room.BlackID == nil {
+		return
+	}
+	s.applyRatedResult(room)
+	ids := []int64{*room.WhiteID}
+	if *room.BlackID != *room.WhiteID {
+		ids = append(ids, *room.BlackID)
+	}
+	for _, id := range ids {
+		u, ok := s.users[id]
+		if !ok {
+			continue
+		}
+		u.TotalGames++
+		if room.Result == models.ResultWhiteWon && id == *room.WhiteID ||
+			room.Result == models.ResultBlackWon && id == *room.BlackID {
+			u.Wins++
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Package memory is an in-memory implementation of the storage interfaces of package repositories
+(UserStore, RoomStore, MoveStore, MessageStore, RatingStore, TournamentStore, TournamentSettingsStore),
+for unit and scenario tests of the bot that should not need PostgreSQL.
+
+It follows the semantics of the PostgreSQL repositories rather than just storing structs: only the columns
+a query writes are changed, the rooms_active_players_pair index and the foreign keys are enforced,
+lookups of a missing record wrap repositories.ErrNotFound and room updates check the version.
+Values are copied in and out, so a caller never shares memory with the store.
+As in PostgreSQL, the moves are written by UpdateRoomWithMove, and the ratings and the rating history
+when a rated room finishes.
+*/
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
+)
+
+// Store holds all the records behind a single mutex; it is safe for concurrent use.
+type Store struct {
+	mu          sync.Mutex
+	users       map[int64]*models.User
+	rooms       map[string]*roomRow
+	moves       map[string][]models.RoomMove
+	messages    map[messageKey]models.RoomMessage
+	ratings     map[ratingKey]*models.UserRating
+	history     []models.RatingChange // rating_history, by ID
+	tournaments map[string]*models.Tournament
+	settings    []models.TournamentSettings
+	seq         uint64 // last roomRow.touched
+	now         func() time.Time
+}
+
+// roomRow is a stored room with the order of its last write, so that "ORDER BY updated_at DESC"
+// is deterministic even for writes within the same clock tick.
+type roomRow struct {
+	room    models.Room
+	touched uint64
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		users:       make(map[int64]*models.User),
+		rooms:       make(map[string]*roomRow),
+		moves:       make(map[string][]models.RoomMove),
+		messages:    make(map[messageKey]models.RoomMessage),
+		ratings:     make(map[ratingKey]*models.UserRating),
+		tournaments: make(map[string]*models.Tournament),
+		now:         time.Now,
+	}
+}
+
+// Users returns the store as a repositories.UserStore.
+func (s *Store) Users() repositories.UserStore {
+	return (*usersStore)(s)
+}
+
+// Rooms returns the store as a repositories.RoomStore.
+func (s *Store) Rooms() repositories.RoomStore {
+	return (*roomsStore)(s)
+}
+
+// Moves returns the store as a repositories.MoveStore.
+func (s *Store) Moves() repositories.MoveStore {
+	return (*movesStore)(s)
+}
+
+// Messages returns the store as a repositories.MessageStore.
+func (s *Store) Messages() repositories.MessageStore {
+	return (*messagesStore)(s)
+}
+
+// Ratings returns the store as a repositories.RatingStore.
+func (s *Store) Ratings() repositories.RatingStore {
+	return (*ratingsStore)(s)
+}
+
+// Tournaments returns the store as a repositories.TournamentStore.
+func (s *Store) Tournaments() repositories.TournamentStore {
+	return (*tournamentsStore)(s)
+}
+
+// TournamentSettings returns the store as a repositories.TournamentSettingsStore.
+func (s *Store) TournamentSettings() repositories.TournamentSettingsStore {
+	return (*tournamentSettingsStore)(s)
+}
+
+// touch records a write of the room: its updated_at and its place in the write order.
+func (s *Store) touch(row *roomRow) {
+	s.seq++
+	row.touched = s.seq
+	row.room.UpdatedAt = s.now()
+}
+
+// cloneRoom copies a room together with the values its pointer fields point to.
+func cloneRoom(r models.Room) models.Room {
+	r.Player2ID = cloneInt64(r.Player2ID)
+	r.WhiteID = cloneInt64(r.WhiteID)
+	r.BlackID = cloneInt64(r.BlackID)
+	r.ChatID = cloneInt64(r.ChatID)
+	r.DrawOfferedBy = cloneInt64(r.DrawOfferedBy)
+	if r.TurnStartedAt != nil {
+		t := *r.TurnStartedAt
+		r.TurnStartedAt = &t
+	}
+	return r
+}
+
+// cloneMove copies a move together with its evaluation.
+func cloneMove(mv models.RoomMove) models.RoomMove {
+	if mv.Eval != nil {
+		eval := *mv.Eval
+		mv.Eval = &eval
+	}
+	return mv
+}
+
+// cloneTournament copies a tournament together with its players.
+func cloneTournament(t models.Tournament) models.Tournament {
+	t.Players = append([]int64(nil), t.Players...)
+	return t
+}
+
+func cloneInt64(p *int64) *int64 {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// equalInt64 compares two nullable IDs the way a UNIQUE index does: NULL never equals anything.
+func equalInt64(a, b *int64) bool {
+	return a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
+
+	"github.com/google/uuid"
+)
+
+// tournamentsStore implements repositories.TournamentStore on a Store.
+type tournamentsStore Store
+
+// CreateTournament stores a new tournament, generating its ID and timestamps when they are empty.
+func (s *tournamentsStore) CreateTournament(ctx context.Context, t *models.Tournament) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = s.now()
+	}
+	if t.UpdatedAt.IsZero() {
+		t.UpdatedAt = s.now()
+	}
+	if _, ok := s.tournaments[t.ID]; ok {
+		return fmt.Errorf("CreateTournament: tournament %s already exists", t.ID)
+	}
+
+	stored := cloneTournament(*t)
+	s.tournaments[t.ID] = &stored
+	return nil
+}
+
+// GetTournamentByID returns the tournament, or an error wrapping repositories.ErrNotFound.
+func (s *tournamentsStore) GetTournamentByID(ctx context.Context, tid string) (*models.Tournament, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tournaments[tid]
+	if !ok {
+		return nil, fmt.Errorf("GetTournamentByID %s: %w", tid, repositories.ErrNotFound)
+	}
+	found := cloneTournament(*t)
+	return &found, nil
+}
+
+// AddPlayer adds the user to the players of the tournament unless already there.
+func (s *tournamentsStore) AddPlayer(ctx context.Context, tid string, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tournaments[tid]
+	if !ok {
+		return fmt.Errorf("GetTournamentByID %s: %w", tid, repositories.ErrNotFound)
+	}
+	for _, p := range t.Players {
+		if p == userID {
+			return nil
+		}
+	}
+	t.Players = append(t.Players, userID)
+	t.UpdatedAt = s.now()
+	return nil
+}
+
+// StartTournament marks the tournament as active, starting now; an unknown tournament is ignored, as by the UPDATE.
+func (s *tournamentsStore) StartTournament(ctx context.Context, tid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tournaments[tid]; ok {
+		t.Status = models.TournamentStatusActive
+		t.StartAt = s.now()
+		t.UpdatedAt = t.StartAt
+	}
+	return nil
+}
+
+// tournamentSettingsStore implements repositories.TournamentSettingsStore on a Store.
+type tournamentSettingsStore Store
+
+// LinkRoomToTournament adds the room to the tournament at the given rank, waiting.
+// Both must exist (fk_tournament, fk_room).
+func (s *tournamentSettingsStore) LinkRoomToTournament(ctx context.Context, tid, rid string, rank int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tournaments[tid]; !ok {
+		return fmt.Errorf("LinkRoomToTournament: t_id %s is not a tournament (fk_tournament)", tid)
+	}
+	if _, ok := s.rooms[rid]; !ok {
+		return fmt.Errorf("LinkRoomToTournament: r_id %s is not a room (fk_room)", rid)
+	}
+	s.settings = append(s.settings, models.TournamentSettings{
+		TID:    tid,
+		RID:    rid,
+		Rank:   rank,
+		Status: models.TSStatusWaiting,
+	})
+	return nil
+}
+
+// UpdateTournamentRoomRank sets the rank and status of the room within the tournament.
+func (s *tournamentSettingsStore) UpdateTournamentRoomRank(
+	ctx context.Context,
+	tid, rid string,
+	newRank int,
+	newStatus int,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.settings {
+		if ts := &s.settings[i]; ts.TID == tid && ts.RID == rid {
+			ts.Rank = newRank
+			ts.Status = newStatus
+		}
+	}
+	return nil
+}
+
+// GetRoomsByTournament returns the rooms of the tournament by rank.
+func (s *tournamentSettingsStore) GetRoomsByTournament(
+	ctx context.Context,
+	tid string,
+) ([]models.TournamentSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []models.TournamentSettings
+	for _, ts := range s.settings {
+		if ts.TID == tid {
+			results = append(results, ts)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank < results[j].Rank })
+	return results, nil
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
+)
+
+// usersStore implements repositories.UserStore on a Store.
+type usersStore Store
+
+// CreateOrUpdateUser inserts the user or, if it exists, updates its profile fields (names, chat ID)
+// and keeps its game statistics, like UsersRepository.CreateOrUpdateUser.
+func (s *usersStore) CreateOrUpdateUser(ctx context.Context, u *models.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.users[u.ID]; ok {
+		existing.Username = u.Username
+		existing.FirstName = u.FirstName
+		existing.ChatID = u.ChatID
+		return nil
+	}
+
+	stored := models.User{
+		ID:         u.ID,
+		Username:   u.Username,
+		FirstName:  u.FirstName,
+		ChatID:     u.ChatID,
+		Rating:     u.Rating,
+		Wins:       u.Wins,
+		TotalGames: u.TotalGames,
+		BoardStyle: models.BoardStyleImage,
+	}
+	if stored.Rating == 0 {
+		stored.Rating = 1000
+	}
+	s.users[u.ID] = &stored
+	return nil
+}
+
+// GetUserByID returns the user, or an error wrapping repositories.ErrNotFound.
+func (s *usersStore) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("GetUserByID %d: %w", id, repositories.ErrNotFound)
+	}
+	found := *u
+	return &found, nil
+}
+
+// SetBoardStyle stores how the board is shown to the user; an unknown user is ignored, as by the UPDATE.
+func (s *usersStore) SetBoardStyle(ctx context.Context, id int64, style string) error {
+	if style != models.BoardStyleImage && style != models.BoardStyleASCII {
+		return fmt.Errorf("SetBoardStyle: unknown style %q", style)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[id]; ok {
+		u.BoardStyle = style
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"lvlchess/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// migrationFiles holds the schema migrations: migrations/NNNN_name.up.sql applies a migration,
+// migrations/NNNN_name.down.sql reverts it. Every migration runs in its own transaction.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFileRe matches the name of a migration file: version, name and direction.
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockID is the key of the advisory lock held while migrating, so that several replicas
+// starting at once do not apply the same migration twice.
+const migrationLockID int64 = 7_431_902_115
+
+// Migration is a numbered schema change with its SQL in both directions.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and when it was applied (nil if it is pending).
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the embedded migrations, sorted by version. Every version must have both an up
+// and a down file.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationFileRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		sql, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(sql)
+		} else {
+			mig.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+/*
+MigrateUp applies all pending migrations in order and returns the ones it applied.
+It holds the advisory lock meanwhile, so a concurrent MigrateUp (another replica) waits and then finds
+nothing left to do.
+*/
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(ctx, pool, func(conn *pgxpool.Conn, done map[int]time.Time, migrations []Migration) error {
+		for _, mig := range migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			insert := `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, NOW())`
+			if err := runMigration(ctx, conn, mig, mig.Up, insert, mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("apply: %w", err)
+			}
+			utils.Logger.Info("Applied migration", zap.Int("version", mig.Version), zap.String("name", mig.Name))
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return applied, fmt.Errorf("MigrateUp: %w", err)
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and returns the ones it reverted.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := withMigrationLock(ctx, pool, func(conn *pgxpool.Conn, done map[int]time.Time, migrations []Migration) error {
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			remove := `DELETE FROM schema_migrations WHERE version = $1`
+			if err := runMigration(ctx, conn, mig, mig.Down, remove, mig.Version); err != nil {
+				return fmt.Errorf("revert: %w", err)
+			}
+			utils.Logger.Info("Reverted migration", zap.Int("version", mig.Version), zap.String("name", mig.Name))
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return reverted, fmt.Errorf("MigrateDown: %w", err)
+	}
+	return reverted, nil
+}
+
+/*
+MigrationStatus lists every known migration with the time it was applied. It only reads: it takes no lock
+and does not create schema_migrations, so on a database that was never migrated every migration is pending.
+*/
+func MigrationStatus(ctx context.Context, pool *pgxpool.Pool) ([]MigrationState, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("MigrationStatus: %w", err)
+	}
+
+	var exists bool
+	if err = pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("MigrationStatus: find schema_migrations: %w", err)
+	}
+	done := make(map[int]time.Time)
+	if exists {
+		if done, err = appliedMigrations(ctx, pool); err != nil {
+			return nil, fmt.Errorf("MigrationStatus: %w", err)
+		}
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, mig := range migrations {
+		state := MigrationState{Migration: mig}
+		if at, ok := done[mig.Version]; ok {
+			state.AppliedAt = &at
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// withMigrationLock takes a connection, holds the advisory lock on it and calls fn with the applied
+// migrations (version -> applied at) and the known ones.
+func withMigrationLock(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	fn func(conn *pgxpool.Conn, done map[int]time.Time, migrations []Migration) error,
+) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// The advisory lock belongs to a session, so everything runs on one connection.
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("advisory lock: %w", err)
+	}
+	defer func() {
+		// Unlock even if ctx is already cancelled, the connection goes back to the pool.
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			utils.Logger.Error("advisory unlock: "+err.Error(), zap.Error(err))
+		}
+	}()
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+	  version    BIGINT PRIMARY KEY,
+	  name       VARCHAR(255) NOT NULL,
+	  applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	`
+	if _, err = conn.Exec(ctx, schema); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	done, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, done, migrations)
+}
+
+// querier is what appliedMigrations reads with: the pool or the connection holding the lock.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// appliedMigrations reads schema_migrations: the version of every applied migration and when it was applied.
+func appliedMigrations(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	done := make(map[int]time.Time)
+	var (
+		version   int
+		appliedAt time.Time
+	)
+	_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		done[version] = appliedAt
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	return done, nil
+}
+
+// runMigration executes the SQL of a migration and the bookkeeping statement in one transaction.
+func runMigration(ctx context.Context, conn *pgxpool.Conn, mig Migration, sql, bookkeeping string, args ...any) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%04d_%s begin: %w", mig.Version, mig.Name, err)
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("%04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err = tx.Exec(ctx, bookkeeping, args...); err != nil {
+		return fmt.Errorf("%04d_%s bookkeeping: %w", mig.Version, mig.Name, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%04d_%s commit: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
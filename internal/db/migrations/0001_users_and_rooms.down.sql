@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_curr_room;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS users;
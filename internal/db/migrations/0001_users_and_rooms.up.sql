@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS users (
+    id          BIGINT UNIQUE,
+    user_name   VARCHAR(255),
+    first_name  VARCHAR(255),
+    chat_id     BIGINT DEFAULT(0),
+    current_room VARCHAR(36) NULL,
+    rating      INT DEFAULT 1000,
+    wins        INT DEFAULT 0,
+    total_games INT DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    room_id       VARCHAR(36) PRIMARY KEY,
+    room_title    TEXT,
+    player1_id    BIGINT NOT NULL,
+    player2_id    BIGINT,
+    status        VARCHAR(20) NOT NULL DEFAULT('waiting'), -- waiting/playing/finished
+    board_state   TEXT,
+    is_white_turn BOOLEAN NOT NULL DEFAULT true,
+    white_id      BIGINT,
+    black_id      BIGINT NULL,
+    chat_id       BIGINT, -- if referencing a group
+    created_at    TIMESTAMP DEFAULT NOW(),
+    updated_at    TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT fk_p1 FOREIGN KEY(player1_id) REFERENCES users(id),
+    CONSTRAINT fk_p2 FOREIGN KEY(player2_id) REFERENCES users(id),
+    CONSTRAINT players_pair UNIQUE (player1_id, player2_id)
+);
+
+-- Databases created before the migrations may already have the constraint.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_curr_room') THEN
+        ALTER TABLE users ADD CONSTRAINT fk_curr_room
+            FOREIGN KEY(current_room) REFERENCES rooms(room_id);
+    END IF;
+END $$;
@@ -0,0 +1,16 @@
+ALTER TABLE rooms DROP COLUMN IF EXISTS bot_level;
+ALTER TABLE rooms DROP COLUMN IF EXISTS draw_offered_by;
+-- Fails if the same players have played each other more than once.
+DROP INDEX IF EXISTS rooms_active_players_pair;
+ALTER TABLE rooms ADD CONSTRAINT players_pair UNIQUE (player1_id, player2_id);
+ALTER TABLE rooms DROP COLUMN IF EXISTS termination;
+ALTER TABLE rooms DROP COLUMN IF EXISTS result;
+ALTER TABLE rooms DROP COLUMN IF EXISTS turn_started_at;
+ALTER TABLE rooms DROP COLUMN IF EXISTS black_time_ms;
+ALTER TABLE rooms DROP COLUMN IF EXISTS white_time_ms;
+ALTER TABLE rooms DROP COLUMN IF EXISTS days_per_move;
+ALTER TABLE rooms DROP COLUMN IF EXISTS clock_increment;
+ALTER TABLE rooms DROP COLUMN IF EXISTS clock_initial;
+ALTER TABLE rooms DROP COLUMN IF EXISTS time_control;
+ALTER TABLE rooms DROP COLUMN IF EXISTS rated;
+ALTER TABLE rooms DROP COLUMN IF EXISTS initial_fen;
@@ -0,0 +1,20 @@
+-- Columns added after the initial rooms schema.
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS initial_fen TEXT NULL; -- custom starting position, NULL = standard
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS rated BOOLEAN NOT NULL DEFAULT true; -- false = casual game
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS time_control    VARCHAR(20) NOT NULL DEFAULT ''; -- '' = no clock
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS clock_initial   INT NOT NULL DEFAULT 0;         -- seconds per side
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS clock_increment INT NOT NULL DEFAULT 0;         -- seconds per move
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS days_per_move   INT NOT NULL DEFAULT 0;         -- correspondence
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS white_time_ms   BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS black_time_ms   BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS turn_started_at TIMESTAMPTZ NULL;               -- NULL = clocks stopped
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS result          VARCHAR(7) NOT NULL DEFAULT '';  -- 1-0, 0-1, 1/2-1/2
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS termination     VARCHAR(30) NOT NULL DEFAULT ''; -- how the game ended
+
+-- The same two players may meet again once their previous game is over:
+-- only unfinished rooms must be unique per pair.
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS players_pair;
+CREATE UNIQUE INDEX IF NOT EXISTS rooms_active_players_pair
+    ON rooms (player1_id, player2_id) WHERE status <> 'finished';
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS draw_offered_by BIGINT NULL;                    -- pending draw offer
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS bot_level INT NOT NULL DEFAULT 0;              -- 0 = no bot opponent
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS room_moves (
+    room_id    VARCHAR(36) NOT NULL,
+    ply        INT NOT NULL,          -- 1-based half-move number within the room
+    uci        VARCHAR(5) NOT NULL,   -- e.g. e2e4, e7e8q
+    san        VARCHAR(16) NOT NULL,  -- e.g. e4, Nxf7+, O-O
+    fen_after  TEXT NOT NULL,
+    mover_id   BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT pk_room_moves PRIMARY KEY (room_id, ply),
+    CONSTRAINT fk_move_room  FOREIGN KEY (room_id) REFERENCES rooms(room_id) ON DELETE CASCADE,
+    CONSTRAINT fk_move_user  FOREIGN KEY (mover_id) REFERENCES users(id)
+);
+
+-- Post-game analysis, filled in once the game is over (NULL eval = not analysed yet)
+ALTER TABLE room_moves ADD COLUMN IF NOT EXISTS eval     INT;                 -- engine score after the move, White's POV
+ALTER TABLE room_moves ADD COLUMN IF NOT EXISTS cp_loss  INT NOT NULL DEFAULT 0;
+ALTER TABLE room_moves ADD COLUMN IF NOT EXISTS accuracy DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE room_moves ADD COLUMN IF NOT EXISTS judgment VARCHAR(16) NOT NULL DEFAULT ''; -- inaccuracy / mistake / blunder
+ALTER TABLE room_moves ADD COLUMN IF NOT EXISTS best_san VARCHAR(16) NOT NULL DEFAULT '';
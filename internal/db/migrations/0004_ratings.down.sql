@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rating_history;
+DROP TABLE IF EXISTS user_ratings;
+ALTER TABLE users ALTER COLUMN rating TYPE INT USING round(rating);
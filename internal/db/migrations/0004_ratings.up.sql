@@ -0,0 +1,57 @@
+-- Glicko-2 ratings, one pool per (user, category): bullet/blitz/rapid/classical/correspondence
+-- or a variant name. Column defaults match the rating package defaults.
+-- users.rating is kept as the legacy overall rating.
+ALTER TABLE users ALTER COLUMN rating TYPE DOUBLE PRECISION;
+
+CREATE TABLE IF NOT EXISTS user_ratings (
+    user_id    BIGINT NOT NULL,
+    category   VARCHAR(30) NOT NULL,
+    rating     DOUBLE PRECISION NOT NULL DEFAULT 1000,
+    rd         DOUBLE PRECISION NOT NULL DEFAULT 350,
+    volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06,
+    games      INT NOT NULL DEFAULT 0,  -- rated games in this category; provisional below models.ProvisionalGames
+    updated_at TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT pk_user_ratings PRIMARY KEY (user_id, category),
+    CONSTRAINT fk_user_rating_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS user_ratings_leaderboard_idx ON user_ratings (category, rating DESC);
+
+CREATE TABLE IF NOT EXISTS rating_history (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          BIGINT NOT NULL,
+    room_id          VARCHAR(36) NOT NULL,
+    rating_before    DOUBLE PRECISION NOT NULL,
+    rating_after     DOUBLE PRECISION NOT NULL,
+    rd_before        DOUBLE PRECISION NOT NULL,
+    rd_after         DOUBLE PRECISION NOT NULL,
+    volatility_after DOUBLE PRECISION NOT NULL,
+    created_at       TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT fk_rating_user FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT fk_rating_room FOREIGN KEY (room_id) REFERENCES rooms(room_id) ON DELETE CASCADE
+);
+ALTER TABLE rating_history ADD COLUMN IF NOT EXISTS category VARCHAR(30) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS rating_history_user_idx ON rating_history (user_id, created_at);
+
+-- Databases that ran the first Glicko-2 version keep one overall pool in users.rating, rating_rd and
+-- rating_volatility. Carry it over into the categories the user has rated games in (the category of a game
+-- follows from its room, as in models.RatingCategoryFor), with the number of games from rating_history,
+-- so that nobody is reset to 1000/350. The old columns are no longer read.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'rating_rd') THEN
+        UPDATE rating_history h
+           SET category = CASE WHEN r.time_control = '' THEN 'correspondence' ELSE r.time_control END
+          FROM rooms r
+         WHERE r.room_id = h.room_id
+           AND h.category = '';
+
+        INSERT INTO user_ratings (user_id, category, rating, rd, volatility, games, updated_at)
+        SELECT u.id, h.category, u.rating, u.rating_rd, u.rating_volatility, COUNT(*), MAX(h.created_at)
+          FROM users u
+          JOIN rating_history h ON h.user_id = u.id
+         WHERE h.category <> ''
+         GROUP BY u.id, h.category, u.rating, u.rating_rd, u.rating_volatility
+        ON CONFLICT (user_id, category) DO NOTHING;
+    END IF;
+END $$;
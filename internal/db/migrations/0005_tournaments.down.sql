@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tournament_settings;
+DROP TABLE IF EXISTS tournaments;
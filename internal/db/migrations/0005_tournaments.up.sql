@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS tournaments (
+    id          VARCHAR(36) PRIMARY KEY,
+    title       VARCHAR(255),
+    prise       TEXT,
+    players     JSONB,               -- array of user IDs in JSON
+    status      INT DEFAULT 0,       -- 0=planned,1=active,2=finished
+    start_at    TIMESTAMP DEFAULT NOW(),
+    created_at  TIMESTAMP DEFAULT NOW(),
+    updated_at  TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS tournament_settings (
+    t_id   VARCHAR(36) NOT NULL,
+    r_id   VARCHAR(36) NOT NULL,
+    rank   INT DEFAULT 0,
+    status INT DEFAULT 0,
+    CONSTRAINT fk_tournament FOREIGN KEY (t_id) REFERENCES tournaments(id),
+    CONSTRAINT fk_room       FOREIGN KEY (r_id) REFERENCES rooms(room_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS board_style VARCHAR(10) NOT NULL DEFAULT 'image'; -- image / ascii
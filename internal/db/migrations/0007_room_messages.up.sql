@@ -0,0 +1,11 @@
+-- The live board and keyboard messages of each room, edited in place on every move.
+CREATE TABLE IF NOT EXISTS room_messages (
+    room_id    VARCHAR(36) NOT NULL,
+    chat_id    BIGINT NOT NULL,
+    kind       VARCHAR(10) NOT NULL,  -- board / keyboard
+    message_id INT NOT NULL,
+    is_photo   BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT pk_room_messages PRIMARY KEY (room_id, chat_id, kind),
+    CONSTRAINT fk_message_room  FOREIGN KEY (room_id) REFERENCES rooms(room_id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- Optimistic locking: every update of a room bumps the version, UpdateRoom only writes the version it has read.
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
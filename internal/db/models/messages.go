@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Kinds of the live messages of a room, see RoomMessage.
+const (
+	RoomMessageBoard    = "board"    // the board picture or ASCII board
+	RoomMessageKeyboard = "keyboard" // the move keyboard
+)
+
+// RoomMessage corresponds to a row of the "room_messages" table: the Telegram message that shows
+// the board or the move keyboard of a room in one chat. Instead of sending new messages on every move,
+// the bot edits these in place.
+type RoomMessage struct {
+	RoomID    string    `json:"room_id"`
+	ChatID    int64     `json:"chat_id"`    // Group chat of the room or private chat of a player
+	Kind      string    `json:"kind"`       // RoomMessageBoard or RoomMessageKeyboard
+	MessageID int       `json:"message_id"` // Telegram message ID within the chat
+	IsPhoto   bool      `json:"is_photo"`   // The board is a picture (edited with editMessageMedia) rather than text
+	UpdatedAt time.Time `json:"updated_at"`
+}
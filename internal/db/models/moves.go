@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// RoomMove corresponds to a single half-move (ply) stored in the "room_moves" table.
+// Together, the moves of a room form the full game history, which the "rooms" table alone
+// cannot keep (it only holds the latest FEN in board_state).
+type RoomMove struct {
+	RoomID    string    `json:"room_id"`   // The room this move belongs to
+	Ply       int       `json:"ply"`       // 1-based half-move number within the room
+	UCI       string    `json:"uci"`       // Move in UCI notation, e.g. "e2e4" or "e7e8q"
+	SAN       string    `json:"san"`       // Move in Standard Algebraic Notation, e.g. "e4", "Nxf7+"
+	FENAfter  string    `json:"fen_after"` // FEN of the position after this move
+	MoverID   int64     `json:"mover_id"`  // Telegram user ID of the player who made the move
+	CreatedAt time.Time `json:"created_at"`
+
+	// Post-game analysis (see the analysis package); Eval is nil until the game has been analysed.
+	Eval     *int    `json:"eval,omitempty"`     // Engine score after the move, White's point of view
+	CPLoss   int     `json:"cp_loss"`            // Centipawns lost compared with the engine's choice
+	Accuracy float64 `json:"accuracy"`           // Move accuracy, 0..100
+	Judgment string  `json:"judgment,omitempty"` // "inaccuracy", "mistake", "blunder" or empty
+	BestSAN  string  `json:"best_san,omitempty"` // Engine's preferred move, if it differs from the played one
+}
+
+// Validate ensures the move has the fields we need to replay it later.
+func (m *RoomMove) Validate() error {
+	return validation.ValidateStruct(m,
+		validation.Field(&m.RoomID, validation.Required),
+		validation.Field(&m.UCI, validation.Required, validation.Length(4, 5)),
+		validation.Field(&m.SAN, validation.Required),
+		validation.Field(&m.FENAfter, validation.Required),
+		validation.Field(&m.MoverID, validation.Required),
+	)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+)
+
+// Rating categories: every player has a separate rating pool per category. Real-time categories match
+// the TimeControl* values of the room; games without a clock are rated as correspondence (no time pressure).
+// A chess variant, once supported, is rated in a pool named after the variant instead.
+const (
+	RatingCategoryBullet         = TimeControlBullet
+	RatingCategoryBlitz          = TimeControlBlitz
+	RatingCategoryRapid          = TimeControlRapid
+	RatingCategoryClassical      = TimeControlClassical
+	RatingCategoryCorrespondence = TimeControlCorrespondence
+)
+
+// RatingCategories lists the rating pools in the order they are shown to users.
+var RatingCategories = []string{
+	RatingCategoryBullet,
+	RatingCategoryBlitz,
+	RatingCategoryRapid,
+	RatingCategoryClassical,
+	RatingCategoryCorrespondence,
+}
+
+// ProvisionalGames is the number of rated games in a category after which a rating stops being provisional.
+const ProvisionalGames = 10
+
+// RatingCategoryFor returns the rating pool a room's game belongs to.
+func RatingCategoryFor(room *Room) string {
+	if room.TimeControl == TimeControlNone {
+		return RatingCategoryCorrespondence
+	}
+	return room.TimeControl
+}
+
+// UserRating corresponds to one row of the "user_ratings" table: the Glicko-2 state
+// of a user in one rating category.
+type UserRating struct {
+	UserID     int64     `json:"user_id"`
+	Category   string    `json:"category"`
+	Rating     float64   `json:"rating"`
+	RD         float64   `json:"rd"`
+	Volatility float64   `json:"volatility"`
+	Games      int       `json:"games"` // Rated games played in this category
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// IsProvisional reports whether too few games have been played for the rating to be reliable.
+func (r *UserRating) IsProvisional() bool {
+	return r.Games < ProvisionalGames
+}
+
+// RatingChange corresponds to one row of the "rating_history" table:
+// how a rated game changed the rating of one of its players.
+type RatingChange struct {
+	ID              int64     `json:"id"`
+	UserID          int64     `json:"user_id"`
+	RoomID          string    `json:"room_id"`
+	Category        string    `json:"category"`
+	RatingBefore    float64   `json:"rating_before"`
+	RatingAfter     float64   `json:"rating_after"`
+	RDBefore        float64   `json:"rd_before"`
+	RDAfter         float64   `json:"rd_after"`
+	VolatilityAfter float64   `json:"volatility_after"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Delta returns the rating gained (positive) or lost (negative) in the game.
+func (c *RatingChange) Delta() float64 {
+	return c.RatingAfter - c.RatingBefore
+}
@@ -11,23 +11,84 @@ import (
 const (
 	RoomStatusWaiting  = "waiting"  // A room is waiting for a second player
 	RoomStatusPlaying  = "playing"  // A room has two players; game is ongoing
-	RoomStatusFinished = "finished" // A room has ended (checkmate or draw)
+	RoomStatusFinished = "finished" // A room has ended; see Room.Result and Room.Termination
+)
+
+// Bot levels a room against the engine can be played at; the engine maps each one to a playing strength.
+const (
+	MinBotLevel = 1 // The weakest bot
+	MaxBotLevel = 8 // The engine at full strength
+)
+
+// Time control categories. An empty TimeControl means the room is played without a clock.
+const (
+	TimeControlNone           = ""               // No clock at all
+	TimeControlBullet         = "bullet"         // Estimated game duration < 3 minutes
+	TimeControlBlitz          = "blitz"          // < 8 minutes
+	TimeControlRapid          = "rapid"          // < 25 minutes
+	TimeControlClassical      = "classical"      // 25 minutes and more
+	TimeControlCorrespondence = "correspondence" // A fixed number of days per move
+)
+
+// Game results, in PGN notation. Empty while the game is in progress (and for aborted games).
+const (
+	ResultNone     = ""
+	ResultWhiteWon = "1-0"
+	ResultBlackWon = "0-1"
+	ResultDraw     = "1/2-1/2"
+)
+
+// Termination methods: how a finished game has ended. Empty while the game is in progress.
+const (
+	TerminationNone                 = ""
+	TerminationCheckmate            = "checkmate"
+	TerminationStalemate            = "stalemate"
+	TerminationThreefoldRepetition  = "threefold_repetition" // Also used for the automatic fivefold repetition
+	TerminationFiftyMoveRule        = "fifty_move_rule"      // Also used for the automatic 75-move rule
+	TerminationInsufficientMaterial = "insufficient_material"
+	TerminationResignation          = "resignation" // One of the players resigned
+	TerminationAgreement            = "agreement"   // A draw offer was accepted
+	TerminationAborted              = "aborted"     // The game was aborted within the first two plies
+	TerminationTimeout              = "timeout"     // A flag fell (loss on time or draw vs insufficient material)
 )
 
 // Room represents a single chess "room" or match session between players.
 type Room struct {
-	RoomID      string    `json:"room_id"`       // Unique identifier (UUID)
-	RoomTitle   string    `json:"room_title"`    // Title/nickname of the room
-	Player1ID   int64     `json:"player_1"`      // Telegram user ID of the first player
-	Player2ID   *int64    `json:"player_2"`      // Telegram user ID of the second player, nil if not joined
-	Status      string    `json:"status"`        // One of RoomStatusWaiting|RoomStatusPlaying|RoomStatusFinished
-	BoardState  string    `json:"board_state"`   // FEN string representing current board position
-	IsWhiteTurn bool      `json:"is_white_turn"` // Whose turn it is; 'true' means White's turn
-	WhiteID     *int64    `json:"white_id"`      // Which player is assigned the White pieces
-	BlackID     *int64    `json:"black_id"`      // Which player is assigned the Black pieces
-	ChatID      *int64    `json:"chat_id"`       // Group chat ID if this room is associated with a Telegram group
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	RoomID      string `json:"room_id"`       // Unique identifier (UUID)
+	RoomTitle   string `json:"room_title"`    // Title/nickname of the room
+	Player1ID   int64  `json:"player_1"`      // Telegram user ID of the first player
+	Player2ID   *int64 `json:"player_2"`      // Telegram user ID of the second player, nil if not joined
+	Status      string `json:"status"`        // One of RoomStatusWaiting|RoomStatusPlaying|RoomStatusFinished
+	BoardState  string `json:"board_state"`   // FEN string representing current board position
+	IsWhiteTurn bool   `json:"is_white_turn"` // Whose turn it is; 'true' means White's turn
+	WhiteID     *int64 `json:"white_id"`      // Which player is assigned the White pieces
+	BlackID     *int64 `json:"black_id"`      // Which player is assigned the Black pieces
+	ChatID      *int64 `json:"chat_id"`       // Group chat ID if this room is associated with a Telegram group
+	InitialFEN  string `json:"initial_fen"`   // Custom starting position (FEN); empty for the standard initial position
+	Rated       bool   `json:"rated"`         // Rated games change the players' ratings, casual ones do not
+	BotLevel    int    `json:"bot_level"`     // Strength of the bot opponent (MinBotLevel..MaxBotLevel); 0 if both players are humans
+
+	TimeControl    string     `json:"time_control"`    // One of the TimeControl* categories; empty = no clock
+	ClockInitial   int        `json:"clock_initial"`   // Seconds per side at the start (real-time controls)
+	ClockIncrement int        `json:"clock_increment"` // Seconds added to the mover's clock after each move
+	DaysPerMove    int        `json:"days_per_move"`   // Correspondence: days allowed for each move
+	WhiteTimeMs    int64      `json:"white_time_ms"`   // Remaining time of White, as of TurnStartedAt
+	BlackTimeMs    int64      `json:"black_time_ms"`   // Remaining time of Black, as of TurnStartedAt
+	TurnStartedAt  *time.Time `json:"turn_started_at"` // When the side to move started thinking; nil while clocks are stopped
+
+	Result        string `json:"result"`          // One of the Result* values; empty while the game is in progress
+	Termination   string `json:"termination"`     // One of the Termination* methods; empty while the game is in progress
+	DrawOfferedBy *int64 `json:"draw_offered_by"` // Player with a pending draw offer, nil if there is none
+
+	Version int64 `json:"version"` // Bumped on every update of the row; UpdateRoom only writes the version it has read
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HasClock reports whether the room is played with a time control.
+func (u *Room) HasClock() bool {
+	return u.TimeControl != TimeControlNone
 }
 
 // Validate checks basic constraints, e.g., non-empty RoomID, valid status, etc.
@@ -39,6 +100,16 @@ func (u *Room) Validate() error {
 		validation.Field(&u.Status, validation.Required,
 			validation.In(RoomStatusWaiting, RoomStatusPlaying, RoomStatusFinished)),
 		validation.Field(&u.BoardState, validation.Required),
+		validation.Field(&u.BotLevel, validation.Min(0), validation.Max(MaxBotLevel)),
+		validation.Field(&u.TimeControl,
+			validation.In(TimeControlBullet, TimeControlBlitz, TimeControlRapid,
+				TimeControlClassical, TimeControlCorrespondence)),
+		validation.Field(&u.Result,
+			validation.In(ResultWhiteWon, ResultBlackWon, ResultDraw)),
+		validation.Field(&u.Termination,
+			validation.In(TerminationCheckmate, TerminationStalemate, TerminationThreefoldRepetition,
+				TerminationFiftyMoveRule, TerminationInsufficientMaterial, TerminationResignation,
+				TerminationAgreement, TerminationAborted, TerminationTimeout)),
 	)
 }
 
@@ -52,5 +123,24 @@ func PrepareNewRoom(p1ID int64, title string) *Room {
 		Status:      RoomStatusWaiting,
 		BoardState:  chess.NewGame().FEN(),
 		IsWhiteTurn: true, // Typically starts with White
+		Rated:       true,
 	}
 }
+
+// PrepareNewRoomFromFEN builds a new Room that starts from a custom position.
+// The FEN is expected to be validated beforehand (see game.ValidateFEN); the side to move
+// encoded in it drives IsWhiteTurn, and castling/en-passant rights are kept as-is in BoardState.
+func PrepareNewRoomFromFEN(p1ID int64, title, fen string) (*Room, error) {
+	fenOption, err := chess.FEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	pos := chess.NewGame(fenOption).Position()
+
+	room := PrepareNewRoom(p1ID, title)
+	room.BoardState = fen
+	room.InitialFEN = fen
+	room.IsWhiteTurn = pos.Turn() == chess.White
+	room.Rated = false // Games from arbitrary positions do not count for the rating
+	return room, nil
+}
@@ -7,16 +7,23 @@ import (
 // UnregisteredPrivateChat = 0, used if a user doesn't have a personal chat ID assigned, or is unknown
 const UnregisteredPrivateChat = 0
 
+// Board styles: how the bot shows the board to the user.
+const (
+	BoardStyleImage = "image" // PNG picture (default)
+	BoardStyleASCII = "ascii" // Monospace Unicode text
+)
+
 // User corresponds to the table "users" in the DB, storing basic info about each Telegram user.
 type User struct {
-	ID          int64  `json:"id"`           // Telegram user ID
-	Username    string `json:"username"`     // e.g., @katalvlaran
-	FirstName   string `json:"firstName"`    // If needed for display
-	ChatID      int64  `json:"chatID"`       // A personal or private chat ID with the bot
-	CurrentRoom *Room  `json:"current_room"` // Possibly unused. If needed, references the user's current room
-	Rating      int    `json:"rating"`       // optional
-	Wins        int    `json:"wins"`         // optional
-	TotalGames  int    `json:"totalGames"`   // optional
+	ID          int64   `json:"id"`           // Telegram user ID
+	Username    string  `json:"username"`     // e.g., @katalvlaran
+	FirstName   string  `json:"firstName"`    // If needed for display
+	ChatID      int64   `json:"chatID"`       // A personal or private chat ID with the bot
+	CurrentRoom *Room   `json:"current_room"` // Possibly unused. If needed, references the user's current room
+	Rating      float64 `json:"rating"`       // Legacy overall rating; per-category ratings live in UserRating
+	Wins        int     `json:"wins"`         // optional
+	TotalGames  int     `json:"totalGames"`   // optional
+	BoardStyle  string  `json:"boardStyle"`   // BoardStyleImage or BoardStyleASCII
 }
 
 // Validate ensures the user has an ID, username, etc.
@@ -17,20 +17,37 @@ var (
 	Pool                   *pgxpool.Pool
 	usersRepo              *repositories.UsersRepository
 	roomsRepo              *repositories.RoomsRepository
+	movesRepo              *repositories.MovesRepository
+	ratingsRepo            *repositories.RatingsRepository
+	messagesRepo           *repositories.MessagesRepository
 	tournamentsRepo        *repositories.TournamentRepository
 	tournamentSettingsRepo *repositories.TournamentSettingsRepository
 )
 
 /*
 InitDB handles:
+ 1. Connecting to PostgreSQL and setting up the repositories (see Connect)
+ 2. Applying the pending schema migrations (see MigrateUp)
+*/
+func InitDB() {
+	Connect()
+
+	applied, err := MigrateUp(context.Background(), Pool)
+	if err != nil {
+		utils.Logger.Fatal("Failed to migrate the database: "+err.Error(), zap.Error(err))
+	}
+	utils.Logger.Info(fmt.Sprintf("Database schema is up to date, %d migration(s) applied", len(applied)))
+}
+
+/*
+Connect handles:
  1. Reading config (PgUser, PgPass, etc.) from config.Cfg
  2. Constructing the DSN (postgres://...)
  3. Creating the pgxpool connection
  4. Attempting a Ping() to confirm connectivity
  5. Setting up global repository objects (e.g. usersRepo, roomsRepo)
- 6. Optionally calling initSchema() to create DB tables if they don't exist
 */
-func InitDB() {
+func Connect() {
 	// Build DSN from environment
 	dsn := fmt.Sprintf(
 		"postgres://%s:%s@%s%s/%s",
@@ -65,11 +82,18 @@ func InitDB() {
 	// Initialize repository instances
 	usersRepo = repositories.NewUsersRepository(Pool)
 	roomsRepo = repositories.NewRoomsRepository(Pool)
+	movesRepo = repositories.NewMovesRepository(Pool)
+	ratingsRepo = repositories.NewRatingsRepository(Pool)
+	messagesRepo = repositories.NewMessagesRepository(Pool)
 	tournamentsRepo = repositories.NewTournamentRepository(Pool)
 	tournamentSettingsRepo = repositories.NewTournamentSettingsRepository(Pool)
+}
 
-	// Run a basic schema creation script
-	initSchema()
+// Close closes the connection pool. The repositories must not be used afterwards.
+func Close() {
+	if Pool != nil {
+		Pool.Close()
+	}
 }
 
 // GetUsersRepo returns the global UsersRepository singleton
@@ -82,6 +106,21 @@ func GetRoomsRepo() *repositories.RoomsRepository {
 	return roomsRepo
 }
 
+// GetMovesRepo returns the global MovesRepository singleton
+func GetMovesRepo() *repositories.MovesRepository {
+	return movesRepo
+}
+
+// GetRatingsRepo returns the global RatingsRepository singleton
+func GetRatingsRepo() *repositories.RatingsRepository {
+	return ratingsRepo
+}
+
+// GetMessagesRepo returns the global MessagesRepository singleton
+func GetMessagesRepo() *repositories.MessagesRepository {
+	return messagesRepo
+}
+
 // GetTournamentsRepo returns the global TournamentRepository singleton
 func GetTournamentsRepo() *repositories.TournamentRepository {
 	return tournamentsRepo
@@ -91,83 +130,3 @@ func GetTournamentsRepo() *repositories.TournamentRepository {
 func GetTournamentSettingsRepo() *repositories.TournamentSettingsRepository {
 	return tournamentSettingsRepo
 }
-
-/*
-initSchema():
-
-	Simple "migration" approach.
-	It creates required tables if they do not exist.
-	You could also integrate a more robust migration tool (like goose or migrate).
-*/
-func initSchema() {
-	schemaUsers := `
-	CREATE TABLE IF NOT EXISTS users (
-		id        BIGINT UNIQUE,
-		user_name  VARCHAR(255),
-		first_name VARCHAR(255),
-		chat_id   BIGINT DEFAULT(0),
-	    current_room VARCHAR(36) NULL,
-		rating    INT DEFAULT 1000,
-		wins      INT DEFAULT 0,
-		total_games INT DEFAULT 0
-	);`
-	if _, err := Pool.Exec(context.Background(), schemaUsers); err != nil {
-		utils.Logger.Error("Error creating users table", zap.Error(err))
-	}
-
-	schemaRooms := `
-	CREATE TABLE IF NOT EXISTS rooms (
-		 room_id       VARCHAR(36) PRIMARY KEY,
-		 room_title    TEXT,
-		 player1_id    BIGINT NOT NULL,
-		 player2_id    BIGINT,
-		 status        VARCHAR(20) NOT NULL DEFAULT('waiting'), -- waiting/playing/finished
-		 board_state   TEXT,
-		 is_white_turn BOOLEAN NOT NULL DEFAULT true,
-		 white_id      BIGINT,
-		 black_id      BIGINT NULL,
-		 chat_id       BIGINT, -- if referencing a group
-		 created_at    TIMESTAMP DEFAULT NOW(),
-		 updated_at    TIMESTAMP DEFAULT NOW(),
-		 CONSTRAINT fk_p1 FOREIGN KEY(player1_id) REFERENCES users(id),
-		 CONSTRAINT fk_p2 FOREIGN KEY(player2_id) REFERENCES users(id),
-		 CONSTRAINT players_pair UNIQUE (player1_id, player2_id)
-	 );
-
-	ALTER TABLE users ADD CONSTRAINT fk_curr_room 
-	    FOREIGN KEY(current_room) REFERENCES rooms(room_id);
-	`
-	if _, err := Pool.Exec(context.Background(), schemaRooms); err != nil {
-		utils.Logger.Error("Error creating rooms table", zap.Error(err))
-	}
-
-	schemaTournaments := `
-	CREATE TABLE IF NOT EXISTS tournaments (
-	  id          VARCHAR(36) PRIMARY KEY,
-	  title       VARCHAR(255),
-	  prise       TEXT,
-	  players     JSONB,   -- array of user IDs in JSON
-	  status      INT DEFAULT 0,       -- 0=planned,1=active,2=finished
-	  start_at    TIMESTAMP DEFAULT NOW(),
-	  created_at  TIMESTAMP DEFAULT NOW(),
-	  updated_at  TIMESTAMP DEFAULT NOW()
-	);
-	`
-	if _, err := Pool.Exec(context.Background(), schemaTournaments); err != nil {
-		utils.Logger.Error("Error creating tournaments table", zap.Error(err))
-	}
-
-	schemaTournamentSettings := `
-	CREATE TABLE IF NOT EXISTS tournament_settings (
-	  t_id   VARCHAR(36) NOT NULL,
-	  r_id   VARCHAR(36) NOT NULL,
-	  rank   INT DEFAULT 0,
-	  status INT DEFAULT 0,
-	  CONSTRAINT fk_tournament FOREIGN KEY (t_id) REFERENCES tournaments(id),
-	  CONSTRAINT fk_room       FOREIGN KEY (r_id) REFERENCES rooms(room_id)
-	);
-	`
-	if _, err := Pool.Exec(context.Background(), schemaTournamentSettings); err != nil {
-		utils.Logger.Error("Error creating tournament_settings table", zap.Error(err))
-	}
-}
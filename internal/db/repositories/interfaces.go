@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"lvlchess/internal/db/models"
+
+	"github.com/notnil/chess"
+)
+
+// ErrNotFound is wrapped by the lookups of a single record (GetUserByID, GetRoomByID, ...) when there is none.
+var ErrNotFound = errors.New("not found")
+
+/*
+UserStore is what the bot needs from the users storage. It is implemented by UsersRepository (PostgreSQL)
+and by the in-memory store of package memory.
+*/
+type UserStore interface {
+	CreateOrUpdateUser(ctx context.Context, u *models.User) error
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	SetBoardStyle(ctx context.Context, id int64, style string) error
+}
+
+/*
+RoomStore is what the bot needs from the rooms storage. It is implemented by RoomsRepository (PostgreSQL)
+and by the in-memory store of package memory. Implementations share the semantics of RoomsRepository:
+  - CreateRoom fails with ErrUniqueViolation if the room ID is taken or the pair of players already has
+    an unfinished room; UpdateRoom fails the same way when it would create such a pair;
+  - the Get*ByID/ByChatID/ByPlayerIDs lookups wrap ErrNotFound if there is no such room;
+  - UpdateRoom and UpdateRoomWithMove wrap ErrRoomVersionConflict if the room changed since it was read.
+*/
+type RoomStore interface {
+	CreateRoom(ctx context.Context, room *models.Room) error
+	GetRoomByID(ctx context.Context, roomID string) (*models.Room, error)
+	GetRoomByChatID(ctx context.Context, chatID int64) (*models.Room, error)
+	GetRoomByPlayerIDs(ctx context.Context, p1ID, p2ID int64) (*models.Room, error)
+	UpdateRoom(ctx context.Context, room *models.Room) error
+	UpdateRoomWithMove(ctx context.Context, room *models.Room, mv *models.RoomMove) error
+	GetPlayingRoomsForUser(ctx context.Context, userID int64) ([]models.Room, error)
+	GetFinishedRoomsForUser(ctx context.Context, userID int64, limit int) ([]models.Room, error)
+	GetRoomsWithRunningClock(ctx context.Context) ([]models.Room, error)
+	FinishRoom(ctx context.Context, room *models.Room) error
+}
+
+// TournamentStore is what the bot needs from the tournaments storage; GetTournamentByID and AddPlayer
+// wrap ErrNotFound for an unknown tournament.
+type TournamentStore interface {
+	CreateTournament(ctx context.Context, t *models.Tournament) error
+	GetTournamentByID(ctx context.Context, tid string) (*models.Tournament, error)
+	AddPlayer(ctx context.Context, tid string, userID int64) error
+	StartTournament(ctx context.Context, tid string) error
+}
+
+// TournamentSettingsStore is what the bot needs from the storage of the tournament rooms.
+type TournamentSettingsStore interface {
+	LinkRoomToTournament(ctx context.Context, tid, rid string, rank int) error
+	UpdateTournamentRoomRank(ctx context.Context, tid, rid string, newRank int, newStatus int) error
+	GetRoomsByTournament(ctx context.Context, tid string) ([]models.TournamentSettings, error)
+}
+
+/*
+MoveStore is what the bot needs from the move history of the rooms. It is implemented by MovesRepository
+(PostgreSQL) and by the in-memory store of package memory; in both the moves are written by
+RoomStore.UpdateRoomWithMove. GetLastMove returns nil if no move has been played yet.
+*/
+type MoveStore interface {
+	GetMovesByRoomID(ctx context.Context, roomID string) ([]models.RoomMove, error)
+	SaveAnalysis(ctx context.Context, moves []models.RoomMove) error
+	GetLastMove(ctx context.Context, roomID string) (*models.RoomMove, error)
+	CountMoves(ctx context.Context, roomID string) (int, error)
+	ReplayGame(ctx context.Context, roomID, startFEN string) (*chess.Game, error)
+}
+
+// MessageStore is what the bot needs from the storage of the live board and keyboard messages;
+// GetRoomMessage returns nil if there is no such message.
+type MessageStore interface {
+	GetRoomMessage(ctx context.Context, roomID string, chatID int64, kind string) (*models.RoomMessage, error)
+	GetRoomMessages(ctx context.Context, roomID string, kind string) ([]models.RoomMessage, error)
+	SaveRoomMessage(ctx context.Context, m *models.RoomMessage) error
+	DeleteRoomMessages(ctx context.Context, roomID string, chatID int64) error
+}
+
+// RatingStore is what the bot needs from the ratings storage. The ratings are written by RoomStore
+// when a rated room finishes; GetUserRating returns the default rating for a category not played yet.
+type RatingStore interface {
+	GetUserRating(ctx context.Context, userID int64, category string) (*models.UserRating, error)
+	GetLeaderboard(ctx context.Context, category string, limit int) ([]models.UserRating, error)
+	GetRatingChangesByRoomID(ctx context.Context, roomID string) ([]models.RatingChange, error)
+}
+
+// The PostgreSQL repositories implement the interfaces.
+var (
+	_ UserStore               = (*UsersRepository)(nil)
+	_ RoomStore               = (*RoomsRepository)(nil)
+	_ MoveStore               = (*MovesRepository)(nil)
+	_ MessageStore            = (*MessagesRepository)(nil)
+	_ RatingStore             = (*RatingsRepository)(nil)
+	_ TournamentStore         = (*TournamentRepository)(nil)
+	_ TournamentSettingsStore = (*TournamentSettingsRepository)(nil)
+)
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lvlchess/internal/db/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+MessagesRepository provides access to the "room_messages" table: the IDs of the Telegram
+messages showing the board and the move keyboard of each room, per chat, so that they can be
+edited in place.
+*/
+type MessagesRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewMessagesRepository constructs a MessagesRepository given a pgxpool.
+func NewMessagesRepository(pool *pgxpool.Pool) *MessagesRepository {
+	return &MessagesRepository{pool: pool}
+}
+
+/*
+GetRoomMessage returns the live message of the given kind of a room in a chat,
+or nil if there is none yet.
+*/
+func (r *MessagesRepository) GetRoomMessage(ctx context.Context, roomID string, chatID int64, kind string) (*models.RoomMessage, error) {
+	sql := `
+SELECT room_id, chat_id, kind, message_id, is_photo, updated_at
+FROM room_messages
+WHERE room_id = $1 AND chat_id = $2 AND kind = $3
+`
+	var m models.RoomMessage
+	err := r.pool.QueryRow(ctx, sql, roomID, chatID, kind).Scan(
+		&m.RoomID,
+		&m.ChatID,
+		&m.Kind,
+		&m.MessageID,
+		&m.IsPhoto,
+		&m.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetRoomMessage: %w", err)
+	}
+	return &m, nil
+}
+
+/*
+GetRoomMessages returns every live message of the given kind of a room, in all chats.
+*/
+func (r *MessagesRepository) GetRoomMessages(ctx context.Context, roomID string, kind string) ([]models.RoomMessage, error) {
+	sql := `
+SELECT room_id, chat_id, kind, message_id, is_photo, updated_at
+FROM room_messages
+WHERE room_id = $1 AND kind = $2
+`
+	rows, err := r.pool.Query(ctx, sql, roomID, kind)
+	if err != nil {
+		return nil, fmt.Errorf("GetRoomMessages: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.RoomMessage
+	for rows.Next() {
+		var m models.RoomMessage
+		if err = rows.Scan(&m.RoomID, &m.ChatID, &m.Kind, &m.MessageID, &m.IsPhoto, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("GetRoomMessages scan: %w", err)
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
+
+/*
+SaveRoomMessage stores the live message of a room in a chat, replacing the previous one of the same kind.
+*/
+func (r *MessagesRepository) SaveRoomMessage(ctx context.Context, m *models.RoomMessage) error {
+	sql := `
+INSERT INTO room_messages (room_id, chat_id, kind, message_id, is_photo, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (room_id, chat_id, kind)
+DO UPDATE SET message_id = EXCLUDED.message_id, is_photo = EXCLUDED.is_photo, updated_at = NOW()
+`
+	if _, err := r.pool.Exec(ctx, sql, m.RoomID, m.ChatID, m.Kind, m.MessageID, m.IsPhoto); err != nil {
+		return fmt.Errorf("SaveRoomMessage: %w", err)
+	}
+	return nil
+}
+
+/*
+DeleteRoomMessages forgets the live messages of a room in a chat, so that the next board and keyboard
+are sent as new messages (e.g. when a player comes back to the game and the old ones are far up the chat).
+*/
+func (r *MessagesRepository) DeleteRoomMessages(ctx context.Context, roomID string, chatID int64) error {
+	sql := `DELETE FROM room_messages WHERE room_id = $1 AND chat_id = $2`
+	if _, err := r.pool.Exec(ctx, sql, roomID, chatID); err != nil {
+		return fmt.Errorf("DeleteRoomMessages: %w", err)
+	}
+	return nil
+}
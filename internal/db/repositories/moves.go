@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lvlchess/internal/db/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/notnil/chess"
+)
+
+/*
+MovesRepository provides access to the "room_moves" table, i.e. the full
+move history of each room. Moves are written by RoomsRepository.UpdateRoomWithMove,
+in the same transaction as the board_state update; this repository only adds
+the post-game analysis to them (SaveAnalysis).
+*/
+type MovesRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewMovesRepository constructs a MovesRepository given a pgxpool.
+func NewMovesRepository(pool *pgxpool.Pool) *MovesRepository {
+	return &MovesRepository{pool: pool}
+}
+
+/*
+GetMovesByRoomID returns every move of the given room ordered by ply (1, 2, 3, ...).
+An empty slice means no move has been played yet.
+*/
+func (r *MovesRepository) GetMovesByRoomID(ctx context.Context, roomID string) ([]models.RoomMove, error) {
+	sql := `
+SELECT
+  room_id,
+  ply,
+  uci,
+  san,
+  fen_after,
+  mover_id,
+  created_at,
+  eval,
+  cp_loss,
+  accuracy,
+  judgment,
+  best_san
+FROM room_moves
+WHERE room_id = $1
+ORDER BY ply ASC
+`
+	rows, err := r.pool.Query(ctx, sql, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("GetMovesByRoomID: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.RoomMove
+	for rows.Next() {
+		var mv models.RoomMove
+		err := rows.Scan(
+			&mv.RoomID,
+			&mv.Ply,
+			&mv.UCI,
+			&mv.SAN,
+			&mv.FENAfter,
+			&mv.MoverID,
+			&mv.CreatedAt,
+			&mv.Eval,
+			&mv.CPLoss,
+			&mv.Accuracy,
+			&mv.Judgment,
+			&mv.BestSAN,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		result = append(result, mv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetMovesByRoomID rows: %w", err)
+	}
+	return result, nil
+}
+
+/*
+SaveAnalysis stores the analysis fields (Eval, CPLoss, Accuracy, Judgment, BestSAN)
+of the given moves, matched by room and ply, in a single transaction.
+*/
+func (r *MovesRepository) SaveAnalysis(ctx context.Context, moves []models.RoomMove) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("SaveAnalysis begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `
+UPDATE room_moves
+SET eval = $3, cp_loss = $4, accuracy = $5, judgment = $6, best_san = $7
+WHERE room_id = $1 AND ply = $2
+`
+	for _, mv := range moves {
+		_, err := tx.Exec(ctx, sql, mv.RoomID, mv.Ply, mv.Eval, mv.CPLoss, mv.Accuracy, mv.Judgment, mv.BestSAN)
+		if err != nil {
+			return fmt.Errorf("SaveAnalysis ply %d: %w", mv.Ply, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("SaveAnalysis commit: %w", err)
+	}
+	return nil
+}
+
+/*
+GetLastMove returns the latest move of the room, or nil if no move has been played yet.
+*/
+func (r *MovesRepository) GetLastMove(ctx context.Context, roomID string) (*models.RoomMove, error) {
+	sql := `
+SELECT room_id, ply, uci, san, fen_after, mover_id, created_at
+FROM room_moves
+WHERE room_id = $1
+ORDER BY ply DESC
+LIMIT 1
+`
+	var mv models.RoomMove
+	err := r.pool.QueryRow(ctx, sql, roomID).Scan(
+		&mv.RoomID,
+		&mv.Ply,
+		&mv.UCI,
+		&mv.SAN,
+		&mv.FENAfter,
+		&mv.MoverID,
+		&mv.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetLastMove: %w", err)
+	}
+	return &mv, nil
+}
+
+/*
+CountMoves returns how many plies have been played in the room so far.
+*/
+func (r *MovesRepository) CountMoves(ctx context.Context, roomID string) (int, error) {
+	sql := `SELECT COUNT(*) FROM room_moves WHERE room_id = $1`
+
+	var count int
+	if err := r.pool.QueryRow(ctx, sql, roomID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("CountMoves: %w", err)
+	}
+	return count, nil
+}
+
+/*
+ReplayGame rebuilds a *chess.Game from the stored move history of the room.
+startFEN is the position the room started from (empty means the standard initial position).
+The returned game carries the full position history, so repetition checks and PGN encoding work on it.
+*/
+func (r *MovesRepository) ReplayGame(ctx context.Context, roomID, startFEN string) (*chess.Game, error) {
+	moves, err := r.GetMovesByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	return ReplayMoves(moves, startFEN)
+}
+
+// ReplayMoves plays the moves of a room, in ply order, from startFEN (empty means the standard initial
+// position). It is the ReplayGame of every MoveStore.
+func ReplayMoves(moves []models.RoomMove, startFEN string) (*chess.Game, error) {
+	chGame := chess.NewGame()
+	if startFEN != "" {
+		fenOption, err := chess.FEN(startFEN)
+		if err != nil {
+			return nil, fmt.Errorf("ReplayGame: invalid start FEN: %w", err)
+		}
+		chGame = chess.NewGame(fenOption)
+	}
+
+	for _, stored := range moves {
+		mv, err := chess.UCINotation{}.Decode(chGame.Position(), stored.UCI)
+		if err != nil {
+			return nil, fmt.Errorf("ReplayGame: ply %d (%s): %w", stored.Ply, stored.UCI, err)
+		}
+		if err := chGame.Move(mv); err != nil {
+			return nil, fmt.Errorf("ReplayGame: ply %d (%s): %w", stored.Ply, stored.UCI, err)
+		}
+	}
+	return chGame, nil
+}
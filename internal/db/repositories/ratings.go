@@ -0,0 +1,296 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/rating"
+)
+
+/*
+RatingsRepository reads the "user_ratings" and "rating_history" tables.
+Ratings themselves are updated by RoomsRepository when a rated room finishes,
+in the same transaction as the room (see applyRatedResult).
+*/
+type RatingsRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewRatingsRepository constructs a RatingsRepository given a pgxpool.
+func NewRatingsRepository(pool *pgxpool.Pool) *RatingsRepository {
+	return &RatingsRepository{pool: pool}
+}
+
+/*
+GetUserRating returns the rating of a user in the given category.
+A user who has not played a rated game in that category gets the default (provisional) rating.
+*/
+func (r *RatingsRepository) GetUserRating(ctx context.Context, userID int64, category string) (*models.UserRating, error) {
+	sql := `
+SELECT
+  user_id,
+  category,
+  rating,
+  rd,
+  volatility,
+  games,
+  updated_at
+FROM user_ratings
+WHERE user_id = $1 AND category = $2
+`
+	ur, err := scanUserRating(r.pool.QueryRow(ctx, sql, userID, category))
+	if errors.Is(err, pgx.ErrNoRows) {
+		def := rating.Default()
+		return &models.UserRating{
+			UserID:     userID,
+			Category:   category,
+			Rating:     def.Rating,
+			RD:         def.RD,
+			Volatility: def.Volatility,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetUserRating: %w", err)
+	}
+	return ur, nil
+}
+
+/*
+GetLeaderboard returns the top players of a rating category, best first.
+Provisional ratings (fewer than models.ProvisionalGames games) are left out.
+*/
+func (r *RatingsRepository) GetLeaderboard(ctx context.Context, category string, limit int) ([]models.UserRating, error) {
+	sql := `
+SELECT
+  user_id,
+  category,
+  rating,
+  rd,
+  volatility,
+  games,
+  updated_at
+FROM user_ratings
+WHERE category = $1
+  AND games >= $2
+ORDER BY rating DESC
+LIMIT $3
+`
+	rows, err := r.pool.Query(ctx, sql, category, models.ProvisionalGames, limit)
+	if err != nil {
+		return nil, fmt.Errorf("GetLeaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.UserRating
+	for rows.Next() {
+		ur, err := scanUserRating(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		result = append(result, *ur)
+	}
+	return result, rows.Err()
+}
+
+/*
+GetRatingChangesByRoomID returns the rating changes caused by the given room
+(two rows for a finished rated game, none otherwise).
+*/
+func (r *RatingsRepository) GetRatingChangesByRoomID(ctx context.Context, roomID string) ([]models.RatingChange, error) {
+	sql := `
+SELECT
+  id,
+  user_id,
+  room_id,
+  category,
+  rating_before,
+  rating_after,
+  rd_before,
+  rd_after,
+  volatility_after,
+  created_at
+FROM rating_history
+WHERE room_id = $1
+ORDER BY id
+`
+	rows, err := r.pool.Query(ctx, sql, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRatingChangesByRoomID: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.RatingChange
+	for rows.Next() {
+		var c models.RatingChange
+		if err := rows.Scan(
+			&c.ID,
+			&c.UserID,
+			&c.RoomID,
+			&c.Category,
+			&c.RatingBefore,
+			&c.RatingAfter,
+			&c.RDBefore,
+			&c.RDAfter,
+			&c.VolatilityAfter,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// scanUserRating reads a single "user_ratings" row.
+func scanUserRating(row pgx.Row) (*models.UserRating, error) {
+	var ur models.UserRating
+	err := row.Scan(
+		&ur.UserID,
+		&ur.Category,
+		&ur.Rating,
+		&ur.RD,
+		&ur.Volatility,
+		&ur.Games,
+		&ur.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ur, nil
+}
+
+// applyRatedResult updates the Glicko-2 ratings of both players of a finished rated room in the room's
+// rating category and records the changes in "rating_history". It must run inside the transaction
+// finishing the room.
+func applyRatedResult(ctx context.Context, tx pgx.Tx, room *models.Room) error {
+	if !room.Rated || room.Result == models.ResultNone || room.WhiteID == nil || room.BlackID == nil {
+		return nil
+	}
+
+	var score float64
+	switch room.Result {
+	case models.ResultWhiteWon:
+		score = rating.ScoreWin
+	case models.ResultBlackWon:
+		score = rating.ScoreLoss
+	default:
+		score = rating.ScoreDraw
+	}
+
+	category := models.RatingCategoryFor(room)
+	white, black, err := lockPlayerRatings(ctx, tx, category, *room.WhiteID, *room.BlackID)
+	if err != nil {
+		return err
+	}
+
+	newWhite, newBlack := rating.Rate(white, black, score)
+	if err := savePlayerRating(ctx, tx, room.RoomID, category, *room.WhiteID, white, newWhite); err != nil {
+		return err
+	}
+	return savePlayerRating(ctx, tx, room.RoomID, category, *room.BlackID, black, newBlack)
+}
+
+// lockPlayerRatings reads the current ratings of both players in a category (creating default rows
+// for first-timers), locking the rows until the transaction ends.
+// Rows are locked in ID order, so two games finishing at once cannot deadlock.
+func lockPlayerRatings(ctx context.Context, tx pgx.Tx, category string, whiteID, blackID int64) (white, black rating.Rating, err error) {
+	if _, err = tx.Exec(ctx, `
+INSERT INTO user_ratings (user_id, category)
+VALUES ($1, $3), ($2, $3)
+ON CONFLICT (user_id, category) DO NOTHING
+`, whiteID, blackID, category); err != nil {
+		return white, black, fmt.Errorf("init ratings: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+SELECT user_id, rating, rd, volatility
+FROM user_ratings
+WHERE category = $1 AND user_id IN ($2, $3)
+ORDER BY user_id
+FOR UPDATE
+`, category, whiteID, blackID)
+	if err != nil {
+		return white, black, fmt.Errorf("lock ratings: %w", err)
+	}
+	defer rows.Close()
+
+	found := 0
+	for rows.Next() {
+		var id int64
+		var rt rating.Rating
+		if err = rows.Scan(&id, &rt.Rating, &rt.RD, &rt.Volatility); err != nil {
+			return white, black, fmt.Errorf("lock ratings scan: %w", err)
+		}
+		if id == whiteID {
+			white = rt
+		} else {
+			black = rt
+		}
+		found++
+	}
+	if err = rows.Err(); err != nil {
+		return white, black, fmt.Errorf("lock ratings: %w", err)
+	}
+	if found != 2 {
+		return white, black, fmt.Errorf("lock ratings: expected 2 players, found %d", found)
+	}
+	return white, black, nil
+}
+
+// savePlayerRating stores the new rating of a user in a category and appends a "rating_history" row.
+func savePlayerRating(
+	ctx context.Context,
+	tx pgx.Tx,
+	roomID, category string,
+	userID int64,
+	before, after rating.Rating,
+) error {
+	sqlRating := `
+UPDATE user_ratings
+SET
+    rating     = $1,
+    rd         = $2,
+    volatility = $3,
+    games      = games + 1,
+    updated_at = NOW()
+WHERE user_id = $4 AND category = $5
+`
+	if _, err := tx.Exec(ctx, sqlRating,
+		after.Rating, after.RD, after.Volatility, userID, category,
+	); err != nil {
+		return fmt.Errorf("update rating of user %d: %w", userID, err)
+	}
+
+	sqlHistory := `
+INSERT INTO rating_history (
+  user_id,
+  room_id,
+  category,
+  rating_before,
+  rating_after,
+  rd_before,
+  rd_after,
+  volatility_after,
+  created_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+`
+	if _, err := tx.Exec(ctx, sqlHistory,
+		userID,
+		roomID,
+		category,
+		before.Rating,
+		after.Rating,
+		before.RD,
+		after.RD,
+		after.Volatility,
+	); err != nil {
+		return fmt.Errorf("insert rating history of user %d: %w", userID, err)
+	}
+	return nil
+}
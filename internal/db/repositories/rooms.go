@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
@@ -14,9 +15,14 @@ import (
 )
 
 // ErrUniqueViolation is a sentinel error message typically triggered
-// by a Postgres UNIQUE constraint (e.g., "players_pair" on (player1_id, player2_id)).
+// by a Postgres UNIQUE constraint (e.g., "rooms_active_players_pair" on (player1_id, player2_id)).
 const ErrUniqueViolation = "unique_violation"
 
+// ErrRoomVersionConflict is returned by UpdateRoom, UpdateRoomWithMove and FinishRoom when the room row has been changed
+// by someone else since it was loaded (its version no longer matches room.Version).
+// The caller should reload the room and decide again.
+var ErrRoomVersionConflict = errors.New("room was modified concurrently")
+
 /*
 RoomsRepository provides CRUD-like operations for the "rooms" table.
 It manages the creation of new chess rooms, retrieving and updating them.
@@ -30,6 +36,94 @@ func NewRoomsRepository(pool *pgxpool.Pool) *RoomsRepository {
 	return &RoomsRepository{pool: pool}
 }
 
+// roomColumns lists the "rooms" columns read by scanRoom, in scan order.
+// Nullable text columns are coalesced so they can be scanned into plain strings.
+const roomColumns = `
+  room_id,
+  room_title,
+  player1_id,
+  player2_id,
+  status,
+  board_state,
+  is_white_turn,
+  white_id,
+  black_id,
+  chat_id,
+  COALESCE(initial_fen, ''),
+  rated,
+  bot_level,
+  time_control,
+  clock_initial,
+  clock_increment,
+  days_per_move,
+  white_time_ms,
+  black_time_ms,
+  turn_started_at,
+  result,
+  termination,
+  draw_offered_by,
+  version,
+  created_at,
+  updated_at`
+
+// scanRoom reads a single row selected with roomColumns into a models.Room.
+func scanRoom(row pgx.Row) (*models.Room, error) {
+	var rm models.Room
+	err := row.Scan(
+		&rm.RoomID,
+		&rm.RoomTitle,
+		&rm.Player1ID,
+		&rm.Player2ID,
+		&rm.Status,
+		&rm.BoardState,
+		&rm.IsWhiteTurn,
+		&rm.WhiteID,
+		&rm.BlackID,
+		&rm.ChatID,
+		&rm.InitialFEN,
+		&rm.Rated,
+		&rm.BotLevel,
+		&rm.TimeControl,
+		&rm.ClockInitial,
+		&rm.ClockIncrement,
+		&rm.DaysPerMove,
+		&rm.WhiteTimeMs,
+		&rm.BlackTimeMs,
+		&rm.TurnStartedAt,
+		&rm.Result,
+		&rm.Termination,
+		&rm.DrawOfferedBy,
+		&rm.Version,
+		&rm.CreatedAt,
+		&rm.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rm, nil
+}
+
+// collectRooms scans every row of a query selecting roomColumns and closes rows.
+func collectRooms(rows pgx.Rows) ([]models.Room, error) {
+	defer rows.Close()
+
+	var result []models.Room
+	for rows.Next() {
+		rm, err := scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		result = append(result, *rm)
+	}
+	return result, rows.Err()
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation (23505).
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
 /*
 CreateRoom inserts a new record into the "rooms" table. If the DB constraint
 violates the unique pairing of Player1 + Player2, it returns an error
@@ -49,10 +143,19 @@ INSERT INTO rooms (
   status,
   board_state,
   is_white_turn,
+  initial_fen,
+  time_control,
+  clock_initial,
+  clock_increment,
+  days_per_move,
+  white_time_ms,
+  black_time_ms,
+  rated,
+  bot_level,
   created_at,
   updated_at
 )
-VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW())
 `
 	_, err := r.pool.Exec(ctx, sql,
 		room.RoomID,
@@ -61,14 +164,21 @@ VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
 		room.Status,
 		room.BoardState,
 		room.IsWhiteTurn,
+		room.InitialFEN,
+		room.TimeControl,
+		room.ClockInitial,
+		room.ClockIncrement,
+		room.DaysPerMove,
+		room.WhiteTimeMs,
+		room.BlackTimeMs,
+		room.Rated,
+		room.BotLevel,
 	)
 	if err != nil {
 		// If the DB error is a unique violation on the constraint
-		if pgErr, ok := err.(*pgconn.PgError); ok {
-			if pgErr.Code == "23505" {
-				// We transform that into our internal error constant
-				return errors.New(ErrUniqueViolation)
-			}
+		if isUniqueViolation(err) {
+			// We transform that into our internal error constant
+			return errors.New(ErrUniqueViolation)
 		}
 		utils.Logger.Error("INSERT: "+err.Error(), zap.Error(err))
 		return fmt.Errorf("CreateRoom: %v", err)
@@ -82,43 +192,20 @@ Returns the matched record or an error if not found.
 */
 func (r *RoomsRepository) GetRoomByID(ctx context.Context, roomID string) (*models.Room, error) {
 	sql := `
-SELECT 
-  room_id,
-  room_title,
-  player1_id,
-  player2_id,
-  status,
-  board_state,
-  is_white_turn,
-  white_id,
-  black_id,
-  chat_id,
-  created_at,
-  updated_at
+SELECT ` + roomColumns + `
 FROM rooms
 WHERE room_id = $1
 `
 	row := r.pool.QueryRow(ctx, sql, roomID)
 
-	var rm models.Room
-	err := row.Scan(
-		&rm.RoomID,
-		&rm.RoomTitle,
-		&rm.Player1ID,
-		&rm.Player2ID,
-		&rm.Status,
-		&rm.BoardState,
-		&rm.IsWhiteTurn,
-		&rm.WhiteID,
-		&rm.BlackID,
-		&rm.ChatID,
-		&rm.CreatedAt,
-		&rm.UpdatedAt,
-	)
+	rm, err := scanRoom(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("GetRoomByID: %w", ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("GetRoomByID: %v", err)
 	}
-	return &rm, nil
+	return rm, nil
 }
 
 /*
@@ -127,43 +214,20 @@ If no such record is found, an error is returned.
 */
 func (r *RoomsRepository) GetRoomByChatID(ctx context.Context, chatID int64) (*models.Room, error) {
 	sql := `
-SELECT 
-  room_id,
-  room_title,
-  player1_id,
-  player2_id,
-  status,
-  board_state,
-  is_white_turn,
-  white_id,
-  black_id,
-  chat_id,
-  created_at,
-  updated_at
+SELECT ` + roomColumns + `
 FROM rooms
 WHERE chat_id = $1
 `
 	row := r.pool.QueryRow(ctx, sql, chatID)
 
-	var rm models.Room
-	err := row.Scan(
-		&rm.RoomID,
-		&rm.RoomTitle,
-		&rm.Player1ID,
-		&rm.Player2ID,
-		&rm.Status,
-		&rm.BoardState,
-		&rm.IsWhiteTurn,
-		&rm.WhiteID,
-		&rm.BlackID,
-		&rm.ChatID,
-		&rm.CreatedAt,
-		&rm.UpdatedAt,
-	)
+	rm, err := scanRoom(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("GetRoomByChatID: %w", ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("GetRoomByChatID: %v", err)
 	}
-	return &rm, nil
+	return rm, nil
 }
 
 /*
@@ -173,19 +237,7 @@ it checks if the pair (p1ID, p2ID) or (p2ID, p1ID) matches an existing record.
 */
 func (r *RoomsRepository) GetRoomByPlayerIDs(ctx context.Context, p1ID, p2ID int64) (*models.Room, error) {
 	sql := `
-SELECT 
-    room_id,
-    room_title,
-    player1_id,
-    player2_id,
-    status,
-    board_state,
-    is_white_turn,
-    white_id,
-    black_id,
-    chat_id,
-    created_at,
-    updated_at
+SELECT ` + roomColumns + `
 FROM rooms
 WHERE status IN('waiting','playing')
   AND 
@@ -206,30 +258,22 @@ WHERE status IN('waiting','playing')
 
 	row := r.pool.QueryRow(ctx, sql, p1ID, p2ID)
 
-	var rm models.Room
-	err := row.Scan(
-		&rm.RoomID,
-		&rm.RoomTitle,
-		&rm.Player1ID,
-		&rm.Player2ID,
-		&rm.Status,
-		&rm.BoardState,
-		&rm.IsWhiteTurn,
-		&rm.WhiteID,
-		&rm.BlackID,
-		&rm.ChatID,
-		&rm.CreatedAt,
-		&rm.UpdatedAt,
-	)
+	rm, err := scanRoom(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("GetRoomByPlayerIDs: %w", ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("GetRoomByPlayerIDs: %v", err)
 	}
-	return &rm, nil
+	return rm, nil
 }
 
 /*
 UpdateRoom modifies the existing record in "rooms", changing
 fields like Title, second player, status, board_state, etc.
+If the new second player already has an unfinished room with the first one, ErrUniqueViolation is returned.
+It is a compare-and-swap on the version column: the row is only updated if it still has room.Version,
+otherwise ErrRoomVersionConflict is returned and nothing is written. On success room.Version is bumped.
 */
 func (r *RoomsRepository) UpdateRoom(ctx context.Context, room *models.Room) error {
 	if err := room.Validate(); err != nil {
@@ -246,10 +290,24 @@ SET
     white_id       = $6,
     black_id       = $7,
     chat_id        = $8,
+    time_control   = $9,
+    clock_initial  = $10,
+    clock_increment = $11,
+    days_per_move  = $12,
+    white_time_ms  = $13,
+    black_time_ms  = $14,
+    turn_started_at = $15,
+    result         = $16,
+    termination    = $17,
+    draw_offered_by = $18,
+    rated          = $19,
+    bot_level      = $20,
+    version        = version + 1,
     updated_at     = NOW()
-WHERE room_id = $9
+WHERE room_id = $21
+  AND version = $22
 `
-	_, err := r.pool.Exec(ctx, sql,
+	tag, err := r.pool.Exec(ctx, sql,
 		room.RoomTitle,
 		room.Player2ID,
 		room.Status,
@@ -258,26 +316,135 @@ WHERE room_id = $9
 		room.WhiteID,
 		room.BlackID,
 		room.ChatID,
+		room.TimeControl,
+		room.ClockInitial,
+		room.ClockIncrement,
+		room.DaysPerMove,
+		room.WhiteTimeMs,
+		room.BlackTimeMs,
+		room.TurnStartedAt,
+		room.Result,
+		room.Termination,
+		room.DrawOfferedBy,
+		room.Rated,
+		room.BotLevel,
 		room.RoomID,
+		room.Version,
 	)
+	if isUniqueViolation(err) {
+		// The second player already has an unfinished room with the first one.
+		return errors.New(ErrUniqueViolation)
+	}
 	if err != nil {
 		return fmt.Errorf("UpdateRoom exec: %v", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateRoom %s: %w", room.RoomID, ErrRoomVersionConflict)
+	}
+	room.Version++
+	return nil
+}
+
+/*
+UpdateRoomWithMove stores a freshly played move: it updates the room row (board_state, turn, ...)
+and appends the move to "room_moves" within a single transaction, so the history never
+diverges from the board. The move's Ply is assigned here (last ply + 1) and written back into mv.
+Like UpdateRoom, it only applies to the version of the room that was loaded: if another update got in first
+(e.g. a second click on a move button), ErrRoomVersionConflict is returned and the move is not stored.
+*/
+func (r *RoomsRepository) UpdateRoomWithMove(ctx context.Context, room *models.Room, mv *models.RoomMove) error {
+	if err := room.Validate(); err != nil {
+		return fmt.Errorf("UpdateRoomWithMove Validate room: %w", err)
+	}
+	if err := mv.Validate(); err != nil {
+		return fmt.Errorf("UpdateRoomWithMove Validate move: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateRoomWithMove begin: %w", err)
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	sqlRoom := `
+UPDATE rooms
+SET
+    board_state    = $1,
+    is_white_turn  = $2,
+    status         = $3,
+    white_time_ms  = $4,
+    black_time_ms  = $5,
+    turn_started_at = $6,
+    draw_offered_by = $7,
+    result         = $8,
+    termination    = $9,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE room_id = $10
+  AND status = 'playing'
+  AND version = $11
+`
+	tag, err := tx.Exec(ctx, sqlRoom,
+		room.BoardState,
+		room.IsWhiteTurn,
+		room.Status,
+		room.WhiteTimeMs,
+		room.BlackTimeMs,
+		room.TurnStartedAt,
+		room.DrawOfferedBy,
+		room.Result,
+		room.Termination,
+		room.RoomID,
+		room.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("UpdateRoomWithMove update room: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateRoomWithMove %s: %w", room.RoomID, ErrRoomVersionConflict)
+	}
+
+	sqlMove := `
+INSERT INTO room_moves (room_id, ply, uci, san, fen_after, mover_id, created_at)
+VALUES (
+  $1,
+  (SELECT COALESCE(MAX(ply), 0) + 1 FROM room_moves WHERE room_id = $1),
+  $2, $3, $4, $5, NOW()
+)
+RETURNING ply, created_at
+`
+	if err = tx.QueryRow(ctx, sqlMove,
+		mv.RoomID,
+		mv.UCI,
+		mv.SAN,
+		mv.FENAfter,
+		mv.MoverID,
+	).Scan(&mv.Ply, &mv.CreatedAt); err != nil {
+		return fmt.Errorf("UpdateRoomWithMove insert move: %w", err)
+	}
+
+	// The move may have ended the game (mate, stalemate, ...): count it in the same transaction.
+	if room.Status == models.RoomStatusFinished {
+		if err = updatePlayerStats(ctx, tx, room); err != nil {
+			return fmt.Errorf("UpdateRoomWithMove: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("UpdateRoomWithMove commit: %w", err)
+	}
+	room.Version++
 	return nil
 }
 
 /*
-GetPlayingRoomsForUser attempts to return all "active" or "waiting" rooms
+GetPlayingRoomsForUser returns all active ("waiting" or "playing") rooms
 for the specified user. It filters by (player1_id=$1 OR player2_id=$1).
 */
 func (r *RoomsRepository) GetPlayingRoomsForUser(ctx context.Context, userID int64) ([]models.Room, error) {
 	sql := `
-SELECT
-  room_id,
-  room_title,
-  board_state,
-  is_white_turn,
-  updated_at
+SELECT ` + roomColumns + `
 FROM rooms
 WHERE status IN ('waiting','playing')
   AND (player1_id = $1 OR player2_id = $1)
@@ -287,23 +454,135 @@ ORDER BY updated_at DESC
 	if err != nil {
 		return nil, fmt.Errorf("GetPlayingRoomsForUser: %v", err)
 	}
-	defer rows.Close()
+	return collectRooms(rows)
+}
 
-	var result []models.Room
-	for rows.Next() {
-		var rm models.Room
-		err := rows.Scan(
-			&rm.RoomID,
-			&rm.RoomTitle,
-			&rm.BoardState,
-			&rm.IsWhiteTurn,
-			&rm.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("rows.Scan: %v", err)
-		}
-		// Note: we are not scanning p1, p2, WhiteID, etc. here
-		result = append(result, rm)
+/*
+GetFinishedRoomsForUser returns up to limit most recently finished rooms of the specified user.
+*/
+func (r *RoomsRepository) GetFinishedRoomsForUser(ctx context.Context, userID int64, limit int) ([]models.Room, error) {
+	sql := `
+SELECT ` + roomColumns + `
+FROM rooms
+WHERE status = 'finished'
+  AND (player1_id = $1 OR player2_id = $1)
+ORDER BY updated_at DESC
+LIMIT $2
+`
+	rows, err := r.pool.Query(ctx, sql, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("GetFinishedRoomsForUser: %v", err)
+	}
+	return collectRooms(rows)
+}
+
+/*
+GetRoomsWithRunningClock returns all "playing" rooms that have a time control and whose clocks
+are already running. Used by the background flag-fall watcher.
+*/
+func (r *RoomsRepository) GetRoomsWithRunningClock(ctx context.Context) ([]models.Room, error) {
+	sql := `
+SELECT ` + roomColumns + `
+FROM rooms
+WHERE status = 'playing'
+  AND time_control <> ''
+  AND turn_started_at IS NOT NULL
+`
+	rows, err := r.pool.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("GetRoomsWithRunningClock: %w", err)
+	}
+	return collectRooms(rows)
+}
+
+/*
+FinishRoom marks a "playing" room as finished, recording room.Result, room.Termination and the final clocks,
+and updates both players' statistics in the same transaction.
+Like UpdateRoom, it only applies to the version of the room that was loaded (and to its board), so a concurrent
+move or draw offer cannot be overwritten by, e.g., the flag-fall watcher: otherwise ErrRoomVersionConflict
+is returned and nothing is written. On success room.Version is bumped.
+*/
+func (r *RoomsRepository) FinishRoom(ctx context.Context, room *models.Room) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("FinishRoom begin: %w", err)
 	}
-	return result, nil
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	sql := `
+UPDATE rooms
+SET
+    status          = 'finished',
+    white_time_ms   = $1,
+    black_time_ms   = $2,
+    turn_started_at = NULL,
+    result          = $3,
+    termination     = $4,
+    draw_offered_by = NULL,
+    version         = version + 1,
+    updated_at      = NOW()
+WHERE room_id = $5
+  AND status = 'playing'
+  AND board_state = $6
+  AND version = $7
+`
+	tag, err := tx.Exec(ctx, sql,
+		room.WhiteTimeMs,
+		room.BlackTimeMs,
+		room.Result,
+		room.Termination,
+		room.RoomID,
+		room.BoardState,
+		room.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("FinishRoom exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("FinishRoom %s: %w", room.RoomID, ErrRoomVersionConflict)
+	}
+	if err = updatePlayerStats(ctx, tx, room); err != nil {
+		return fmt.Errorf("FinishRoom: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("FinishRoom commit: %w", err)
+	}
+
+	room.Status = models.RoomStatusFinished
+	room.TurnStartedAt = nil
+	room.DrawOfferedBy = nil
+	room.Version++
+	return nil
+}
+
+// updatePlayerStats counts a finished game for both players: total_games+1 each, wins+1 for the winner,
+// and, for rated rooms, new ratings. Games without a result (aborted) are not counted.
+func updatePlayerStats(ctx context.Context, tx pgx.Tx, room *models.Room) error {
+	if room.Result == models.ResultNone || room.WhiteID == nil || room.BlackID == nil {
+		return nil
+	}
+	if err := applyRatedResult(ctx, tx, room); err != nil {
+		return err
+	}
+
+	var winnerID *int64
+	switch room.Result {
+	case models.ResultWhiteWon:
+		winnerID = room.WhiteID
+	case models.ResultBlackWon:
+		winnerID = room.BlackID
+	}
+
+	sql := `
+UPDATE users
+SET
+    total_games = total_games + 1,
+    wins        = wins + CASE WHEN id = $3 THEN 1 ELSE 0 END
+WHERE id IN ($1, $2)
+`
+	if _, err := tx.Exec(ctx, sql, *room.WhiteID, *room.BlackID, winnerID); err != nil {
+		return fmt.Errorf("update player stats: %w", err)
+	}
+	return nil
 }
@@ -3,12 +3,14 @@ package repositories
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"lvlchess/internal/db/models"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -109,6 +111,9 @@ WHERE id = $1
 		&t.CreatedAt,
 		&t.UpdatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("GetTournamentByID %s: %w", tid, ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("GetTournamentByID: %w", err)
 	}
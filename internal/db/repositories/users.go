@@ -2,11 +2,13 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"lvlchess/internal/db/models"
 	"lvlchess/internal/utils"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -15,6 +17,7 @@ import (
 UsersRepository handles operations for the "users" table:
   - Creating or updating a user
   - Retrieving by user ID
+  - Storing the user's preferences (board style)
 */
 type UsersRepository struct {
 	pool *pgxpool.Pool
@@ -27,8 +30,9 @@ func NewUsersRepository(pool *pgxpool.Pool) *UsersRepository {
 
 /*
 CreateOrUpdateUser is an UPSERT method.
-If a user with the same ID exists, it updates fields,
-otherwise it inserts a new record.
+If a user with the same ID exists, it updates the profile fields (names, chat ID),
+otherwise it inserts a new record. Game statistics (rating, wins, total_games) of an
+existing user are never overwritten here: they are maintained when games finish.
 */
 func (repo *UsersRepository) CreateOrUpdateUser(ctx context.Context, u *models.User) error {
 	// Validate the user struct
@@ -46,15 +50,12 @@ INSERT INTO users (
   wins,
   total_games
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7)
+VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, 0), 1000), $6, $7)
 ON CONFLICT (id) DO UPDATE
    SET
      user_name    = EXCLUDED.user_name,
      first_name   = EXCLUDED.first_name,
-     chat_id      = EXCLUDED.chat_id,
-     rating       = EXCLUDED.rating,
-     wins         = EXCLUDED.wins,
-     total_games  = EXCLUDED.total_games
+     chat_id      = EXCLUDED.chat_id
 `
 	_, err := repo.pool.Exec(ctx, sql,
 		u.ID,
@@ -74,7 +75,7 @@ ON CONFLICT (id) DO UPDATE
 
 /*
 GetUserByID fetches a single user by their Telegram user ID.
-It reads the columns: id, user_name, first_name, chat_id, rating, wins, total_games.
+It reads the columns: id, user_name, first_name, chat_id, rating, wins, total_games, board_style.
 */
 func (repo *UsersRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	sql := `
@@ -85,7 +86,8 @@ SELECT
   chat_id,
   rating,
   wins,
-  total_games
+  total_games,
+  board_style
 FROM users
 WHERE id = $1;
 `
@@ -101,9 +103,27 @@ WHERE id = $1;
 		&u.Rating,
 		&u.Wins,
 		&u.TotalGames,
+		&u.BoardStyle,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("GetUserByID %d: %w", id, ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("GetUserByID: %v", err)
 	}
 	return &u, nil
 }
+
+/*
+SetBoardStyle stores how the board is shown to the user: models.BoardStyleImage or models.BoardStyleASCII.
+*/
+func (repo *UsersRepository) SetBoardStyle(ctx context.Context, id int64, style string) error {
+	if style != models.BoardStyleImage && style != models.BoardStyleASCII {
+		return fmt.Errorf("SetBoardStyle: unknown style %q", style)
+	}
+	sql := `UPDATE users SET board_style = $2 WHERE id = $1`
+	if _, err := repo.pool.Exec(ctx, sql, id, style); err != nil {
+		return fmt.Errorf("SetBoardStyle: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package engine contains the chess engines behind the "Play with bot" mode, hints and analysis.
+// The built-in engine (Builtin) is written in pure Go on top of notnil/chess positions and needs
+// no external binaries: an iterative-deepening alpha-beta (negamax) search with a transposition table,
+// quiescence search over captures and move ordering (TT move, MVV-LVA, killers, history).
+// Alternatively, any local binary speaking the UCI protocol can be plugged in (UCI).
+package engine
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	// DefaultMoveTime is the thinking time used when Limits.MoveTime is not set.
+	DefaultMoveTime = 2 * time.Second
+	// MaxDepth is the deepest iteration the search will start.
+	MaxDepth = 32
+
+	// MateScore is the score of a position where the side to move delivers mate right now.
+	// Mate in N plies is reported as MateScore-N (or -(MateScore-N) when being mated).
+	MateScore = 100000
+	// mateThreshold separates mate scores from ordinary evaluations.
+	mateThreshold = MateScore - 1000
+)
+
+// ErrNoMoves is returned when the game is already over and there is nothing to search.
+var ErrNoMoves = errors.New("engine: no legal moves")
+
+// Limits bound a single search. Zero values mean "use the default":
+// MaxDepth plies, DefaultMoveTime, no node limit and full strength.
+type Limits struct {
+	Depth    int           // Maximal search depth in plies
+	MoveTime time.Duration // Wall-clock budget for the whole search
+	Nodes    int64         // Maximal number of visited nodes
+	Level    int           // Bot level (MinLevel..MaxLevel), see LevelStrength; 0 = full strength
+	Game     string        // Identifies the game searched (e.g. the room ID); "" = a new game every time
+}
+
+// Result is the outcome of a search.
+type Result struct {
+	Move  *chess.Move   // Best move found, legal in the searched position
+	Score int           // Centipawns from the point of view of the side to move
+	Depth int           // Depth of the last completed iteration
+	Nodes int64         // Nodes visited
+	PV    []*chess.Move // Principal variation, starting with Move
+}
+
+/*
+Engine finds moves for the bot and evaluates positions for hints and analysis.
+It is implemented by the built-in engine (Builtin) and by external UCI binaries (UCI).
+*/
+type Engine interface {
+	// Search looks for the best move in the current position of chGame within the limits.
+	// It returns ErrNoMoves if the game is already over.
+	Search(ctx context.Context, chGame *chess.Game, limits Limits) (*Result, error)
+	// Close releases the engine's resources (e.g. stops external processes).
+	Close() error
+}
+
+// IsMate reports whether a score announces a forced mate (for either side).
+func IsMate(score int) bool {
+	return score >= mateThreshold || score <= -mateThreshold
+}
+
+// Builtin is the pure-Go engine. It is stateless between searches and safe for concurrent use:
+// every call to Search gets its own transposition table.
+type Builtin struct{}
+
+// NewBuiltin constructs the built-in engine.
+func NewBuiltin() *Builtin {
+	return &Builtin{}
+}
+
+// Close implements Engine; the built-in engine holds no resources.
+func (b *Builtin) Close() error {
+	return nil
+}
+
+// Search looks for the best move in the current position of chGame. The game's position history
+// is used to score repetitions as draws. The search stops at the first exhausted limit or when ctx
+// is cancelled; the best move of the deepest finished iteration is returned.
+func (b *Builtin) Search(ctx context.Context, chGame *chess.Game, limits Limits) (*Result, error) {
+	pos := chGame.Position()
+	if len(pos.ValidMoves()) == 0 {
+		return nil, ErrNoMoves
+	}
+
+	var strength Strength
+	if limits.Level > 0 {
+		strength = LevelStrength(limits.Level)
+		limits = strength.limit(limits)
+	}
+
+	depth := limits.Depth
+	if depth <= 0 || depth > MaxDepth {
+		depth = MaxDepth
+	}
+	moveTime := limits.MoveTime
+	if moveTime <= 0 {
+		moveTime = DefaultMoveTime
+	}
+
+	s := newSearcher(ctx, time.Now().Add(moveTime), limits.Nodes)
+	for _, p := range chGame.Positions()[:len(chGame.Positions())-1] {
+		s.path = append(s.path, hashPosition(p))
+	}
+	s.scoreAll = strength.weakened()
+	res := s.iterate(pos, inCheck(chGame), depth)
+	if s.scoreAll {
+		rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		chosen := strength.pick(s.rootScores, rng)
+		res.Move, res.Score = chosen.move, chosen.score
+		res.PV = s.principalVariation(pos, res.Move, res.Depth)
+	}
+	return res, nil
+}
+
+// inCheck reports whether the side to move in the game's current position is in check.
+func inCheck(chGame *chess.Game) bool {
+	moves := chGame.Moves()
+	return len(moves) > 0 && moves[len(moves)-1].HasTag(chess.Check)
+}
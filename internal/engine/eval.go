@@ -0,0 +1,160 @@
+package engine
+
+import "github.com/notnil/chess"
+
+// pieceValues are the material values in centipawns, indexed by chess.PieceType.
+var pieceValues = [...]int{
+	chess.NoPieceType: 0,
+	chess.King:        0,
+	chess.Queen:       900,
+	chess.Rook:        500,
+	chess.Bishop:      330,
+	chess.Knight:      320,
+	chess.Pawn:        100,
+}
+
+// Piece-square tables (Tomasz Michniewski's "Simplified Evaluation Function").
+// They are written from White's point of view with rank 8 on top, see pstIndex.
+var (
+	pawnTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	knightTable = [64]int{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	}
+	bishopTable = [64]int{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	}
+	rookTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0,
+	}
+	queenTable = [64]int{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	}
+	kingMiddleTable = [64]int{
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
+	}
+	kingEndTable = [64]int{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50,
+	}
+)
+
+const (
+	// bishopPairBonus rewards keeping both bishops.
+	bishopPairBonus = 30
+	// tempoBonus is given to the side to move.
+	tempoBonus = 10
+	// maxPhase is the game phase of the initial position (knight/bishop 1, rook 2, queen 4).
+	maxPhase = 24
+)
+
+// phaseWeights give each piece type's contribution to the game phase.
+var phaseWeights = [...]int{chess.Queen: 4, chess.Rook: 2, chess.Bishop: 1, chess.Knight: 1, chess.Pawn: 0}
+
+// pstIndex maps a square to the piece-square table index for a piece of the given color.
+func pstIndex(sq chess.Square, c chess.Color) int {
+	if c == chess.White {
+		return (7-int(sq.Rank()))*8 + int(sq.File())
+	}
+	return int(sq.Rank())*8 + int(sq.File())
+}
+
+// evaluate is a static evaluation in centipawns from the point of view of the side to move:
+// material, piece-square tables (the king table is blended between middlegame and endgame
+// by the remaining material), the bishop pair and a small tempo bonus.
+func evaluate(pos *chess.Position) int {
+	board := pos.Board()
+	var score, kingMid, kingEnd [3]int
+	var bishops [3]int
+	phase := 0
+
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		p := board.Piece(sq)
+		if p == chess.NoPiece {
+			continue
+		}
+		c, t := p.Color(), p.Type()
+		idx := pstIndex(sq, c)
+		score[c] += pieceValues[t]
+		switch t {
+		case chess.Pawn:
+			score[c] += pawnTable[idx]
+		case chess.Knight:
+			score[c] += knightTable[idx]
+			phase += phaseWeights[t]
+		case chess.Bishop:
+			score[c] += bishopTable[idx]
+			bishops[c]++
+			phase += phaseWeights[t]
+		case chess.Rook:
+			score[c] += rookTable[idx]
+			phase += phaseWeights[t]
+		case chess.Queen:
+			score[c] += queenTable[idx]
+			phase += phaseWeights[t]
+		case chess.King:
+			kingMid[c] = kingMiddleTable[idx]
+			kingEnd[c] = kingEndTable[idx]
+		}
+	}
+
+	phase = min(phase, maxPhase)
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		score[c] += (kingMid[c]*phase + kingEnd[c]*(maxPhase-phase)) / maxPhase
+		if bishops[c] >= 2 {
+			score[c] += bishopPairBonus
+		}
+	}
+
+	us, them := pos.Turn(), pos.Turn().Other()
+	return score[us] - score[them] + tempoBonus
+}
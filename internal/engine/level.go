@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"lvlchess/internal/db/models"
+)
+
+const (
+	// MinLevel is the weakest bot level.
+	MinLevel = models.MinBotLevel
+	// MaxLevel is the strongest bot level: the engine plays at full strength.
+	MaxLevel = models.MaxBotLevel
+)
+
+/*
+Strength describes how a bot level plays. Lower levels search shallower and with fewer nodes,
+then pick randomly among the moves that are nearly as good as the best one (Margin),
+and now and then (BlunderChance) deliberately play a clearly worse move that loses at most MaxLoss.
+*/
+type Strength struct {
+	Depth         int           // Search depth cap in plies; 0 = no cap
+	Nodes         int64         // Node budget; 0 = no budget
+	MoveTime      time.Duration // Thinking time cap; 0 = no cap
+	Margin        int           // Moves within Margin centipawns of the best are played at random
+	BlunderChance float64       // Probability of playing a deliberate inaccuracy
+	MaxLoss       int           // Largest loss (centipawns) such an inaccuracy may cost
+}
+
+// levels lists the strength of each level, index 0 being level 1.
+var levels = [MaxLevel]Strength{
+	{Depth: 1, Nodes: 2_000, MoveTime: 300 * time.Millisecond, Margin: 250, BlunderChance: 0.35, MaxLoss: 600},
+	{Depth: 1, Nodes: 5_000, MoveTime: 400 * time.Millisecond, Margin: 150, BlunderChance: 0.25, MaxLoss: 400},
+	{Depth: 2, Nodes: 10_000, MoveTime: 500 * time.Millisecond, Margin: 100, BlunderChance: 0.18, MaxLoss: 300},
+	{Depth: 2, Nodes: 25_000, MoveTime: 700 * time.Millisecond, Margin: 70, BlunderChance: 0.12, MaxLoss: 200},
+	{Depth: 3, Nodes: 60_000, MoveTime: time.Second, Margin: 40, BlunderChance: 0.08, MaxLoss: 150},
+	{Depth: 4, Nodes: 150_000, MoveTime: 1500 * time.Millisecond, Margin: 20, BlunderChance: 0.04, MaxLoss: 100},
+	{Depth: 5, Margin: 10, BlunderChance: 0.02, MaxLoss: 60},
+	{}, // Full strength
+}
+
+// LevelStrength returns the strength of a bot level. Levels out of range are clamped.
+func LevelStrength(level int) Strength {
+	level = min(max(level, MinLevel), MaxLevel)
+	return levels[level-1]
+}
+
+// weakened reports whether the strength deviates from the best move at all.
+func (st Strength) weakened() bool {
+	return st.Margin > 0 || st.BlunderChance > 0
+}
+
+// limit narrows the search limits down to the strength's caps.
+func (st Strength) limit(l Limits) Limits {
+	if st.Depth > 0 && (l.Depth <= 0 || l.Depth > st.Depth) {
+		l.Depth = st.Depth
+	}
+	if st.Nodes > 0 && (l.Nodes <= 0 || l.Nodes > st.Nodes) {
+		l.Nodes = st.Nodes
+	}
+	if st.MoveTime > 0 && (l.MoveTime <= 0 || l.MoveTime > st.MoveTime) {
+		l.MoveTime = st.MoveTime
+	}
+	return l
+}
+
+// pick chooses the move to play among root moves sorted best first. Forced mates are never thrown away.
+// Otherwise, with probability BlunderChance a move losing between Margin and MaxLoss is played,
+// and else a move within Margin of the best, closer moves being more likely.
+func (st Strength) pick(scored []scoredMove, rng *rand.Rand) scoredMove {
+	best := scored[0]
+	if len(scored) == 1 || (IsMate(best.score) && best.score > 0) {
+		return best
+	}
+
+	if rng.Float64() < st.BlunderChance {
+		var worse []scoredMove
+		for _, sm := range scored {
+			if loss := best.score - sm.score; loss > st.Margin && loss <= st.MaxLoss {
+				worse = append(worse, sm)
+			}
+		}
+		if len(worse) > 0 {
+			return worse[rng.IntN(len(worse))]
+		}
+	}
+
+	total := 0
+	weights := make([]int, 0, len(scored))
+	for _, sm := range scored {
+		loss := best.score - sm.score
+		if loss > st.Margin {
+			break
+		}
+		weights = append(weights, st.Margin-loss+1)
+		total += st.Margin - loss + 1
+	}
+	n := rng.IntN(total)
+	for i, w := range weights {
+		if n < w {
+			return scored[i]
+		}
+		n -= w
+	}
+	return best
+}
@@ -0,0 +1,349 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	// maxPly bounds the search tree height (including quiescence) and sizes the killer table.
+	maxPly = 64
+	// checkEvery is how often (in nodes) the search looks at the clock and the context.
+	checkEvery = 1024
+	// deltaMargin is the safety margin of delta pruning in the quiescence search.
+	deltaMargin = 200
+	// infinity is larger than any possible score.
+	infinity = MateScore + 1
+)
+
+// Move ordering priorities; captures are ordered by MVV-LVA within their band.
+const (
+	orderTTMove  = 1 << 30
+	orderCapture = 1 << 24
+	orderKiller  = 1 << 20
+)
+
+// searcher holds the state of one Search call.
+type searcher struct {
+	ctx      context.Context
+	deadline time.Time
+	maxNodes int64
+
+	nodes     int64
+	stopped   bool
+	unbounded bool // limits are ignored while the first iteration runs
+
+	// scoreAll makes the root search every move with a full window, so that rootScores holds
+	// exact scores for all of them (needed to play weaker on purpose, see Strength).
+	scoreAll   bool
+	rootScores []scoredMove
+
+	tt      *transpositionTable
+	killers [maxPly][2]moveKey
+	history [64][64]int
+	// path holds the hashes of the positions before the current node (game history + search path),
+	// used to detect repetitions.
+	path []uint64
+}
+
+// newSearcher prepares a search bounded by the deadline and (if positive) a node budget.
+func newSearcher(ctx context.Context, deadline time.Time, maxNodes int64) *searcher {
+	return &searcher{
+		ctx:      ctx,
+		deadline: deadline,
+		maxNodes: maxNodes,
+		tt:       newTranspositionTable(),
+	}
+}
+
+// iterate runs the iterative deepening loop up to maxDepth. The first iteration always completes,
+// so a legal move is returned even with a tiny budget.
+func (s *searcher) iterate(pos *chess.Position, check bool, maxDepth int) *Result {
+	var best *Result
+	for depth := 1; depth <= maxDepth; depth++ {
+		s.unbounded = best == nil
+		score, mv, scores := s.root(pos, check, depth)
+		if mv == nil || s.stopped {
+			break
+		}
+		best = &Result{Move: mv, Score: score, Depth: depth}
+		s.rootScores = scores
+		if IsMate(score) {
+			break
+		}
+	}
+	best.Nodes = s.nodes
+	best.PV = s.principalVariation(pos, best.Move, best.Depth)
+	return best
+}
+
+// root searches all moves of the root position to the given depth and returns the best one together
+// with the scores of all moves, best first (only bounds unless scoreAll is set). The root itself
+// is never pruned, but being in check extends the search like in any other node.
+func (s *searcher) root(pos *chess.Position, check bool, depth int) (int, *chess.Move, []scoredMove) {
+	hash := hashPosition(pos)
+	moves := pos.ValidMoves()
+	s.orderMoves(pos, moves, s.ttMove(hash), 0)
+
+	alpha, beta := -infinity, infinity
+	var bestMove *chess.Move
+	s.path = append(s.path, hash)
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	if check {
+		depth++
+	}
+	scores := make([]scoredMove, 0, len(moves))
+	for _, mv := range moves {
+		childBeta := -alpha
+		if s.scoreAll {
+			childBeta = infinity
+		}
+		score := -s.negamax(pos.Update(mv), depth-1, 1, -beta, childBeta, mv.HasTag(chess.Check))
+		if s.stopped {
+			return 0, nil, nil
+		}
+		scores = append(scores, scoredMove{move: mv, score: score})
+		if bestMove == nil || score > alpha {
+			alpha, bestMove = score, mv
+		}
+	}
+	s.tt.store(hash, depth, alpha, boundExact, keyOf(bestMove))
+	slices.SortStableFunc(scores, func(a, b scoredMove) int { return b.score - a.score })
+	return alpha, bestMove, scores
+}
+
+// negamax is a fail-hard alpha-beta search returning the score from the side to move's point of view.
+func (s *searcher) negamax(pos *chess.Position, depth, ply, alpha, beta int, check bool) int {
+	if s.shouldStop() {
+		return 0
+	}
+	hash := hashPosition(pos)
+	if s.isRepetition(hash, pos.HalfMoveClock()) || pos.HalfMoveClock() >= 100 {
+		return 0
+	}
+
+	// Extend the search when in check, so that short mating sequences are not cut off.
+	if check {
+		depth++
+	}
+	if depth <= 0 || ply >= maxPly {
+		return s.quiesce(pos, ply, alpha, beta, check)
+	}
+
+	ttMove := moveKey(0)
+	if e, ok := s.tt.probe(hash); ok {
+		ttMove = e.move
+		if int(e.depth) >= depth {
+			score := scoreFromTT(int(e.score), ply)
+			switch {
+			case e.bound == boundExact,
+				e.bound == boundLower && score >= beta,
+				e.bound == boundUpper && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if check {
+			return -MateScore + ply
+		}
+		return 0
+	}
+	s.orderMoves(pos, moves, ttMove, ply)
+
+	s.path = append(s.path, hash)
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	bound := boundUpper
+	var bestMove *chess.Move
+	for _, mv := range moves {
+		score := -s.negamax(pos.Update(mv), depth-1, ply+1, -beta, -alpha, mv.HasTag(chess.Check))
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			if !isTactical(mv) {
+				s.rememberQuiet(mv, depth, ply)
+			}
+			s.tt.store(hash, depth, scoreToTT(beta, ply), boundLower, keyOf(mv))
+			return beta
+		}
+		if score > alpha {
+			alpha, bestMove, bound = score, mv, boundExact
+		}
+	}
+	s.tt.store(hash, depth, scoreToTT(alpha, ply), bound, keyOf(bestMove))
+	return alpha
+}
+
+// quiesce extends the search along captures and promotions only, so that the static evaluation
+// is never taken in the middle of an exchange. A side in check has to consider all its evasions.
+func (s *searcher) quiesce(pos *chess.Position, ply, alpha, beta int, check bool) int {
+	if s.shouldStop() {
+		return 0
+	}
+
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -MateScore + ply
+		}
+		return 0
+	}
+
+	standPat := evaluate(pos)
+	if ply >= maxPly {
+		return min(max(standPat, alpha), beta)
+	}
+	candidates := moves
+	if !check {
+		if standPat >= beta {
+			return beta
+		}
+		alpha = max(alpha, standPat)
+
+		candidates = moves[:0]
+		for _, mv := range moves {
+			if isTactical(mv) {
+				candidates = append(candidates, mv)
+			}
+		}
+	}
+	s.orderMoves(pos, candidates, 0, ply)
+
+	board := pos.Board()
+	for _, mv := range candidates {
+		// Delta pruning: even winning the captured piece for free would not reach alpha.
+		if !check && mv.Promo() == chess.NoPieceType && standPat+capturedValue(board, mv)+deltaMargin < alpha {
+			continue
+		}
+		score := -s.quiesce(pos.Update(mv), ply+1, -beta, -alpha, mv.HasTag(chess.Check))
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			return beta
+		}
+		alpha = max(alpha, score)
+	}
+	return alpha
+}
+
+// shouldStop counts the node and, every checkEvery nodes, checks the deadline, the node budget
+// and the context. Once it returns true, the search unwinds.
+func (s *searcher) shouldStop() bool {
+	s.nodes++
+	if s.stopped {
+		return true
+	}
+	if s.unbounded {
+		return false
+	}
+	if s.maxNodes > 0 && s.nodes >= s.maxNodes {
+		s.stopped = true
+	} else if s.nodes%checkEvery == 0 && (time.Now().After(s.deadline) || s.ctx.Err() != nil) {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+// isRepetition reports whether the position already occurred since the last irreversible move.
+func (s *searcher) isRepetition(hash uint64, halfMoveClock int) bool {
+	for i := len(s.path) - 1; i >= 0 && i >= len(s.path)-halfMoveClock; i-- {
+		if s.path[i] == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// ttMove returns the best move stored for the position, if any.
+func (s *searcher) ttMove(hash uint64) moveKey {
+	if e, ok := s.tt.probe(hash); ok {
+		return e.move
+	}
+	return 0
+}
+
+// orderMoves sorts moves so that the most promising ones are searched first:
+// the TT move, then captures and promotions (MVV-LVA), then killers, then by history.
+func (s *searcher) orderMoves(pos *chess.Position, moves []*chess.Move, ttMove moveKey, ply int) {
+	board := pos.Board()
+	scored := make([]scoredMove, len(moves))
+	for i, mv := range moves {
+		scored[i].move = mv
+		key := keyOf(mv)
+		switch {
+		case key == ttMove && ttMove != 0:
+			scored[i].score = orderTTMove
+		case isTactical(mv):
+			scored[i].score = orderCapture + 10*capturedValue(board, mv) + pieceValues[mv.Promo()] -
+				pieceValues[board.Piece(mv.S1()).Type()]/10
+		case ply < maxPly && key == s.killers[ply][0]:
+			scored[i].score = orderKiller + 1
+		case ply < maxPly && key == s.killers[ply][1]:
+			scored[i].score = orderKiller
+		default:
+			scored[i].score = s.history[mv.S1()][mv.S2()]
+		}
+	}
+	slices.SortStableFunc(scored, func(a, b scoredMove) int { return b.score - a.score })
+	for i := range scored {
+		moves[i] = scored[i].move
+	}
+}
+
+// scoredMove pairs a move with its ordering priority.
+type scoredMove struct {
+	move  *chess.Move
+	score int
+}
+
+// rememberQuiet records a quiet move that caused a beta cutoff as a killer and in the history table.
+func (s *searcher) rememberQuiet(mv *chess.Move, depth, ply int) {
+	if ply < maxPly {
+		if key := keyOf(mv); s.killers[ply][0] != key {
+			s.killers[ply][1], s.killers[ply][0] = s.killers[ply][0], key
+		}
+	}
+	s.history[mv.S1()][mv.S2()] = min(s.history[mv.S1()][mv.S2()]+depth*depth, orderKiller-1)
+}
+
+// principalVariation follows the transposition table from the root to rebuild the expected line.
+func (s *searcher) principalVariation(pos *chess.Position, first *chess.Move, depth int) []*chess.Move {
+	pv := []*chess.Move{first}
+	pos = pos.Update(first)
+	for len(pv) < depth {
+		key := s.ttMove(hashPosition(pos))
+		if key == 0 {
+			break
+		}
+		idx := slices.IndexFunc(pos.ValidMoves(), func(mv *chess.Move) bool { return keyOf(mv) == key })
+		if idx < 0 {
+			break
+		}
+		mv := pos.ValidMoves()[idx]
+		pv = append(pv, mv)
+		pos = pos.Update(mv)
+	}
+	return pv
+}
+
+// isTactical reports whether a move is a capture or a promotion.
+func isTactical(mv *chess.Move) bool {
+	return mv.HasTag(chess.Capture) || mv.HasTag(chess.EnPassant) || mv.Promo() != chess.NoPieceType
+}
+
+// capturedValue is the material value of the piece a move captures (a pawn for en passant).
+func capturedValue(board *chess.Board, mv *chess.Move) int {
+	if mv.HasTag(chess.EnPassant) {
+		return pieceValues[chess.Pawn]
+	}
+	return pieceValues[board.Piece(mv.S2()).Type()]
+}
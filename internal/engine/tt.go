@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Zobrist keys: one per (piece, square), plus side to move, castling rights and en-passant file.
+// They are generated from a fixed seed, so hashes are stable between runs.
+var (
+	zobristPieces    [13][64]uint64
+	zobristBlack     uint64
+	zobristCastle    [4]uint64
+	zobristEnPassant [8]uint64
+)
+
+func init() {
+	rng := rand.New(rand.NewPCG(0x6c766c6368657373, 0x656e67696e65))
+	for p := range zobristPieces {
+		for sq := range zobristPieces[p] {
+			zobristPieces[p][sq] = rng.Uint64()
+		}
+	}
+	zobristBlack = rng.Uint64()
+	for i := range zobristCastle {
+		zobristCastle[i] = rng.Uint64()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = rng.Uint64()
+	}
+}
+
+// hashPosition computes the Zobrist hash of a position.
+func hashPosition(pos *chess.Position) uint64 {
+	board := pos.Board()
+	var h uint64
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		if p := board.Piece(sq); p != chess.NoPiece {
+			h ^= zobristPieces[p][sq]
+		}
+	}
+	if pos.Turn() == chess.Black {
+		h ^= zobristBlack
+	}
+	castle := pos.CastleRights().String()
+	for i, r := range "KQkq" {
+		if strings.ContainsRune(castle, r) {
+			h ^= zobristCastle[i]
+		}
+	}
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		h ^= zobristEnPassant[ep.File()]
+	}
+	return h
+}
+
+// Bound kinds of a transposition table entry.
+const (
+	boundExact uint8 = iota + 1
+	boundLower       // fail-high: the real score is at least Score
+	boundUpper       // fail-low: the real score is at most Score
+)
+
+// ttSize is the number of transposition table entries per search (a power of two).
+const ttSize = 1 << 17
+
+// moveKey is a compact move encoding (from, to, promotion) kept in the transposition table.
+type moveKey uint16
+
+// keyOf encodes a move as a moveKey; nil becomes the zero key.
+func keyOf(mv *chess.Move) moveKey {
+	if mv == nil {
+		return 0
+	}
+	return moveKey(mv.S1()) | moveKey(mv.S2())<<6 | moveKey(mv.Promo())<<12
+}
+
+// ttEntry is a single transposition table slot.
+type ttEntry struct {
+	key   uint64
+	score int32
+	move  moveKey
+	depth int8
+	bound uint8
+}
+
+// transpositionTable is a fixed-size, always-replace-if-not-shallower hash table.
+type transpositionTable struct {
+	entries []ttEntry
+}
+
+// newTranspositionTable allocates an empty table.
+func newTranspositionTable() *transpositionTable {
+	return &transpositionTable{entries: make([]ttEntry, ttSize)}
+}
+
+// probe returns the entry stored for the hash, if any.
+func (t *transpositionTable) probe(hash uint64) (ttEntry, bool) {
+	e := t.entries[hash&(ttSize-1)]
+	return e, e.bound != 0 && e.key == hash
+}
+
+// store saves a search result. An entry of another position is always replaced;
+// an entry of the same position only by a search that was at least as deep.
+func (t *transpositionTable) store(hash uint64, depth, score int, bound uint8, mv moveKey) {
+	e := &t.entries[hash&(ttSize-1)]
+	if e.bound != 0 && e.key == hash && int(e.depth) > depth {
+		return
+	}
+	*e = ttEntry{key: hash, score: int32(score), move: mv, depth: int8(depth), bound: bound}
+}
+
+// scoreToTT converts a mate score relative to the root into one relative to the stored node,
+// so that it stays correct when the position is reached at another ply.
+func scoreToTT(score, ply int) int {
+	switch {
+	case score >= mateThreshold:
+		return score + ply
+	case score <= -mateThreshold:
+		return score - ply
+	}
+	return score
+}
+
+// scoreFromTT is the inverse of scoreToTT.
+func scoreFromTT(score, ply int) int {
+	switch {
+	case score >= mateThreshold:
+		return score - ply
+	case score <= -mateThreshold:
+		return score + ply
+	}
+	return score
+}
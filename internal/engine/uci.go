@@ -0,0 +1,561 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	// DefaultUCIPoolSize is the number of engine processes used when UCIConfig.PoolSize is not set.
+	DefaultUCIPoolSize = 2
+	// DefaultUCIHandshakeTimeout bounds the "uci"/"isready" handshake of a freshly started process.
+	DefaultUCIHandshakeTimeout = 10 * time.Second
+
+	// uciGrace is how long an engine may overrun its move time before it is told to stop.
+	uciGrace = 2 * time.Second
+	// uciStopGrace is how long an engine may take to answer "stop" before it is considered hung.
+	uciStopGrace = 2 * time.Second
+	// uciMultiPV is the number of lines requested when playing at a reduced level,
+	// so that the level can choose among several scored moves.
+	uciMultiPV = 8
+)
+
+// errUCIProcess marks failures of the engine process itself (crash, hang, broken pipe).
+// The process is then replaced and the search retried once.
+var errUCIProcess = errors.New("uci engine process failure")
+
+// UCIConfig describes an external engine binary speaking the UCI protocol.
+type UCIConfig struct {
+	Path             string            // Executable, either a path or a name looked up in $PATH
+	Args             []string          // Command line arguments
+	PoolSize         int               // Number of engine processes; 0 = DefaultUCIPoolSize
+	Options          map[string]string // Sent as "setoption name <key> value <value>" after the handshake
+	HandshakeTimeout time.Duration     // 0 = DefaultUCIHandshakeTimeout
+}
+
+/*
+UCI is an Engine backed by a pool of external engine processes (e.g. Stockfish).
+Each search borrows one process; a process that crashes, hangs or misbehaves is killed
+and replaced by a fresh one, and the search is retried once. Processes are started lazily,
+except for the first one, which NewUCI starts to make sure the binary works.
+*/
+type UCI struct {
+	cfg   UCIConfig
+	name  string           // "id name" of the first process, see Name
+	slots chan *uciProcess // idle processes; nil means "free slot, start a process on demand"
+}
+
+// NewUCI validates the configuration and starts the first engine process.
+func NewUCI(cfg UCIConfig) (*UCI, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("NewUCI: empty engine path")
+	}
+	path, err := exec.LookPath(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("NewUCI: %w", err)
+	}
+	cfg.Path = path
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = DefaultUCIPoolSize
+	}
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = DefaultUCIHandshakeTimeout
+	}
+
+	first, err := startUCIProcess(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("NewUCI: %w", err)
+	}
+	u := &UCI{cfg: cfg, name: first.name, slots: make(chan *uciProcess, cfg.PoolSize)}
+	u.slots <- first
+	for i := 1; i < cfg.PoolSize; i++ {
+		u.slots <- nil
+	}
+	return u, nil
+}
+
+// Name returns the engine's self-reported name ("id name ..."), e.g. "Stockfish 16", as read at start-up.
+func (u *UCI) Name() string {
+	if u.name != "" {
+		return u.name
+	}
+	return u.cfg.Path
+}
+
+// Search implements Engine. At a reduced Limits.Level the engine is asked for several lines (MultiPV)
+// and the move is chosen among them like the built-in engine does, see Strength.
+// A process that last searched another Limits.Game is sent "ucinewgame" first.
+func (u *UCI) Search(ctx context.Context, chGame *chess.Game, limits Limits) (*Result, error) {
+	if len(chGame.Position().ValidMoves()) == 0 {
+		return nil, ErrNoMoves
+	}
+	var strength Strength
+	if limits.Level > 0 {
+		strength = LevelStrength(limits.Level)
+		limits = strength.limit(limits)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		p, err := u.acquire(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("uci acquire: %w", err)
+		}
+		res, err := p.search(ctx, chGame, limits, strength)
+		u.release(p, errors.Is(err, errUCIProcess))
+		if err == nil {
+			return res, nil
+		}
+		lastErr = err
+		if !errors.Is(err, errUCIProcess) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("uci search: %w", lastErr)
+}
+
+// Close stops all engine processes, waiting for running searches to finish first.
+func (u *UCI) Close() error {
+	for i := 0; i < u.cfg.PoolSize; i++ {
+		if p := <-u.slots; p != nil {
+			p.quit()
+		}
+	}
+	return nil
+}
+
+// acquire borrows an idle process from the pool, starting (or restarting) one if needed.
+func (u *UCI) acquire(ctx context.Context) (*uciProcess, error) {
+	select {
+	case p := <-u.slots:
+		if p != nil && p.alive() {
+			return p, nil
+		}
+		p, err := startUCIProcess(u.cfg)
+		if err != nil {
+			u.slots <- nil
+			return nil, err
+		}
+		return p, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release returns a process to the pool. A broken process is killed and its slot freed.
+func (u *UCI) release(p *uciProcess, broken bool) {
+	if broken {
+		p.kill()
+		p = nil
+	}
+	u.slots <- p
+}
+
+// uciProcess is one running engine binary.
+type uciProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string   // stdout, line by line; closed at EOF
+	exited  chan struct{} // closed when the process has exited
+	name    string
+	multiPV int
+	game    string // Limits.Game of the last search; "ucinewgame" is sent when it changes
+}
+
+// startUCIProcess starts the binary and performs the UCI handshake.
+func startUCIProcess(cfg UCIConfig) (*uciProcess, error) {
+	cmd := exec.Command(cfg.Path, cfg.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", cfg.Path, err)
+	}
+
+	p := &uciProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string, 256),
+		exited:  make(chan struct{}),
+		multiPV: 1,
+	}
+	go func() {
+		readLines(stdout, p.lines)
+		_ = cmd.Wait()
+		close(p.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HandshakeTimeout)
+	defer cancel()
+	if err = p.handshake(ctx, cfg.Options); err != nil {
+		p.kill()
+		return nil, err
+	}
+	return p, nil
+}
+
+// handshake switches the engine to UCI mode, applies the options and waits until it is ready.
+func (p *uciProcess) handshake(ctx context.Context, options map[string]string) error {
+	if err := p.send("uci"); err != nil {
+		return err
+	}
+	for {
+		line, err := p.readLine(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for uciok: %w", err)
+		}
+		if name, ok := strings.CutPrefix(line, "id name "); ok {
+			p.name = name
+		}
+		if line == "uciok" {
+			break
+		}
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := p.send(fmt.Sprintf("setoption name %s value %s", name, options[name])); err != nil {
+			return err
+		}
+	}
+	return p.sync(ctx)
+}
+
+// sync sends "isready" and waits for "readyok".
+func (p *uciProcess) sync(ctx context.Context) error {
+	if err := p.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := p.readLine(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for readyok: %w", err)
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// uciLine is the latest "info" of one principal variation (MultiPV index).
+type uciLine struct {
+	depth int
+	score int
+	pv    []string
+}
+
+// search runs one "go" command for the current position of chGame.
+func (p *uciProcess) search(ctx context.Context, chGame *chess.Game, limits Limits, strength Strength) (*Result, error) {
+	multiPV := 1
+	if strength.weakened() {
+		multiPV = uciMultiPV
+	}
+	if limits.Game == "" || limits.Game != p.game {
+		// A new game: the engine must not reuse what it learnt about the previous one (hash, history).
+		if err := p.send("ucinewgame"); err != nil {
+			return nil, err
+		}
+		syncCtx, cancel := context.WithTimeout(ctx, DefaultUCIHandshakeTimeout)
+		err := p.sync(syncCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("%w: %v", errUCIProcess, err) // no "readyok" in time: hung
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.game = limits.Game
+	}
+	if multiPV != p.multiPV {
+		if err := p.send(fmt.Sprintf("setoption name MultiPV value %d", multiPV)); err != nil {
+			return nil, err
+		}
+		p.multiPV = multiPV
+	}
+
+	moveTime := limits.MoveTime
+	if moveTime <= 0 {
+		moveTime = DefaultMoveTime
+	}
+	goCmd := fmt.Sprintf("go movetime %d", moveTime.Milliseconds())
+	if limits.Depth > 0 {
+		goCmd += fmt.Sprintf(" depth %d", limits.Depth)
+	}
+	if limits.Nodes > 0 {
+		goCmd += fmt.Sprintf(" nodes %d", limits.Nodes)
+	}
+	if err := p.send(positionCommand(chGame)); err != nil {
+		return nil, err
+	}
+	if err := p.send(goCmd); err != nil {
+		return nil, err
+	}
+
+	// Wait for "bestmove". If the caller gives up or the engine overruns its time, it is told to stop;
+	// if it does not answer that either, it is considered hung.
+	timeout := time.NewTimer(moveTime + uciGrace)
+	defer timeout.Stop()
+	cancelled := ctx.Done()
+	stopSent := false
+	lines := make(map[int]*uciLine)
+	var nodes int64
+	for {
+		var line string
+		select {
+		case l, ok := <-p.lines:
+			if !ok {
+				return nil, fmt.Errorf("%w: engine exited", errUCIProcess)
+			}
+			line = l
+		case <-cancelled:
+		case <-timeout.C:
+		}
+		if line == "" {
+			if stopSent {
+				return nil, fmt.Errorf("%w: no answer to stop", errUCIProcess)
+			}
+			// Deadline or cancellation: ask for the best move found so far.
+			stopSent, cancelled = true, nil
+			if err := p.send("stop"); err != nil {
+				return nil, err
+			}
+			timeout.Reset(uciStopGrace)
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			idx, info, n := parseInfo(fields)
+			if n > 0 {
+				nodes = n
+			}
+			if info != nil {
+				lines[idx] = info
+			}
+		case "bestmove":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%w: malformed %q", errUCIProcess, line)
+			}
+			return buildUCIResult(chGame.Position(), fields[1], lines, nodes, strength)
+		}
+	}
+}
+
+// buildUCIResult converts the engine's answer into a Result. At a reduced level the move is picked
+// among the reported lines; otherwise it is the engine's "bestmove".
+func buildUCIResult(pos *chess.Position, best string, lines map[int]*uciLine, nodes int64, strength Strength) (*Result, error) {
+	move := findMove(pos, best)
+	if move == nil {
+		return nil, fmt.Errorf("engine returned illegal move %q", best)
+	}
+	res := &Result{Move: move, Nodes: nodes}
+	if top, ok := lines[1]; ok {
+		res.Score, res.Depth = top.score, top.depth
+		res.PV = decodePV(pos, top.pv)
+	}
+
+	if strength.weakened() && len(lines) > 0 {
+		var scored []scoredMove
+		for _, l := range lines {
+			if len(l.pv) > 0 {
+				if mv := findMove(pos, l.pv[0]); mv != nil {
+					scored = append(scored, scoredMove{move: mv, score: l.score})
+				}
+			}
+		}
+		if len(scored) > 0 {
+			slices.SortStableFunc(scored, func(a, b scoredMove) int { return b.score - a.score })
+			chosen := strength.pick(scored, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+			res.Move, res.Score = chosen.move, chosen.score
+			for _, l := range lines {
+				if len(l.pv) > 0 && l.pv[0] == chosen.move.String() {
+					res.PV = decodePV(pos, l.pv)
+				}
+			}
+		}
+	}
+	if len(res.PV) == 0 || res.PV[0].String() != res.Move.String() {
+		res.PV = []*chess.Move{res.Move}
+	}
+	return res, nil
+}
+
+// parseInfo extracts the MultiPV index, depth, score and PV of an "info" line, plus the node count.
+// info is nil for lines without a score or a PV (e.g. "info string ..." or "info currmove ...").
+func parseInfo(fields []string) (multiPV int, info *uciLine, nodes int64) {
+	multiPV = 1
+	l := &uciLine{}
+	hasScore := false
+	for i := 1; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				i++
+				return fields[i]
+			}
+			return ""
+		}
+		switch fields[i] {
+		case "string":
+			return multiPV, nil, nodes // free text till the end of the line
+		case "depth":
+			l.depth, _ = strconv.Atoi(next())
+		case "multipv":
+			multiPV, _ = strconv.Atoi(next())
+		case "nodes":
+			nodes, _ = strconv.ParseInt(next(), 10, 64)
+		case "score":
+			kind := next()
+			v, err := strconv.Atoi(next())
+			if err != nil {
+				continue
+			}
+			hasScore = true
+			switch kind {
+			case "cp":
+				l.score = v
+			case "mate":
+				if v > 0 {
+					l.score = MateScore - (2*v - 1)
+				} else {
+					l.score = -MateScore - 2*v
+				}
+			}
+		case "pv":
+			l.pv = fields[i+1:]
+			i = len(fields)
+		}
+	}
+	if !hasScore || len(l.pv) == 0 {
+		return multiPV, nil, nodes
+	}
+	return multiPV, l, nodes
+}
+
+// positionCommand encodes the game as "position fen <start> moves <uci>...", so that the engine
+// sees the full history and can detect repetitions.
+func positionCommand(chGame *chess.Game) string {
+	var sb strings.Builder
+	sb.WriteString("position fen ")
+	sb.WriteString(chGame.Positions()[0].String())
+	if moves := chGame.Moves(); len(moves) > 0 {
+		sb.WriteString(" moves")
+		for _, mv := range moves {
+			sb.WriteString(" ")
+			sb.WriteString(mv.String())
+		}
+	}
+	return sb.String()
+}
+
+// findMove returns the legal move of pos written in UCI notation, or nil.
+func findMove(pos *chess.Position, uci string) *chess.Move {
+	for _, mv := range pos.ValidMoves() {
+		if mv.String() == uci {
+			return mv
+		}
+	}
+	return nil
+}
+
+// decodePV turns a PV in UCI notation into moves, stopping at the first move that is not legal.
+func decodePV(pos *chess.Position, pv []string) []*chess.Move {
+	var moves []*chess.Move
+	for _, s := range pv {
+		mv := findMove(pos, s)
+		if mv == nil {
+			break
+		}
+		moves = append(moves, mv)
+		pos = pos.Update(mv)
+	}
+	return moves
+}
+
+// readLines copies the engine's output into ch line by line and closes ch at EOF.
+func readLines(r io.Reader, ch chan<- string) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			ch <- line
+		}
+	}
+	close(ch)
+}
+
+// send writes a command line to the engine.
+func (p *uciProcess) send(cmd string) error {
+	if _, err := p.stdin.Write([]byte(cmd + "\n")); err != nil {
+		return fmt.Errorf("%w: write %q: %v", errUCIProcess, cmd, err)
+	}
+	return nil
+}
+
+// readLine waits for the next output line. A process that exits yields errUCIProcess.
+func (p *uciProcess) readLine(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return "", fmt.Errorf("%w: engine exited", errUCIProcess)
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// alive reports whether the process is still running.
+func (p *uciProcess) alive() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// quit asks the engine to exit and kills it if it does not.
+func (p *uciProcess) quit() {
+	_ = p.send("quit")
+	select {
+	case <-p.exited:
+	case <-time.After(time.Second):
+		p.kill()
+	}
+}
+
+// kill terminates the process. Its output is drained, so the reader goroutine can finish.
+func (p *uciProcess) kill() {
+	_ = p.stdin.Close()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	go func() {
+		for range p.lines {
+		}
+	}()
+}
@@ -0,0 +1,355 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// The test binary doubles as a fake UCI engine: started with fakeEngineEnv set, TestMain runs
+// fakeEngine on stdin/stdout instead of the tests.
+const (
+	fakeEngineEnv   = "LVLCHESS_FAKE_UCI"        // mode: "ok" or "crash-once"
+	fakeEngineLog   = "LVLCHESS_FAKE_UCI_LOG"    // file every received command is appended to
+	fakeEngineCrash = "LVLCHESS_FAKE_UCI_MARKER" // crash-once: the first process to "go" creates it and dies
+)
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeEngineEnv); mode != "" {
+		os.Exit(fakeEngine(mode))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeEngine answers the UCI commands the adapter sends. "go" reports one line per legal move
+// (up to MultiPV), the first move scoring best, and plays that move.
+func fakeEngine(mode string) int {
+	logFile, err := os.OpenFile(os.Getenv(fakeEngineLog), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 2
+	}
+	defer logFile.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	say := func(format string, args ...any) {
+		fmt.Fprintf(out, format+"\n", args...)
+		out.Flush()
+	}
+	multiPV := 1
+	var chGame *chess.Game
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		line := in.Text()
+		fmt.Fprintln(logFile, line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			say("id name Fake Engine 1.0")
+			say("id author lvlchess")
+			say("option name MultiPV type spin default 1 min 1 max 500")
+			say("uciok")
+		case "isready":
+			say("readyok")
+		case "setoption":
+			if len(fields) == 5 && fields[2] == "MultiPV" {
+				multiPV, _ = strconv.Atoi(fields[4])
+			}
+		case "position":
+			chGame = fakePosition(fields)
+		case "go":
+			if mode == "crash-once" {
+				if f, err := os.OpenFile(os.Getenv(fakeEngineCrash), os.O_CREATE|os.O_EXCL, 0o644); err == nil {
+					f.Close()
+					return 1
+				}
+			}
+			moves := chGame.ValidMoves()
+			say("info string searching %d moves", len(moves))
+			for i := 0; i < multiPV && i < len(moves); i++ {
+				say("info depth 5 seldepth 7 multipv %d score cp %d nodes 4242 nps 1000 pv %s", i+1, 50-100*i, moves[i])
+			}
+			say("bestmove %s", moves[0])
+		case "quit":
+			return 0
+		}
+	}
+	return 0
+}
+
+// fakePosition decodes "position fen <6 fields> [moves ...]".
+func fakePosition(fields []string) *chess.Game {
+	fen, _ := chess.FEN(strings.Join(fields[2:8], " "))
+	chGame := chess.NewGame(fen)
+	if len(fields) > 9 {
+		for _, s := range fields[9:] {
+			chGame.Move(findMove(chGame.Position(), s))
+		}
+	}
+	return chGame
+}
+
+// newFakeUCI starts the adapter on the fake engine and returns it with the path of the command log.
+func newFakeUCI(t *testing.T, mode string, cfg UCIConfig) (*UCI, string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "commands.log")
+	t.Setenv(fakeEngineEnv, mode)
+	t.Setenv(fakeEngineLog, logPath)
+	t.Setenv(fakeEngineCrash, filepath.Join(dir, "crashed"))
+
+	cfg.Path = os.Args[0]
+	cfg.Args = []string{"-test.run=^$"}
+	u, err := NewUCI(cfg)
+	if err != nil {
+		t.Fatalf("NewUCI: %v", err)
+	}
+	return u, logPath
+}
+
+// commands returns the commands the fake engines have received so far.
+func commands(t *testing.T, logPath string) []string {
+	t.Helper()
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read command log: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func count(lines []string, line string) int {
+	n := 0
+	for _, l := range lines {
+		if l == line {
+			n++
+		}
+	}
+	return n
+}
+
+func TestUCIHandshake(t *testing.T) {
+	u, logPath := newFakeUCI(t, "ok", UCIConfig{
+		PoolSize: 1,
+		Options:  map[string]string{"Threads": "2", "Hash": "16"},
+	})
+	defer u.Close()
+
+	if got := u.Name(); got != "Fake Engine 1.0" {
+		t.Errorf("Name() = %q, want %q", got, "Fake Engine 1.0")
+	}
+	want := []string{"uci", "setoption name Hash value 16", "setoption name Threads value 2", "isready"}
+	if got := commands(t, logPath); !slices.Equal(got, want) {
+		t.Errorf("handshake commands = %q, want %q", got, want)
+	}
+}
+
+func TestUCINameWhileBusy(t *testing.T) {
+	u, _ := newFakeUCI(t, "ok", UCIConfig{PoolSize: 1})
+	defer u.Close()
+
+	// Hold the only process, as a running search does.
+	p, err := u.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.release(p, false)
+
+	done := make(chan string)
+	go func() { done <- u.Name() }()
+	select {
+	case name := <-done:
+		if name != "Fake Engine 1.0" {
+			t.Errorf("Name() = %q", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Name() blocked while the pool was busy")
+	}
+}
+
+func TestUCISearch(t *testing.T) {
+	u, logPath := newFakeUCI(t, "ok", UCIConfig{PoolSize: 1})
+	defer u.Close()
+
+	chGame := chess.NewGame()
+	res, err := u.Search(context.Background(), chGame, Limits{MoveTime: 10 * time.Millisecond, Game: "room-1"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	best := chGame.ValidMoves()[0]
+	if res.Move.String() != best.String() || res.Score != 50 || res.Depth != 5 || res.Nodes != 4242 {
+		t.Errorf("Search = %s score %d depth %d nodes %d, want %s score 50 depth 5 nodes 4242",
+			res.Move, res.Score, res.Depth, res.Nodes, best)
+	}
+
+	// Another search of the same game keeps the engine's state; a different game starts afresh.
+	chGame.Move(res.Move)
+	for _, game := range []string{"room-1", "room-2"} {
+		if _, err = u.Search(context.Background(), chGame, Limits{MoveTime: 10 * time.Millisecond, Game: game}); err != nil {
+			t.Fatalf("Search %s: %v", game, err)
+		}
+	}
+	got := commands(t, logPath)
+	if n := count(got, "ucinewgame"); n != 2 {
+		t.Errorf("ucinewgame sent %d times, want 2 (room-1, room-2): %q", n, got)
+	}
+	if !slices.Contains(got, "position fen "+chess.StartingPosition().String()+" moves "+res.Move.String()) {
+		t.Errorf("no position command with the played move: %q", got)
+	}
+}
+
+func TestUCISearchMultiPV(t *testing.T) {
+	u, logPath := newFakeUCI(t, "ok", UCIConfig{PoolSize: 1})
+	defer u.Close()
+
+	chGame := chess.NewGame()
+	res, err := u.Search(context.Background(), chGame, Limits{MoveTime: 10 * time.Millisecond, Level: MinLevel})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if !slices.Contains(commands(t, logPath), fmt.Sprintf("setoption name MultiPV value %d", uciMultiPV)) {
+		t.Error("MultiPV was not requested at a reduced level")
+	}
+	// The chosen move must be one of the reported lines, with that line's score.
+	for i, mv := range chGame.ValidMoves()[:uciMultiPV] {
+		if mv.String() == res.Move.String() {
+			if res.Score != 50-100*i {
+				t.Errorf("score of %s = %d, want %d", mv, res.Score, 50-100*i)
+			}
+			return
+		}
+	}
+	t.Errorf("move %s is not among the reported lines", res.Move)
+}
+
+func TestUCIRestartAfterCrash(t *testing.T) {
+	u, logPath := newFakeUCI(t, "crash-once", UCIConfig{PoolSize: 1})
+	defer u.Close()
+
+	res, err := u.Search(context.Background(), chess.NewGame(), Limits{MoveTime: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Search after a crash: %v", err)
+	}
+	if res.Move == nil {
+		t.Fatal("no move")
+	}
+	if n := count(commands(t, logPath), "uci"); n != 2 {
+		t.Errorf("%d processes started, want 2 (the crashed one and its replacement)", n)
+	}
+}
+
+func TestUCIClose(t *testing.T) {
+	u, logPath := newFakeUCI(t, "ok", UCIConfig{PoolSize: 2})
+
+	// Start the second, lazily created process too.
+	p1, err := u.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := u.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.release(p1, false)
+	u.release(p2, false)
+
+	if err = u.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, p := range []*uciProcess{p1, p2} {
+		select {
+		case <-p.exited:
+		case <-time.After(2 * time.Second):
+			t.Fatal("engine process still running after Close")
+		}
+	}
+	if n := count(commands(t, logPath), "quit"); n != 2 {
+		t.Errorf("quit sent %d times, want 2", n)
+	}
+}
+
+func TestUCISearchCancelledWhileWaiting(t *testing.T) {
+	u, _ := newFakeUCI(t, "ok", UCIConfig{PoolSize: 1})
+	defer u.Close()
+
+	p, err := u.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = u.Search(ctx, chess.NewGame(), Limits{})
+	u.release(p, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Search with a busy pool = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantMultiPV int
+		wantInfo    *uciLine
+		wantNodes   int64
+	}{
+		{
+			line:        "info depth 12 seldepth 18 multipv 1 score cp 34 nodes 123456 nps 1000000 pv e2e4 e7e5",
+			wantMultiPV: 1,
+			wantInfo:    &uciLine{depth: 12, score: 34, pv: []string{"e2e4", "e7e5"}},
+			wantNodes:   123456,
+		},
+		{
+			line:        "info depth 9 multipv 3 score cp -120 upperbound nodes 77 pv g1f3",
+			wantMultiPV: 3,
+			wantInfo:    &uciLine{depth: 9, score: -120, pv: []string{"g1f3"}},
+			wantNodes:   77,
+		},
+		{
+			line:        "info depth 20 multipv 2 score mate 3 pv d1h5",
+			wantMultiPV: 2,
+			wantInfo:    &uciLine{depth: 20, score: MateScore - 5, pv: []string{"d1h5"}},
+		},
+		{
+			line:        "info depth 20 score mate -2 pv e1e2",
+			wantMultiPV: 1,
+			wantInfo:    &uciLine{depth: 20, score: -MateScore + 4, pv: []string{"e1e2"}},
+		},
+		{
+			line:        "info depth 5 currmove e2e4 currmovenumber 1 nodes 900",
+			wantMultiPV: 1,
+			wantNodes:   900,
+		},
+		{
+			line:        "info string NNUE evaluation using nn.nnue score cp 10 pv e2e4",
+			wantMultiPV: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			multiPV, info, nodes := parseInfo(strings.Fields(tt.line))
+			if multiPV != tt.wantMultiPV || nodes != tt.wantNodes {
+				t.Errorf("multipv %d nodes %d, want %d and %d", multiPV, nodes, tt.wantMultiPV, tt.wantNodes)
+			}
+			switch {
+			case tt.wantInfo == nil && info != nil:
+				t.Errorf("info = %+v, want none", info)
+			case tt.wantInfo != nil && info == nil:
+				t.Errorf("no info, want %+v", tt.wantInfo)
+			case info != nil && (info.depth != tt.wantInfo.depth || info.score != tt.wantInfo.score ||
+				!slices.Equal(info.pv, tt.wantInfo.pv)):
+				t.Errorf("info = %+v, want %+v", info, tt.wantInfo)
+			}
+		})
+	}
+}
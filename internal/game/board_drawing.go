@@ -0,0 +1,316 @@
+package game
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/notnil/chess"
+)
+
+// Geometry of the board pictures: 8x8 squares of SquareSize pixels surrounded by a frame of boardMargin
+// pixels with the file letters below and the rank numbers on the left.
+const (
+	SquareSize  = 80
+	boardMargin = 24
+	BoardPixels = 8*SquareSize + 2*boardMargin
+
+	// Size of a coordinate glyph.
+	glyphScale  = 2
+	glyphWidth  = 5 * glyphScale
+	glyphHeight = 7 * glyphScale
+)
+
+// Colors of the board pictures.
+var (
+	lightSquareColor = color.NRGBA{240, 217, 181, 255}
+	darkSquareColor  = color.NRGBA{181, 136, 99, 255}
+	frameColor       = color.NRGBA{48, 46, 43, 255}
+	coordinateColor  = color.NRGBA{224, 224, 224, 255}
+	checkColor       = color.NRGBA{230, 20, 20, 255}
+)
+
+// Colors for markers and arrows: translucent, so that the squares and pieces stay visible.
+var (
+	LastMoveColor = color.NRGBA{205, 210, 106, 150} // Squares of the last move
+	GreenMark     = color.NRGBA{21, 120, 27, 170}   // Best move, good squares
+	RedMark       = color.NRGBA{200, 30, 30, 170}   // Threats, mistakes
+	BlueMark      = color.NRGBA{0, 48, 136, 170}    // Alternatives, hints
+	YellowMark    = color.NRGBA{230, 160, 0, 170}   // Inaccuracies, attention
+)
+
+// Arrow is an arrow drawn over the board from the center of one square to another (e.g. a suggested move).
+type Arrow struct {
+	From, To chess.Square
+	Color    color.NRGBA
+}
+
+// Marker fills a square with a translucent color.
+type Marker struct {
+	Square chess.Square
+	Color  color.NRGBA
+}
+
+/*
+BoardImage describes a board picture: the position, the side shown at the bottom, the last move
+and any markers and arrows (hints, the engine's best move, analysis).
+It is the single drawing model behind every visual output: RenderBoardSVG writes it as SVG,
+RenderBoardPNG rasterises the very same shapes.
+*/
+type BoardImage struct {
+	FEN         string      // Position to draw; empty means the standard initial position
+	Orientation chess.Color // Side at the bottom; chess.NoColor means White
+	LastMove    string      // Last move in UCI notation (e.g. "e2e4"), optional
+	Markers     []Marker
+	Arrows      []Arrow
+}
+
+// shapeKind enumerates the primitives a board drawing is made of.
+type shapeKind int
+
+const (
+	shapeRect    shapeKind = iota // Filled rectangle
+	shapeGlow                     // Radial gradient filling a rectangle, opaque in the middle
+	shapePiece                    // Piece sprite filling a rectangle
+	shapePolygon                  // Filled polygon (arrows)
+	shapeText                     // Coordinate character in a glyphWidth x glyphHeight box at rect.Min
+)
+
+// point is a position in pixels; fractional coordinates are kept for anti-aliasing.
+type point struct{ X, Y float64 }
+
+// shape is a single primitive of the drawing, in pixels. Shapes are painted in order.
+type shape struct {
+	kind   shapeKind
+	rect   image.Rectangle
+	points []point
+	color  color.NRGBA
+	piece  chess.Piece
+	char   rune
+}
+
+// shapes lays out the drawing: frame, squares, markers, check glow, pieces, arrows and coordinates.
+func (b BoardImage) shapes() ([]shape, error) {
+	chGame, err := parseFEN(b.FEN)
+	if err != nil {
+		return nil, err
+	}
+	pos := chGame.Position()
+	board := pos.Board()
+	flipped := b.Orientation == chess.Black
+
+	markers := b.Markers
+	if len(b.LastMove) >= 4 {
+		from, to := parseSquare(b.LastMove[0:2]), parseSquare(b.LastMove[2:4])
+		if from < 0 || to < 0 {
+			return nil, fmt.Errorf("invalid last move %q", b.LastMove)
+		}
+		markers = append([]Marker{{from, LastMoveColor}, {to, LastMoveColor}}, markers...)
+	}
+	checked, inCheck := CheckedKing(board, pos.Turn())
+
+	shapes := []shape{{kind: shapeRect, rect: image.Rect(0, 0, BoardPixels, BoardPixels), color: frameColor}}
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		squareColor := darkSquareColor
+		if (int(sq.File())+int(sq.Rank()))%2 == 1 {
+			squareColor = lightSquareColor
+		}
+		shapes = append(shapes, shape{kind: shapeRect, rect: squareRect(sq, flipped), color: squareColor})
+	}
+	for _, m := range markers {
+		shapes = append(shapes, shape{kind: shapeRect, rect: squareRect(m.Square, flipped), color: m.Color})
+	}
+	if inCheck {
+		shapes = append(shapes, shape{kind: shapeGlow, rect: squareRect(checked, flipped), color: checkColor})
+	}
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		if p := board.Piece(sq); p != chess.NoPiece {
+			shapes = append(shapes, shape{kind: shapePiece, rect: squareRect(sq, flipped), piece: p})
+		}
+	}
+	for _, a := range b.Arrows {
+		if a.From != a.To {
+			shapes = append(shapes, shape{kind: shapePolygon, points: arrowPolygon(a, flipped), color: a.Color})
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		file, rank := rune('a'+i), rune('8'-i)
+		if flipped {
+			file, rank = rune('h'-i), rune('1'+i)
+		}
+		x := boardMargin + i*SquareSize + (SquareSize-glyphWidth)/2
+		y := boardMargin + 8*SquareSize + (boardMargin-glyphHeight)/2
+		shapes = append(shapes, shape{kind: shapeText, rect: glyphRect(x, y), color: coordinateColor, char: file})
+		x = (boardMargin - glyphWidth) / 2
+		y = boardMargin + i*SquareSize + (SquareSize-glyphHeight)/2
+		shapes = append(shapes, shape{kind: shapeText, rect: glyphRect(x, y), color: coordinateColor, char: rank})
+	}
+	return shapes, nil
+}
+
+// squareRect returns the pixel rectangle of a square for the given orientation.
+func squareRect(sq chess.Square, flipped bool) image.Rectangle {
+	col, row := int(sq.File()), 7-int(sq.Rank())
+	if flipped {
+		col, row = 7-col, 7-row
+	}
+	x, y := boardMargin+col*SquareSize, boardMargin+row*SquareSize
+	return image.Rect(x, y, x+SquareSize, y+SquareSize)
+}
+
+// glyphRect returns the box of a coordinate glyph with its top-left corner at (x, y).
+func glyphRect(x, y int) image.Rectangle {
+	return image.Rect(x, y, x+glyphWidth, y+glyphHeight)
+}
+
+// arrowPolygon builds the outline of an arrow between the centers of two squares:
+// a shaft ending in a triangular head whose tip stops a little before the target center.
+func arrowPolygon(a Arrow, flipped bool) []point {
+	center := func(sq chess.Square) point {
+		r := squareRect(sq, flipped)
+		return point{float64(r.Min.X+r.Max.X) / 2, float64(r.Min.Y+r.Max.Y) / 2}
+	}
+	from, to := center(a.From), center(a.To)
+	const (
+		shaftWidth = SquareSize * 0.18
+		headWidth  = SquareSize * 0.5
+		headLength = SquareSize * 0.45
+		tipInset   = SquareSize * 0.15
+	)
+
+	dx, dy := to.X-from.X, to.Y-from.Y
+	length := math.Hypot(dx, dy)
+	ux, uy := dx/length, dy/length // direction
+	nx, ny := -uy, ux              // normal
+	tip := point{to.X - ux*tipInset, to.Y - uy*tipInset}
+	neck := point{tip.X - ux*headLength, tip.Y - uy*headLength}
+	at := func(p point, side float64) point {
+		return point{p.X + nx*side, p.Y + ny*side}
+	}
+	return []point{
+		at(from, shaftWidth/2),
+		at(neck, shaftWidth/2),
+		at(neck, headWidth/2),
+		tip,
+		at(neck, -headWidth/2),
+		at(neck, -shaftWidth/2),
+		at(from, -shaftWidth/2),
+	}
+}
+
+// coordinateGlyphs is a 5x7 bitmap font for the board coordinates.
+var coordinateGlyphs = map[rune][7]string{
+	'a': {".....", ".....", ".###.", "....#", ".####", "#...#", ".####"},
+	'b': {"#....", "#....", "####.", "#...#", "#...#", "#...#", "####."},
+	'c': {".....", ".....", ".###.", "#....", "#....", "#....", ".###."},
+	'd': {"....#", "....#", ".####", "#...#", "#...#", "#...#", ".####"},
+	'e': {".....", ".....", ".###.", "#...#", "#####", "#....", ".###."},
+	'f': {"..##.", ".#...", "####.", ".#...", ".#...", ".#...", ".#..."},
+	'g': {".....", ".####", "#...#", "#...#", ".####", "....#", ".###."},
+	'h': {"#....", "#....", "####.", "#...#", "#...#", "#...#", "#...#"},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {".###.", "#...#", "....#", "..##.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {".###.", "#....", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+}
+
+// ParseArrow converts a move in UCI notation ("g1f3") into an arrow of the given color.
+func ParseArrow(uci string, c color.NRGBA) (Arrow, error) {
+	if len(uci) < 4 {
+		return Arrow{}, fmt.Errorf("invalid arrow %q", uci)
+	}
+	from, to := parseSquare(uci[0:2]), parseSquare(uci[2:4])
+	if from < 0 || to < 0 {
+		return Arrow{}, fmt.Errorf("invalid arrow %q", uci)
+	}
+	return Arrow{From: from, To: to, Color: c}, nil
+}
+
+// ParseMarker converts a square name ("e4") into a marker of the given color.
+func ParseMarker(square string, c color.NRGBA) (Marker, error) {
+	sq := parseSquare(square)
+	if sq < 0 {
+		return Marker{}, fmt.Errorf("invalid square %q", square)
+	}
+	return Marker{Square: sq, Color: c}, nil
+}
+
+// parseSquare converts an algebraic square name ("e4") into a chess.Square, or -1 if it is invalid.
+func parseSquare(name string) chess.Square {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return -1
+	}
+	return chess.NewSquare(chess.File(name[0]-'a'), chess.Rank(name[1]-'1'))
+}
+
+// CheckedKing returns the square of the king of the given color and whether it is attacked,
+// i.e. whether that side is in check.
+func CheckedKing(board *chess.Board, c chess.Color) (chess.Square, bool) {
+	king := chess.NewPiece(chess.King, c)
+	for sq, p := range board.SquareMap() {
+		if p == king {
+			return sq, isAttacked(board, sq, c.Other())
+		}
+	}
+	return -1, false
+}
+
+// isAttacked reports whether a piece of color by attacks the square.
+func isAttacked(board *chess.Board, sq chess.Square, by chess.Color) bool {
+	file, rank := int(sq.File()), int(sq.Rank())
+	pieceAt := func(f, r int) chess.Piece {
+		if f < 0 || f > 7 || r < 0 || r > 7 {
+			return chess.NoPiece
+		}
+		return board.Piece(chess.NewSquare(chess.File(f), chess.Rank(r)))
+	}
+
+	// Pawns attack diagonally forward, so a white pawn attacking sq stands one rank below it.
+	pawnRank := rank - 1
+	if by == chess.Black {
+		pawnRank = rank + 1
+	}
+	for _, df := range []int{-1, 1} {
+		if pieceAt(file+df, pawnRank) == chess.NewPiece(chess.Pawn, by) {
+			return true
+		}
+	}
+
+	for _, d := range [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}} {
+		if pieceAt(file+d[0], rank+d[1]) == chess.NewPiece(chess.Knight, by) {
+			return true
+		}
+	}
+	for df := -1; df <= 1; df++ {
+		for dr := -1; dr <= 1; dr++ {
+			if (df != 0 || dr != 0) && pieceAt(file+df, rank+dr) == chess.NewPiece(chess.King, by) {
+				return true
+			}
+		}
+	}
+
+	// Sliding pieces: rooks and queens along files and ranks, bishops and queens along diagonals.
+	for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}} {
+		slider := chess.Rook
+		if d[0] != 0 && d[1] != 0 {
+			slider = chess.Bishop
+		}
+		for f, r := file+d[0], rank+d[1]; f >= 0 && f <= 7 && r >= 0 && r <= 7; f, r = f+d[0], r+d[1] {
+			p := pieceAt(f, r)
+			if p == chess.NoPiece {
+				continue
+			}
+			if p.Color() == by && (p.Type() == slider || p.Type() == chess.Queen) {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/notnil/chess"
+
+	"lvlchess/internal/db/models"
+)
+
+// TimeControlPreset is one of the time controls offered in the room setup keyboard.
+type TimeControlPreset struct {
+	Code        string        // Short code used in callback data, e.g. "5+3" or "d3"
+	Label       string        // Button label
+	Initial     time.Duration // Time per side at the start (real-time controls)
+	Increment   time.Duration // Time added after each move (real-time controls)
+	DaysPerMove int           // Correspondence only
+}
+
+// TimeControlPresets lists the supported time controls, from the fastest to correspondence.
+var TimeControlPresets = []TimeControlPreset{
+	{Code: "1+0", Label: "🔫 1+0", Initial: time.Minute},
+	{Code: "2+1", Label: "🔫 2+1", Initial: 2 * time.Minute, Increment: time.Second},
+	{Code: "3+2", Label: "⚡ 3+2", Initial: 3 * time.Minute, Increment: 2 * time.Second},
+	{Code: "5+0", Label: "⚡ 5+0", Initial: 5 * time.Minute},
+	{Code: "10+5", Label: "🐇 10+5", Initial: 10 * time.Minute, Increment: 5 * time.Second},
+	{Code: "15+10", Label: "🐇 15+10", Initial: 15 * time.Minute, Increment: 10 * time.Second},
+	{Code: "30+0", Label: "🐢 30+0", Initial: 30 * time.Minute},
+	{Code: "30+20", Label: "🐢 30+20", Initial: 30 * time.Minute, Increment: 20 * time.Second},
+	{Code: "d1", Label: "✉️ 1 день/ход", DaysPerMove: 1},
+	{Code: "d3", Label: "✉️ 3 дня/ход", DaysPerMove: 3},
+	{Code: "d7", Label: "✉️ 7 дней/ход", DaysPerMove: 7},
+}
+
+// FindTimeControlPreset looks up a preset by its code.
+func FindTimeControlPreset(code string) (TimeControlPreset, bool) {
+	for _, p := range TimeControlPresets {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return TimeControlPreset{}, false
+}
+
+// Category classifies the preset into bullet/blitz/rapid/classical/correspondence.
+// Real-time controls use the estimated game duration initial + 40 × increment.
+// The zero preset means "no clock".
+func (p TimeControlPreset) Category() string {
+	if p.Initial == 0 && p.DaysPerMove == 0 {
+		return models.TimeControlNone
+	}
+	if p.DaysPerMove > 0 {
+		return models.TimeControlCorrespondence
+	}
+	estimated := p.Initial + 40*p.Increment
+	switch {
+	case estimated < 3*time.Minute:
+		return models.TimeControlBullet
+	case estimated < 8*time.Minute:
+		return models.TimeControlBlitz
+	case estimated < 25*time.Minute:
+		return models.TimeControlRapid
+	default:
+		return models.TimeControlClassical
+	}
+}
+
+// ApplyTimeControl configures the room clocks from the preset (the zero preset removes the clock).
+// Clocks stay stopped (TurnStartedAt == nil) until the first move is made.
+func ApplyTimeControl(room *models.Room, p TimeControlPreset) {
+	room.TimeControl = p.Category()
+	room.ClockInitial = int(p.Initial / time.Second)
+	room.ClockIncrement = int(p.Increment / time.Second)
+	room.DaysPerMove = p.DaysPerMove
+	room.TurnStartedAt = nil
+
+	full := p.Initial
+	if p.DaysPerMove > 0 {
+		full = time.Duration(p.DaysPerMove) * 24 * time.Hour
+	}
+	room.WhiteTimeMs = full.Milliseconds()
+	room.BlackTimeMs = full.Milliseconds()
+}
+
+// TimeControlLabel returns a short human-readable description, e.g. "blitz 3+2" or "3 дн./ход".
+func TimeControlLabel(room *models.Room) string {
+	switch {
+	case !room.HasClock():
+		return "без контроля времени"
+	case room.TimeControl == models.TimeControlCorrespondence:
+		return fmt.Sprintf("%d дн./ход", room.DaysPerMove)
+	default:
+		return fmt.Sprintf("%s %d+%d", room.TimeControl, room.ClockInitial/60, room.ClockIncrement)
+	}
+}
+
+// RemainingTime returns how much time each side has left at moment now,
+// charging the side to move for the time elapsed since TurnStartedAt.
+func RemainingTime(room *models.Room, now time.Time) (white, black time.Duration) {
+	white = time.Duration(room.WhiteTimeMs) * time.Millisecond
+	black = time.Duration(room.BlackTimeMs) * time.Millisecond
+	if room.TurnStartedAt == nil {
+		return white, black
+	}
+	elapsed := now.Sub(*room.TurnStartedAt)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if room.IsWhiteTurn {
+		white -= elapsed
+	} else {
+		black -= elapsed
+	}
+	return white, black
+}
+
+// IsFlagged reports whether the side to move has run out of time at moment now.
+func IsFlagged(room *models.Room, now time.Time) bool {
+	if !room.HasClock() || room.TurnStartedAt == nil || room.Status != models.RoomStatusPlaying {
+		return false
+	}
+	white, black := RemainingTime(room, now)
+	if room.IsWhiteTurn {
+		return white <= 0
+	}
+	return black <= 0
+}
+
+// PressClock must be called right after the side to move has made a move (before IsWhiteTurn is flipped).
+// It charges the mover for the time spent, adds the increment (or, in correspondence, refills the
+// opponent's days) and starts the opponent's clock at moment now. The very first move only starts the clocks.
+func PressClock(room *models.Room, now time.Time) {
+	if !room.HasClock() {
+		return
+	}
+	if room.TurnStartedAt != nil {
+		white, black := RemainingTime(room, now)
+		increment := time.Duration(room.ClockIncrement) * time.Second
+		if room.IsWhiteTurn {
+			room.WhiteTimeMs = (white + increment).Milliseconds()
+		} else {
+			room.BlackTimeMs = (black + increment).Milliseconds()
+		}
+	}
+
+	if room.TimeControl == models.TimeControlCorrespondence {
+		perMove := (time.Duration(room.DaysPerMove) * 24 * time.Hour).Milliseconds()
+		room.WhiteTimeMs, room.BlackTimeMs = perMove, perMove
+	}
+
+	started := now
+	room.TurnStartedAt = &started
+}
+
+// FormatClocks renders the clock line shown under the board, or "" if the room has no clock.
+func FormatClocks(room *models.Room, now time.Time) string {
+	if !room.HasClock() {
+		return ""
+	}
+	white, black := RemainingTime(room, now)
+	if room.TimeControl == models.TimeControlCorrespondence {
+		left := white
+		side := "белых"
+		if !room.IsWhiteTurn {
+			left, side = black, "чёрных"
+		}
+		return fmt.Sprintf("⏱ На ход %s осталось: %s", side, formatDays(left))
+	}
+
+	whiteMark, blackMark := "", ""
+	if room.TurnStartedAt != nil {
+		if room.IsWhiteTurn {
+			whiteMark = " ⏳"
+		} else {
+			blackMark = " ⏳"
+		}
+	}
+	return fmt.Sprintf("⏱ Белые %s%s | Чёрные %s%s",
+		formatClock(white), whiteMark, formatClock(black), blackMark)
+}
+
+// HasMatingMaterial reports whether the given side still has enough material to ever deliver mate.
+// Used when a flag falls: running out of time against a lone king (or king + single minor piece) is a draw.
+func HasMatingMaterial(board *chess.Board, color chess.Color) bool {
+	minors := 0
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		p := board.Piece(sq)
+		if p == chess.NoPiece || p.Color() != color {
+			continue
+		}
+		switch p.Type() {
+		case chess.Pawn, chess.Rook, chess.Queen:
+			return true
+		case chess.Bishop, chess.Knight:
+			minors++
+		}
+	}
+	return minors >= 2
+}
+
+// formatClock formats a real-time clock as m:ss (or h:mm:ss), with tenths under ten seconds.
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	if d < 10*time.Second {
+		return fmt.Sprintf("0:%02d.%d", int(d/time.Second), int(d%time.Second/(100*time.Millisecond)))
+	}
+	d = d.Truncate(time.Second)
+	h, m, s := int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second)
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// formatDays formats a correspondence clock as "2 д. 23 ч." or "5 ч. 12 мин.".
+func formatDays(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days, hours := int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour)
+	if days > 0 {
+		return fmt.Sprintf("%d д. %d ч.", days, hours)
+	}
+	return fmt.Sprintf("%d ч. %d мин.", hours, int(d%time.Hour/time.Minute))
+}
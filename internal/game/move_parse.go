@@ -0,0 +1,204 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// ErrNotAMove is returned by ParseMove for text that does not look like a move at all.
+var ErrNotAMove = errors.New("это не похоже на ход")
+
+// moveNotationRe matches a normalized move: optional piece letter, optional origin file and rank,
+// the target square and an optional promotion piece. It covers SAN (Nf3, exd5, Nbd2, e8Q)
+// as well as UCI and long algebraic notation (e2e4, g1f3, e7e8Q).
+var moveNotationRe = regexp.MustCompile(`^([KQRBN])?([a-h])?([1-8])?([a-h][1-8])([QRBN])?$`)
+
+// russianPieces maps the Russian piece letters to the English ones. "Кр" (король) has to be replaced
+// before "К" (конь).
+var russianPieces = strings.NewReplacer(
+	"Кр", "K", "КР", "K", "кр", "K",
+	"К", "N", "к", "N",
+	"Ф", "Q", "ф", "Q",
+	"Л", "R", "л", "R",
+	"С", "B",
+	// Lowercase Cyrillic letters that look like files are typed on a Russian keyboard layout.
+	"а", "a", "с", "c", "е", "e",
+	// Capture signs, including the Cyrillic "х".
+	"x", "", "X", "", "х", "", "×", "", ":", "",
+	"-", "", "=", "", " ", "", "(", "", ")", "",
+)
+
+// notation is a parsed but not yet resolved move.
+type notation struct {
+	castle        chess.MoveTag // KingSideCastle or QueenSideCastle for castling, 0 otherwise
+	piece         chess.PieceType
+	fromFile      string
+	fromRank      string
+	to            chess.Square
+	promo         chess.PieceType
+	bishopOrFileB bool // "bxc3": the b-pawn, or the bishop if no b-pawn can make the move
+	anyPiece      bool // origin square given without a piece letter (UCI): any piece may stand there
+}
+
+// LooksLikeMove reports whether text is written like a chess move, regardless of the position.
+func LooksLikeMove(text string) bool {
+	_, err := parseNotation(text)
+	return err == nil
+}
+
+/*
+ParseMove finds the legal move of pos written in text. It is forgiving about the notation:
+  - SAN with or without capture and check signs: e4, Nf3, exd5, Nbd2, e8=Q, Qh4#;
+  - UCI and long algebraic notation: e2e4, e7e8q, e2-e4, Ng1-f3, "e2 e4";
+  - castling as O-O, 0-0, O-O-O, 0-0-0 or as the king move (e1g1);
+  - Russian piece letters: Кр (король), Ф (ферзь), Л (ладья), С (слон), К (конь), e.g. Кf3 or e8=Ф;
+  - lowercase piece letters (nf3), with "b" read as a file unless only the bishop can make the move.
+
+The errors are meant for the player: ErrNotAMove for other text, otherwise an explanation of why
+the move cannot be made (impossible, ambiguous, missing promotion piece).
+*/
+func ParseMove(pos *chess.Position, text string) (*chess.Move, error) {
+	n, err := parseNotation(text)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := n.candidates(pos, false)
+	if len(candidates) == 0 && n.bishopOrFileB {
+		candidates = n.candidates(pos, true)
+	}
+	original := strings.TrimSpace(text)
+
+	switch {
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("ход %s невозможен в этой позиции", original)
+	case len(candidates) == 1:
+		return candidates[0], nil
+	}
+
+	// Several pawn moves differing only by the promotion piece mean the piece was not given.
+	if n.promo == chess.NoPieceType && candidates[0].Promo() != chess.NoPieceType {
+		samePawn := true
+		for _, mv := range candidates[1:] {
+			samePawn = samePawn && mv.S1() == candidates[0].S1()
+		}
+		if samePawn {
+			return nil, fmt.Errorf("укажите, в какую фигуру превращается пешка, например %s=Q", candidates[0].S2())
+		}
+	}
+
+	options := make([]string, len(candidates))
+	for i, mv := range candidates {
+		options[i] = chess.AlgebraicNotation{}.Encode(pos, mv)
+	}
+	return nil, fmt.Errorf("ход %s неоднозначен: %s", original, strings.Join(options, " или "))
+}
+
+// parseNotation normalizes text and splits it into the parts of a move.
+func parseNotation(text string) (notation, error) {
+	s := strings.TrimSpace(text)
+	s = strings.TrimRight(s, "+#!?")
+	s = strings.TrimPrefix(s, "п") // an optional pawn letter
+	s = strings.TrimPrefix(s, "P")
+
+	switch strings.ToUpper(strings.NewReplacer("0", "O", "-", "", " ", "").Replace(s)) {
+	case "OO":
+		return notation{castle: chess.KingSideCastle}, nil
+	case "OOO":
+		return notation{castle: chess.QueenSideCastle}, nil
+	}
+
+	s = russianPieces.Replace(s)
+	if s == "" {
+		return notation{}, ErrNotAMove
+	}
+
+	var n notation
+	// Lowercase piece letters: k, q, r, n are never files; b is decided against the position.
+	if strings.ContainsRune("kqrn", rune(s[0])) {
+		s = strings.ToUpper(s[:1]) + s[1:]
+	} else if s[0] == 'b' && len(s) == 3 {
+		n.bishopOrFileB = true
+	}
+	// A lowercase promotion piece as in UCI: e7e8q.
+	if last := s[len(s)-1]; len(s) > 2 && strings.ContainsRune("qrbn", rune(last)) && s[len(s)-2] >= '1' && s[len(s)-2] <= '8' {
+		s = s[:len(s)-1] + strings.ToUpper(string(last))
+	}
+
+	m := moveNotationRe.FindStringSubmatch(s)
+	if m == nil {
+		return notation{}, ErrNotAMove
+	}
+	to, err := StrToSquare(m[4])
+	if err != nil {
+		return notation{}, ErrNotAMove
+	}
+	n.piece = pieceTypeFromLetter(m[1])
+	n.fromFile, n.fromRank = m[2], m[3]
+	n.to = to
+	n.promo = pieceTypeFromLetter(m[5])
+	if n.promo == chess.Pawn {
+		n.promo = chess.NoPieceType
+	}
+	n.anyPiece = m[1] == "" && n.fromFile != "" && n.fromRank != ""
+	return n, nil
+}
+
+// candidates returns the legal moves of pos matching the notation. asBishop reads a leading "b" as the bishop.
+func (n notation) candidates(pos *chess.Position, asBishop bool) []*chess.Move {
+	var result []*chess.Move
+	for _, mv := range pos.ValidMoves() {
+		if n.castle != 0 {
+			if mv.HasTag(n.castle) {
+				result = append(result, mv)
+			}
+			continue
+		}
+		if mv.S2() != n.to {
+			continue
+		}
+		pieceType := pos.Board().Piece(mv.S1()).Type()
+		fromFile, fromRank := n.fromFile, n.fromRank
+		switch {
+		case asBishop:
+			if pieceType != chess.Bishop {
+				continue
+			}
+			fromFile = ""
+		case n.anyPiece:
+		case pieceType != n.piece:
+			continue
+		}
+		from := mv.S1().String()
+		if fromFile != "" && from[:1] != fromFile || fromRank != "" && from[1:] != fromRank {
+			continue
+		}
+		if n.promo != chess.NoPieceType && mv.Promo() != n.promo {
+			continue
+		}
+		result = append(result, mv)
+	}
+	return result
+}
+
+// pieceTypeFromLetter converts an English piece letter to the piece type; no letter means a pawn.
+func pieceTypeFromLetter(letter string) chess.PieceType {
+	switch letter {
+	case "K":
+		return chess.King
+	case "Q":
+		return chess.Queen
+	case "R":
+		return chess.Rook
+	case "B":
+		return chess.Bishop
+	case "N":
+		return chess.Knight
+	default:
+		return chess.Pawn
+	}
+}
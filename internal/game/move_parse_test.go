@@ -0,0 +1,123 @@
+package game
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	// After 1.e4 e5: the queen and the f1 bishop are free.
+	openFEN = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"
+	// After 1.e4 d5: exd5 is possible.
+	scandiFEN = "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2"
+	// After 1.d4 d5 2.Nf3 Nf6: both knights can go to d2.
+	knightsFEN = "rnbqkb1r/ppp1pppp/5n2/3p4/3P4/5N2/PPP1PPPP/RNBQKB1R w KQkq - 2 3"
+	// After 1.d4 d5: no b-pawn can reach f4, the c1 bishop can.
+	bishopFEN = "rnbqkbnr/ppp1pppp/8/3p4/3P4/8/PPP1PPPP/RNBQKBNR w KQkq d6 0 2"
+	// A black knight on c3 that the b-pawn, the d-pawn and nothing else can take.
+	pawnTakesFEN = "r1bqkbnr/pppppppp/8/8/8/2n5/PPPPPPPP/R1BQKBNR w KQkq - 0 1"
+	// Both sides may still castle either way.
+	castleFEN = "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"
+	// A white pawn about to promote on e8.
+	promoFEN = "8/4P3/8/8/8/8/8/k6K w - - 0 1"
+)
+
+func TestParseMove(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		text    string
+		want    string // the move in UCI
+		wantErr string // a part of the error text instead
+	}{
+		{"SAN pawn", startFEN, "e4", "e2e4", ""},
+		{"SAN piece", startFEN, "Nf3", "g1f3", ""},
+		{"SAN with check sign", startFEN, "Nf3+", "g1f3", ""},
+		{"SAN capture", scandiFEN, "exd5", "e4d5", ""},
+		{"SAN capture without x", scandiFEN, "ed5", "e4d5", ""},
+		{"SAN with origin file", knightsFEN, "Nbd2", "b1d2", ""},
+		{"SAN ambiguous", knightsFEN, "Nd2", "", "неоднозначен"},
+		{"UCI", startFEN, "e2e4", "e2e4", ""},
+		{"UCI of a piece", startFEN, "g1f3", "g1f3", ""},
+		{"long algebraic", startFEN, "e2-e4", "e2e4", ""},
+		{"long algebraic with piece", startFEN, "Ng1-f3", "g1f3", ""},
+		{"squares separated by a space", startFEN, "e2 e4", "e2e4", ""},
+		{"lowercase piece letter", startFEN, "nf3", "g1f3", ""},
+		{"castling O-O", castleFEN, "O-O", "e1g1", ""},
+		{"castling 0-0-0", castleFEN, "0-0-0", "e1c1", ""},
+		{"castling as a king move", castleFEN, "e1g1", "e1g1", ""},
+		{"Russian king", castleFEN, "Крd2", "e1d2", ""},
+		{"Russian queen", openFEN, "Фh5", "d1h5", ""},
+		{"Russian rook", castleFEN, "Лb1", "a1b1", ""},
+		{"Russian bishop", openFEN, "Сc4", "f1c4", ""},
+		{"Russian knight", startFEN, "Кf3", "g1f3", ""},
+		{"Russian capture sign and file letter", scandiFEN, "ехd5", "e4d5", ""},
+		{"lowercase b is the b-pawn", pawnTakesFEN, "bxc3", "b2c3", ""},
+		{"lowercase b is the bishop when no b-pawn can move", bishopFEN, "bf4", "c1f4", ""},
+		{"uppercase B is the bishop", bishopFEN, "Bf4", "c1f4", ""},
+		{"promotion", promoFEN, "e8=Q", "e7e8q", ""},
+		{"promotion in UCI", promoFEN, "e7e8n", "e7e8n", ""},
+		{"promotion to a Russian piece", promoFEN, "e8=Ф", "e7e8q", ""},
+		{"promotion piece missing", promoFEN, "e8", "", "укажите, в какую фигуру"},
+		{"impossible move", startFEN, "e5", "", "невозможен"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fen, err := chess.FEN(tt.fen)
+			if err != nil {
+				t.Fatalf("FEN: %v", err)
+			}
+			pos := chess.NewGame(fen).Position()
+			mv, err := ParseMove(pos, tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseMove(%q) = %v, %v, want an error with %q", tt.text, mv, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMove(%q): %v", tt.text, err)
+			}
+			if mv.String() != tt.want {
+				t.Errorf("ParseMove(%q) = %s, want %s", tt.text, mv, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMoveNotAMove(t *testing.T) {
+	fen, _ := chess.FEN(startFEN)
+	pos := chess.NewGame(fen).Position()
+	for _, text := range []string{"", "gg", "удачи", "e9", "Nf"} {
+		if _, err := ParseMove(pos, text); !errors.Is(err, ErrNotAMove) {
+			t.Errorf("ParseMove(%q) error = %v, want %v", text, err, ErrNotAMove)
+		}
+	}
+}
+
+func TestLooksLikeMove(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"e4", true},
+		{"Nf3", true},
+		{"e2e4", true},
+		{"O-O-O", true},
+		{"Кf3", true},
+		{"e8=Q", true},
+		{"gg", false},
+		{"удачи", false},
+		{"ну и ход", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := LooksLikeMove(tt.text); got != tt.want {
+			t.Errorf("LooksLikeMove(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
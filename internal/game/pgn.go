@@ -0,0 +1,154 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+
+	"lvlchess/internal/analysis"
+	"lvlchess/internal/db/models"
+)
+
+// pgnLineWidth is the maximum length of a movetext line, as recommended by the PGN standard.
+const pgnLineWidth = 80
+
+// PGNHeader carries the Seven Tag Roster (plus optional SetUp/FEN) for a PGN document.
+// Empty values are replaced by the PGN "unknown" placeholders ("?", "????.??.??", "*").
+type PGNHeader struct {
+	Event    string
+	Site     string
+	Date     time.Time
+	Round    string
+	White    string
+	Black    string
+	Result   string // "1-0", "0-1", "1/2-1/2" or "*"
+	StartFEN string // Non-empty only if the game did not start from the standard position
+}
+
+// BuildPGN assembles a full PGN document from the header and the stored room moves (SAN).
+// Analysed moves are annotated with NAGs and {[%eval ...]} comments.
+// Move numbers take StartFEN into account, so games started from a custom position
+// (e.g. with Black to move) are numbered correctly ("12... Nf6").
+func BuildPGN(header PGNHeader, moves []models.RoomMove) (string, error) {
+	result := header.Result
+	if result == "" {
+		result = chess.NoOutcome.String()
+	}
+
+	moveNumber, whiteToMove := 1, true
+	if header.StartFEN != "" {
+		fenOption, err := chess.FEN(header.StartFEN)
+		if err != nil {
+			return "", fmt.Errorf("invalid start FEN: %w", err)
+		}
+		pos := chess.NewGame(fenOption).Position()
+		whiteToMove = pos.Turn() == chess.White
+		moveNumber = FullMoveNumber(header.StartFEN)
+	}
+
+	var sb strings.Builder
+	writeTag(&sb, "Event", orUnknown(header.Event, "?"))
+	writeTag(&sb, "Site", orUnknown(header.Site, "?"))
+	date := "????.??.??"
+	if !header.Date.IsZero() {
+		date = header.Date.Format("2006.01.02")
+	}
+	writeTag(&sb, "Date", date)
+	writeTag(&sb, "Round", orUnknown(header.Round, "-"))
+	writeTag(&sb, "White", orUnknown(header.White, "?"))
+	writeTag(&sb, "Black", orUnknown(header.Black, "?"))
+	writeTag(&sb, "Result", result)
+	if header.StartFEN != "" {
+		writeTag(&sb, "SetUp", "1")
+		writeTag(&sb, "FEN", header.StartFEN)
+	}
+	sb.WriteString("\n")
+
+	// Movetext: tokens are wrapped so that no line exceeds pgnLineWidth.
+	tokens := make([]string, 0, len(moves)*3/2+1)
+	commented := false
+	for i, mv := range moves {
+		switch {
+		case whiteToMove:
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+		case i == 0 || commented:
+			// The game starts with a Black move, or a comment interrupts the move pair, e.g. "12... Nf6".
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+		}
+		tokens = append(tokens, mv.SAN)
+		tokens, commented = appendAnnotation(tokens, mv)
+		if !whiteToMove {
+			moveNumber++
+		}
+		whiteToMove = !whiteToMove
+	}
+	tokens = append(tokens, result)
+
+	lineLen := 0
+	for i, tok := range tokens {
+		if i > 0 {
+			if lineLen+1+len(tok) > pgnLineWidth {
+				sb.WriteString("\n")
+				lineLen = 0
+			} else {
+				sb.WriteString(" ")
+				lineLen++
+			}
+		}
+		sb.WriteString(tok)
+		lineLen += len(tok)
+	}
+	sb.WriteString("\n")
+
+	return sb.String(), nil
+}
+
+// appendAnnotation adds the analysis of an analysed move to the movetext: a NAG ($6, $2, $4)
+// for inaccuracies, mistakes and blunders and a {comment} with the evaluation and the best move.
+// The comment is split into words so that long comments are wrapped like the rest of the movetext.
+// It reports whether a comment was added.
+func appendAnnotation(tokens []string, mv models.RoomMove) ([]string, bool) {
+	if mv.Eval == nil {
+		return tokens, false
+	}
+	if nag := analysis.NAG(mv.Judgment); nag != "" {
+		tokens = append(tokens, nag)
+	}
+	words := strings.Fields(analysis.PGNComment(*mv.Eval, mv.Judgment, mv.BestSAN))
+	if len(words) == 0 {
+		return tokens, false
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	return append(tokens, words...), true
+}
+
+// FullMoveNumber extracts the fullmove counter (6th field) from a FEN, defaulting to 1.
+func FullMoveNumber(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) != 6 {
+		return 1
+	}
+	var n int
+	if _, err := fmt.Sscanf(fields[5], "%d", &n); err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// writeTag writes a single PGN tag pair, escaping backslashes and quotes in the value.
+func writeTag(sb *strings.Builder, key, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	sb.WriteString(fmt.Sprintf("[%s \"%s\"]\n", key, value))
+}
+
+// orUnknown returns fallback if value is empty.
+func orUnknown(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,173 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// ValidateFEN checks that fen describes a legal, still playable chess position and returns
+// it in normalized form. The returned errors are user-facing (Russian) and explain what is wrong.
+// Besides FEN syntax, it rejects:
+//   - missing or extra kings, more than 16 pieces / 8 pawns per side, pawns on the first or last rank;
+//   - adjacent kings, or the side that is NOT to move being in check;
+//   - castling rights without the king and rook on their original squares;
+//   - positions that are already finished (mate, stalemate, insufficient material).
+func ValidateFEN(fen string) (string, error) {
+	fen = strings.Join(strings.Fields(fen), " ")
+	if fen == "" {
+		return "", errors.New("пустая строка FEN")
+	}
+	fenOption, err := chess.FEN(fen)
+	if err != nil {
+		return "", fmt.Errorf("некорректный FEN: %v", err)
+	}
+	chGame := chess.NewGame(fenOption)
+	pos := chGame.Position()
+	board := pos.Board()
+
+	// Material and piece placement.
+	var kings [2][]chess.Square
+	var pieces, pawns [2]int
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		p := board.Piece(sq)
+		if p == chess.NoPiece {
+			continue
+		}
+		side := colorIndex(p.Color())
+		pieces[side]++
+		switch p.Type() {
+		case chess.King:
+			kings[side] = append(kings[side], sq)
+		case chess.Pawn:
+			pawns[side]++
+			if sq.Rank() == chess.Rank1 || sq.Rank() == chess.Rank8 {
+				return "", fmt.Errorf("пешка не может стоять на %s (первая/последняя горизонталь)", sq)
+			}
+		}
+	}
+	for side, name := range []string{"белых", "чёрных"} {
+		if len(kings[side]) != 1 {
+			return "", fmt.Errorf("у %s должен быть ровно один король (найдено: %d)", name, len(kings[side]))
+		}
+		if pieces[side] > 16 {
+			return "", fmt.Errorf("у %s больше 16 фигур", name)
+		}
+		if pawns[side] > 8 {
+			return "", fmt.Errorf("у %s больше 8 пешек", name)
+		}
+	}
+
+	whiteKing, blackKing := kings[0][0], kings[1][0]
+	if squareDistance(whiteKing, blackKing) <= 1 {
+		return "", errors.New("короли не могут стоять на соседних полях")
+	}
+
+	// The side that just moved must not be left in check: if the side to move attacks
+	// the opposing king, the position is unreachable. This is a plain attack test, not a legal-move
+	// search, so pinned attackers and a side to move that is itself in check count as well.
+	opponentKing := blackKing
+	if pos.Turn() == chess.Black {
+		opponentKing = whiteKing
+	}
+	if isAttacked(board, opponentKing, pos.Turn()) {
+		return "", errors.New("сторона, которая не ходит, находится под шахом")
+	}
+
+	// Castling rights require king and rook on their original squares.
+	rights := pos.CastleRights()
+	castleChecks := []struct {
+		color      chess.Color
+		side       chess.Side
+		king, rook chess.Square
+	}{
+		{chess.White, chess.KingSide, chess.E1, chess.H1},
+		{chess.White, chess.QueenSide, chess.E1, chess.A1},
+		{chess.Black, chess.KingSide, chess.E8, chess.H8},
+		{chess.Black, chess.QueenSide, chess.E8, chess.A8},
+	}
+	for _, c := range castleChecks {
+		if !rights.CanCastle(c.color, c.side) {
+			continue
+		}
+		if board.Piece(c.king) != chess.NewPiece(chess.King, c.color) ||
+			board.Piece(c.rook) != chess.NewPiece(chess.Rook, c.color) {
+			return "", fmt.Errorf("право рокировки %q невозможно: король/ладья не на исходных полях", rights.String())
+		}
+	}
+
+	// A room must start from a position where the game is still going on.
+	switch chGame.Method() {
+	case chess.Checkmate:
+		return "", errors.New("в этой позиции уже мат")
+	case chess.Stalemate:
+		return "", errors.New("в этой позиции пат")
+	case chess.InsufficientMaterial:
+		return "", errors.New("в этой позиции недостаточно материала для мата")
+	}
+
+	return chGame.FEN(), nil
+}
+
+// PositionFromPGN reads the first game of a PGN document and returns the FEN of its final position.
+// The PGN may itself start from a custom position (SetUp/FEN tags) and may omit the tag section.
+func PositionFromPGN(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать PGN: %v", err)
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", errors.New("в файле не найдено ни одной партии")
+	}
+
+	// Bare movetext ("1. e4 e5 2. Nf3 ...") is decoded directly; the scanner needs tag pairs
+	// to find where a game starts and only takes the first game of multi-game files.
+	if !strings.HasPrefix(text, "[") {
+		pgnOption, err := chess.PGN(strings.NewReader(text))
+		if err != nil {
+			return "", fmt.Errorf("не удалось разобрать PGN: %v", err)
+		}
+		return chess.NewGame(pgnOption).Position().String(), nil
+	}
+
+	scanner := chess.NewScanner(strings.NewReader(text))
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("не удалось разобрать PGN: %v", err)
+		}
+		return "", errors.New("в файле не найдено ни одной партии")
+	}
+	chGame := scanner.Next()
+	if chGame == nil {
+		return "", errors.New("в файле не найдено ни одной партии")
+	}
+	return chGame.Position().String(), nil
+}
+
+// colorIndex maps White to 0 and Black to 1, for small per-side arrays.
+func colorIndex(c chess.Color) int {
+	if c == chess.Black {
+		return 1
+	}
+	return 0
+}
+
+// squareDistance returns the Chebyshev (king-move) distance between two squares.
+func squareDistance(a, b chess.Square) int {
+	df := int(a.File()) - int(b.File())
+	dr := int(a.Rank()) - int(b.Rank())
+	if df < 0 {
+		df = -df
+	}
+	if dr < 0 {
+		dr = -dr
+	}
+	if df > dr {
+		return df
+	}
+	return dr
+}
@@ -138,14 +138,14 @@ func RenderASCIIBoard(fen string, ranks []chess.Rank, files []chess.File, orient
 		// "Horizontal" means we're enumerating files in the outer loop, ranks in the inner loop
 		// (a typical 90° board).
 		for i, file := range files {
-			sb.WriteString(fmt.Sprintf("%s |", string('a'+i)))
+			sb.WriteString(fmt.Sprintf("%s |", string(rune('a'+i))))
 			for j := range ranks {
 				sq := chess.NewSquare(file, ranks[j])
 				piece := board.Piece(sq)
 				// Determine if the square is "light" or "dark," used for placeholders.
 				sb.WriteString(formatSquare(piece, (i+j)%2 == 0))
 			}
-			sb.WriteString(fmt.Sprintf("| %s\n", string('a'+i)))
+			sb.WriteString(fmt.Sprintf("| %s\n", string(rune('a'+i))))
 		}
 	} else {
 		// Standard (White/Black) board layout logic.
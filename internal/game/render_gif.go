@@ -0,0 +1,155 @@
+package game
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+
+	"lvlchess/internal/db/models"
+)
+
+// replayFinalHold is how many frame delays the final position of a replay stays on screen before the loop restarts.
+const replayFinalHold = 4
+
+// RenderReplayGIF draws an animated GIF of a game: the start position (startFEN, empty for the standard one)
+// followed by one frame per stored move with that move highlighted. Every frame stays on screen for delay,
+// the final one a few times longer. orientation is the side shown at the bottom.
+// Frames are rasterized one at a time and only the previous one is kept, so memory does not grow with the game.
+func RenderReplayGIF(startFEN string, moves []models.RoomMove, orientation chess.Color, delay time.Duration) ([]byte, error) {
+	palette, err := replayPalette()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[color.RGBA]uint8)
+	centiseconds := max(1, int(delay/(10*time.Millisecond)))
+
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: BoardPixels, Height: BoardPixels}}
+	var prev *image.RGBA
+	for i := 0; i <= len(moves); i++ {
+		b := BoardImage{FEN: startFEN, Orientation: orientation}
+		if i > 0 {
+			b = BoardImage{FEN: moves[i-1].FENAfter, Orientation: orientation, LastMove: moves[i-1].UCI}
+		}
+		frame, err := RasterizeBoard(b)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+
+		// Frames after the first one only carry the region that changed, drawn over the previous frame.
+		bounds := frame.Bounds()
+		if prev != nil {
+			bounds = changedBounds(prev, frame)
+			if bounds.Empty() {
+				bounds = image.Rect(0, 0, 1, 1)
+			}
+		}
+		paletted := image.NewPaletted(bounds, palette)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := frame.RGBAAt(x, y)
+				idx, ok := index[c]
+				if !ok {
+					idx = uint8(palette.Index(c))
+					index[c] = idx
+				}
+				paletted.SetColorIndex(x, y, idx)
+			}
+		}
+		frameDelay := centiseconds
+		if i == len(moves) {
+			frameDelay *= replayFinalHold
+		}
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, frameDelay)
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+		prev = frame
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, fmt.Errorf("gif.EncodeAll: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// replayPaletteSamples are the boards the replay palette is built from: between them they show every piece
+// on both square colors, last-move highlights on both square colors, a king in check and both orientations.
+var replayPaletteSamples = []BoardImage{
+	{LastMove: "e2e4"},
+	{FEN: "rnbqkb1r/pppppppp/5n2/8/8/5N2/PPPPPPPP/RNBQKB1R w KQkq - 2 2", LastMove: "g8f6"},
+	{FEN: "rnbqkbnr/ppppp2p/5p2/6pQ/4P3/8/PPPP1PPP/RNB1KBNR b KQkq - 1 3", LastMove: "d1h5", Orientation: chess.Black},
+}
+
+var (
+	replayPaletteOnce   sync.Once
+	replayPaletteColors color.Palette
+	replayPaletteErr    error
+)
+
+// replayPalette returns the fixed palette of the replays, built once from replayPaletteSamples.
+func replayPalette() (color.Palette, error) {
+	replayPaletteOnce.Do(func() {
+		frames := make([]*image.RGBA, len(replayPaletteSamples))
+		for i, b := range replayPaletteSamples {
+			if frames[i], replayPaletteErr = RasterizeBoard(b); replayPaletteErr != nil {
+				replayPaletteErr = fmt.Errorf("replay palette: %w", replayPaletteErr)
+				return
+			}
+		}
+		replayPaletteColors = frequentColors(frames)
+	})
+	return replayPaletteColors, replayPaletteErr
+}
+
+// frequentColors builds a palette of the 256 most frequent colors of the frames. Board pictures use few
+// colors (squares, highlights, piece shades), so the most frequent ones cover them all and the rest
+// (anti-aliasing) maps to a close neighbour.
+func frequentColors(frames []*image.RGBA) color.Palette {
+	counts := make(map[color.RGBA]int)
+	for _, frame := range frames {
+		pix := frame.Pix
+		// Every other pixel is enough to find the frequent colors.
+		for i := 0; i+3 < len(pix); i += 8 {
+			counts[color.RGBA{pix[i], pix[i+1], pix[i+2], 255}]++
+		}
+	}
+	colors := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		if counts[colors[i]] != counts[colors[j]] {
+			return counts[colors[i]] > counts[colors[j]]
+		}
+		a, b := colors[i], colors[j]
+		return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+	})
+	palette := make(color.Palette, 0, 256)
+	for _, c := range colors[:min(256, len(colors))] {
+		palette = append(palette, c)
+	}
+	return palette
+}
+
+// changedBounds returns the smallest rectangle containing every pixel that differs between two frames.
+func changedBounds(prev, next *image.RGBA) image.Rectangle {
+	var changed image.Rectangle
+	b := next.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := y * next.Stride
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := row + x*4
+			if prev.Pix[i] != next.Pix[i] || prev.Pix[i+1] != next.Pix[i+1] || prev.Pix[i+2] != next.Pix[i+2] {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return changed
+}
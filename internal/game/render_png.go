@@ -0,0 +1,185 @@
+package game
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+
+	"github.com/notnil/chess"
+)
+
+// pieceSprites holds the bundled piece images (SquareSize x SquareSize, transparent background),
+// named by color and piece letter: wK.png, bP.png, ...
+//
+//go:embed assets/pieces/*.png
+var pieceSprites embed.FS
+
+var (
+	spritesOnce sync.Once
+	sprites     map[chess.Piece]image.Image
+	spritesPNG  map[chess.Piece][]byte
+	spritesErr  error
+)
+
+// polygonSamples is the supersampling grid (per axis) used to anti-alias polygon edges.
+const polygonSamples = 4
+
+// RenderBoardPNG draws the board as a PNG image by rasterising the shapes of the drawing model
+// (see BoardImage): the pieces, the last move, markers, arrows and a red glow under a king in check.
+func RenderBoardPNG(b BoardImage) ([]byte, error) {
+	img, err := RasterizeBoard(b)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("png.Encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RasterizeBoard draws the board into a new BoardPixels x BoardPixels RGBA image.
+func RasterizeBoard(b BoardImage) (*image.RGBA, error) {
+	shapes, err := b.shapes()
+	if err != nil {
+		return nil, err
+	}
+	if err = loadSprites(); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, BoardPixels, BoardPixels))
+	for _, s := range shapes {
+		switch s.kind {
+		case shapeRect:
+			draw.Draw(img, s.rect, image.NewUniform(s.color), image.Point{}, draw.Over)
+		case shapeGlow:
+			rasterizeGlow(img, s.rect, s.color)
+		case shapePiece:
+			draw.Draw(img, s.rect, sprites[s.piece], image.Point{}, draw.Over)
+		case shapePolygon:
+			rasterizePolygon(img, s.points, s.color)
+		case shapeText:
+			rasterizeGlyph(img, s.char, s.rect.Min, s.color)
+		}
+	}
+	return img, nil
+}
+
+// rasterizeGlow paints a radial gradient, opaque in the middle of the rectangle and fading to its edges.
+func rasterizeGlow(img *image.RGBA, rect image.Rectangle, c color.NRGBA) {
+	cx, cy := float64(rect.Min.X+rect.Max.X)/2, float64(rect.Min.Y+rect.Max.Y)/2
+	radius := float64(rect.Dx()) * 0.6
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / radius
+			if d >= 1 {
+				continue
+			}
+			blend(img, x, y, c, 1-d*d)
+		}
+	}
+}
+
+// rasterizePolygon fills a polygon (even-odd rule), anti-aliasing its edges by supersampling.
+func rasterizePolygon(img *image.RGBA, points []point, c color.NRGBA) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, minY = min(minX, p.X), min(minY, p.Y)
+		maxX, maxY = max(maxX, p.X), max(maxY, p.Y)
+	}
+	bounds := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(img.Bounds())
+
+	const step = 1.0 / polygonSamples
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			covered := 0
+			for sy := 0; sy < polygonSamples; sy++ {
+				for sx := 0; sx < polygonSamples; sx++ {
+					if insidePolygon(points, float64(x)+(float64(sx)+0.5)*step, float64(y)+(float64(sy)+0.5)*step) {
+						covered++
+					}
+				}
+			}
+			if covered > 0 {
+				blend(img, x, y, c, float64(covered)/(polygonSamples*polygonSamples))
+			}
+		}
+	}
+}
+
+// insidePolygon reports whether (x, y) lies inside the polygon (even-odd rule).
+func insidePolygon(points []point, x, y float64) bool {
+	in := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		pi, pj := points[i], points[j]
+		if (pi.Y > y) != (pj.Y > y) && x < (pj.X-pi.X)*(y-pi.Y)/(pj.Y-pi.Y)+pi.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// rasterizeGlyph draws a character of coordinateGlyphs with its top-left corner at min.
+func rasterizeGlyph(img *image.RGBA, r rune, min image.Point, c color.NRGBA) {
+	glyph, ok := coordinateGlyphs[r]
+	if !ok {
+		return
+	}
+	for row, line := range glyph {
+		for col, dot := range line {
+			if dot != '#' {
+				continue
+			}
+			x, y := min.X+col*glyphScale, min.Y+row*glyphScale
+			draw.Draw(img, image.Rect(x, y, x+glyphScale, y+glyphScale), image.NewUniform(c), image.Point{}, draw.Over)
+		}
+	}
+}
+
+// blend draws a single pixel of color c over the image, with the color's alpha scaled by coverage (0..1).
+func blend(img *image.RGBA, x, y int, c color.NRGBA, coverage float64) {
+	a := uint32(float64(c.A) * coverage)
+	dst := img.RGBAAt(x, y)
+	mix := func(s uint8, d uint8) uint8 {
+		return uint8((uint32(s)*a + uint32(d)*(255-a)) / 255)
+	}
+	img.SetRGBA(x, y, color.RGBA{mix(c.R, dst.R), mix(c.G, dst.G), mix(c.B, dst.B), 255})
+}
+
+// loadSprites decodes the bundled piece sprites once.
+func loadSprites() error {
+	spritesOnce.Do(func() {
+		letters := map[chess.PieceType]string{
+			chess.King: "K", chess.Queen: "Q", chess.Rook: "R",
+			chess.Bishop: "B", chess.Knight: "N", chess.Pawn: "P",
+		}
+		sprites = make(map[chess.Piece]image.Image, 12)
+		spritesPNG = make(map[chess.Piece][]byte, 12)
+		for _, c := range []chess.Color{chess.White, chess.Black} {
+			for t, letter := range letters {
+				name := fmt.Sprintf("assets/pieces/%s%s.png", c.String(), letter)
+				data, err := pieceSprites.ReadFile(name)
+				if err != nil {
+					spritesErr = fmt.Errorf("read sprite %s: %w", name, err)
+					return
+				}
+				img, err := png.Decode(bytes.NewReader(data))
+				if err != nil {
+					spritesErr = fmt.Errorf("decode sprite %s: %w", name, err)
+					return
+				}
+				sprites[chess.NewPiece(t, c)] = img
+				spritesPNG[chess.NewPiece(t, c)] = data
+			}
+		}
+	})
+	return spritesErr
+}
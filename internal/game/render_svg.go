@@ -0,0 +1,87 @@
+package game
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// RenderBoardSVG writes the board as an SVG document. It draws the same shapes as RenderBoardPNG:
+// the piece sprites are embedded once as data URIs and reused, arrows become polygons
+// and the coordinates are real text.
+func RenderBoardSVG(b BoardImage) ([]byte, error) {
+	shapes, err := b.shapes()
+	if err != nil {
+		return nil, err
+	}
+	if err = loadSprites(); err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" `+
+		`width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", BoardPixels, BoardPixels, BoardPixels, BoardPixels)
+
+	// Definitions: the sprites of the pieces on the board and the gradient of the check glow.
+	sb.WriteString("<defs>\n")
+	defined := make(map[chess.Piece]bool)
+	for _, s := range shapes {
+		if s.kind != shapePiece || defined[s.piece] {
+			continue
+		}
+		defined[s.piece] = true
+		fmt.Fprintf(&sb, `<image id="%s" width="%d" height="%d" xlink:href="data:image/png;base64,%s"/>`+"\n",
+			spriteID(s.piece), SquareSize, SquareSize, base64.StdEncoding.EncodeToString(spritesPNG[s.piece]))
+	}
+	fmt.Fprintf(&sb, `<radialGradient id="check" r="0.6">`+
+		`<stop offset="0" stop-color="%[1]s"/><stop offset="0.5" stop-color="%[1]s" stop-opacity="0.75"/>`+
+		`<stop offset="1" stop-color="%[1]s" stop-opacity="0"/></radialGradient>`+"\n", svgColor(checkColor))
+	sb.WriteString("</defs>\n")
+
+	for _, s := range shapes {
+		r := s.rect
+		switch s.kind {
+		case shapeRect:
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" %s/>`+"\n",
+				r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgFill(s.color))
+		case shapeGlow:
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="url(#check)"/>`+"\n",
+				r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+		case shapePiece:
+			fmt.Fprintf(&sb, `<use xlink:href="#%s" x="%d" y="%d"/>`+"\n", spriteID(s.piece), r.Min.X, r.Min.Y)
+		case shapePolygon:
+			points := make([]string, len(s.points))
+			for i, p := range s.points {
+				points[i] = fmt.Sprintf("%.1f,%.1f", p.X, p.Y)
+			}
+			fmt.Fprintf(&sb, `<polygon points="%s" %s/>`+"\n", strings.Join(points, " "), svgFill(s.color))
+		case shapeText:
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle" %s>%c</text>`+"\n",
+				(r.Min.X+r.Max.X)/2, r.Max.Y, r.Dy(), svgFill(s.color), s.char)
+		}
+	}
+	sb.WriteString("</svg>\n")
+	return []byte(sb.String()), nil
+}
+
+// spriteID returns the SVG element ID of a piece sprite, e.g. "wK" or "bP".
+func spriteID(p chess.Piece) string {
+	letter := strings.ToUpper(p.Type().String())
+	return p.Color().String() + letter
+}
+
+// svgFill returns the fill attributes of a color, with fill-opacity for translucent colors.
+func svgFill(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf(`fill="%s"`, svgColor(c))
+	}
+	return fmt.Sprintf(`fill="%s" fill-opacity="%.2f"`, svgColor(c), float64(c.A)/255)
+}
+
+// svgColor formats a color as #rrggbb.
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
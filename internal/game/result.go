@@ -0,0 +1,104 @@
+package game
+
+import (
+	"github.com/notnil/chess"
+
+	"lvlchess/internal/db/models"
+)
+
+// ConcludeGame inspects a game right after a move and returns the room result and termination method,
+// or two empty strings if the game goes on. Besides the outcomes notnil/chess detects by itself
+// (mate, stalemate, insufficient material, fivefold repetition, 75-move rule), a threefold repetition
+// or the fifty-move rule is claimed automatically on behalf of the players.
+// Repetitions can only be seen if chGame carries the position history (see MovesRepository.ReplayGame).
+func ConcludeGame(chGame *chess.Game) (result, termination string) {
+	if chGame.Outcome() == chess.NoOutcome {
+		for _, method := range chGame.EligibleDraws() {
+			if method == chess.ThreefoldRepetition || method == chess.FiftyMoveRule {
+				if err := chGame.Draw(method); err == nil {
+					break
+				}
+			}
+		}
+	}
+
+	switch chGame.Outcome() {
+	case chess.WhiteWon:
+		result = models.ResultWhiteWon
+	case chess.BlackWon:
+		result = models.ResultBlackWon
+	case chess.Draw:
+		result = models.ResultDraw
+	default:
+		return models.ResultNone, models.TerminationNone
+	}
+
+	switch chGame.Method() {
+	case chess.Checkmate:
+		termination = models.TerminationCheckmate
+	case chess.Stalemate:
+		termination = models.TerminationStalemate
+	case chess.ThreefoldRepetition, chess.FivefoldRepetition:
+		termination = models.TerminationThreefoldRepetition
+	case chess.FiftyMoveRule, chess.SeventyFiveMoveRule:
+		termination = models.TerminationFiftyMoveRule
+	case chess.InsufficientMaterial:
+		termination = models.TerminationInsufficientMaterial
+	}
+	return result, termination
+}
+
+// ResultForWinner returns "1-0" or "0-1" for the winning color.
+func ResultForWinner(winner chess.Color) string {
+	if winner == chess.White {
+		return models.ResultWhiteWon
+	}
+	return models.ResultBlackWon
+}
+
+// ResultLabel renders a result for chat messages: "1-0", "0-1", "½-½", or "*" if there is none.
+func ResultLabel(result string) string {
+	switch result {
+	case models.ResultDraw:
+		return "½-½"
+	case models.ResultNone:
+		return "*"
+	default:
+		return result
+	}
+}
+
+// TerminationLabel returns a short Russian description of how the game has ended.
+func TerminationLabel(termination string) string {
+	switch termination {
+	case models.TerminationCheckmate:
+		return "мат"
+	case models.TerminationStalemate:
+		return "пат"
+	case models.TerminationThreefoldRepetition:
+		return "повторение позиции"
+	case models.TerminationFiftyMoveRule:
+		return "правило 50 ходов"
+	case models.TerminationInsufficientMaterial:
+		return "недостаточно материала"
+	case models.TerminationResignation:
+		return "сдача"
+	case models.TerminationAgreement:
+		return "соглашение сторон"
+	case models.TerminationAborted:
+		return "партия отменена"
+	case models.TerminationTimeout:
+		return "время"
+	default:
+		return ""
+	}
+}
+
+// FinalSummary formats the closing line of a game, e.g. "Результат: 1-0 (мат)".
+func FinalSummary(room *models.Room) string {
+	summary := "Результат: " + ResultLabel(room.Result)
+	if label := TerminationLabel(room.Termination); label != "" {
+		summary += " (" + label + ")"
+	}
+	return summary
+}
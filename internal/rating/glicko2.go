@@ -0,0 +1,149 @@
+// Package rating implements the Glicko-2 rating system (Mark Glickman, "Example of the Glicko-2 system").
+// Each finished rated game is treated as a rating period of its own for both players.
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1000.0 // Starting rating of a new player (matches the users.rating column default)
+	DefaultRD         = 350.0  // Starting rating deviation: maximal uncertainty
+	DefaultVolatility = 0.06   // Starting volatility
+
+	// MinRD keeps the deviation from collapsing for very active players, so ratings can still move.
+	MinRD = 30.0
+	// MaxRD is the deviation of a completely unknown player.
+	MaxRD = DefaultRD
+
+	// tau constrains the change in volatility over time; 0.3–1.2 is reasonable, smaller is more conservative.
+	tau = 0.5
+	// scale converts between the Glicko and the internal Glicko-2 scale.
+	scale = 173.7178
+	// epsilon is the convergence tolerance of the volatility iteration.
+	epsilon = 0.000001
+)
+
+// Scores of a single game from the point of view of the player being rated.
+const (
+	ScoreLoss = 0.0
+	ScoreDraw = 0.5
+	ScoreWin  = 1.0
+)
+
+// Rating is the Glicko-2 state of a player on the Glicko scale.
+type Rating struct {
+	Rating     float64
+	RD         float64
+	Volatility float64
+}
+
+// Default returns the rating of a player who has not played any rated game yet.
+func Default() Rating {
+	return Rating{Rating: DefaultRating, RD: DefaultRD, Volatility: DefaultVolatility}
+}
+
+// Rate updates both players after a single game. score is the result from the first player's point of view
+// (ScoreWin, ScoreDraw or ScoreLoss).
+func Rate(first, second Rating, score float64) (newFirst, newSecond Rating) {
+	return update(first, result{second, score}), update(second, result{first, 1 - score})
+}
+
+// result is a game of a rating period: the opponent and the score against them.
+type result struct {
+	opponent Rating
+	score    float64
+}
+
+// update applies steps 2–8 of the Glicko-2 algorithm to player for a rating period with the given games.
+func update(player Rating, results ...result) Rating {
+	player = sanitize(player)
+
+	// Step 2: convert to the Glicko-2 scale. Only rating differences matter,
+	// so centring on DefaultRating instead of the paper's 1500 changes nothing.
+	mu := (player.Rating - DefaultRating) / scale
+	phi := player.RD / scale
+
+	// Steps 3–4: estimated variance v and improvement delta.
+	var vInv, gain float64
+	for _, r := range results {
+		opponent := sanitize(r.opponent)
+		muJ := (opponent.Rating - DefaultRating) / scale
+		gJ := g(opponent.RD / scale)
+		eJ := expected(mu, muJ, gJ)
+		vInv += gJ * gJ * eJ * (1 - eJ)
+		gain += gJ * (r.score - eJ)
+	}
+	v := 1 / vInv
+	delta := v * gain
+
+	// Step 5: new volatility.
+	sigma := newVolatility(phi, player.Volatility, v, delta)
+
+	// Steps 6–7: new deviation and rating.
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*gain
+
+	// Step 8: back to the Glicko scale.
+	return Rating{
+		Rating:     newMu*scale + DefaultRating,
+		RD:         math.Min(math.Max(newPhi*scale, MinRD), MaxRD),
+		Volatility: sigma,
+	}
+}
+
+// g reduces the impact of a game according to the opponent's deviation.
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// expected is the expected score against an opponent.
+func expected(mu, muJ, gJ float64) float64 {
+	return 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm (step 5 of the paper).
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		num := ex * (delta*delta - phi*phi - v - ex)
+		den := 2 * (phi*phi + v + ex) * (phi*phi + v + ex)
+		return num/den - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// sanitize replaces missing or out-of-range values (e.g. from rows created before ratings existed) with defaults.
+func sanitize(r Rating) Rating {
+	if r.RD <= 0 || r.RD > MaxRD {
+		r.RD = DefaultRD
+	}
+	if r.Volatility <= 0 {
+		r.Volatility = DefaultVolatility
+	}
+	return r
+}
@@ -0,0 +1,49 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+// shift moves a rating of Glickman's paper, centred at 1500, to this package's centre.
+func shift(r float64) float64 {
+	return r - 1500 + DefaultRating
+}
+
+// TestGlickmanExample checks the worked example of "Example of the Glicko-2 system": a 1500/200 player
+// beats a 1400/30 player and loses to 1550/100 and 1700/300 players in one rating period.
+func TestGlickmanExample(t *testing.T) {
+	player := Rating{Rating: shift(1500), RD: 200, Volatility: DefaultVolatility}
+	got := update(player,
+		result{Rating{Rating: shift(1400), RD: 30, Volatility: DefaultVolatility}, ScoreWin},
+		result{Rating{Rating: shift(1550), RD: 100, Volatility: DefaultVolatility}, ScoreLoss},
+		result{Rating{Rating: shift(1700), RD: 300, Volatility: DefaultVolatility}, ScoreLoss},
+	)
+
+	checks := []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"rating", got.Rating, shift(1464.06), 0.01},
+		{"RD", got.RD, 151.52, 0.01},
+		{"volatility", got.Volatility, 0.05999, 0.00001},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > c.tolerance {
+			t.Errorf("%s = %.5f, want %.5f", c.name, c.got, c.want)
+		}
+	}
+}
+
+// TestMinRD checks that the deviation of a settled player does not drop below MinRD.
+func TestMinRD(t *testing.T) {
+	settled := Rating{Rating: DefaultRating, RD: MinRD, Volatility: 0.0001}
+	first, second := Rate(settled, settled, ScoreDraw)
+	if first.RD != MinRD || second.RD != MinRD {
+		t.Errorf("RD = %v, %v, want %v", first.RD, second.RD, MinRD)
+	}
+	if first.Rating != DefaultRating || second.Rating != DefaultRating {
+		t.Errorf("rating after a draw of equals = %v, %v, want %v", first.Rating, second.Rating, DefaultRating)
+	}
+}
@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"lvlchess/internal/analysis"
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/engine"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+const (
+	// analysisMoveTime is the engine budget per position of the post-game analysis.
+	analysisMoveTime = 300 * time.Millisecond
+	// analysisMaxMoments bounds the number of marked moves listed in the report message.
+	analysisMaxMoments = 15
+)
+
+// runningAnalyses holds the IDs of rooms being analysed, so that a finished game is analysed only once
+// even if the "📊 Анализ" button is pressed while the automatic analysis is still running.
+var runningAnalyses sync.Map
+
+// startAnalysis launches the post-game analysis of a finished room in the background.
+// Aborted games are not analysed.
+func (h *Handler) startAnalysis(ctx context.Context, room *models.Room) {
+	if room.Termination == models.TerminationAborted {
+		return
+	}
+	roomID := room.RoomID
+	h.runInBackground(func(ctx context.Context) { h.analyzeRoom(ctx, roomID, nil) })
+}
+
+// handleAnalysisCallback is triggered by the "📊 Анализ" button of a finished game: it sends the stored
+// report to the user, analysing the game first if that has not happened yet.
+func (h *Handler) handleAnalysisCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if !isRoomParticipant(room, query.From.ID) {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Вы не являетесь участником этой комнаты."))
+		return
+	}
+	if room.Status != models.RoomStatusFinished || room.Termination == models.TerminationAborted {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Анализ доступен только для завершённых партий."))
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	h.runInBackground(func(ctx context.Context) { h.analyzeRoom(ctx, roomID, &chatID) })
+}
+
+// analyzeRoom runs every position of the room's game through the engine, stores the per-move analysis
+// and sends the report: to chatID if given, otherwise to the room's group or both players.
+// An already analysed game is not analysed again, its stored report is sent instead.
+func (h *Handler) analyzeRoom(ctx context.Context, roomID string, chatID *int64) {
+	if _, running := runningAnalyses.LoadOrStore(roomID, struct{}{}); running {
+		if chatID != nil {
+			h.Bot.Send(tgbotapi.NewMessage(*chatID, "⏳ Партия уже анализируется, отчёт скоро придёт."))
+		}
+		return
+	}
+	defer runningAnalyses.Delete(roomID)
+
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		utils.Logger.Error("analyzeRoom: room not found", zap.String("roomID", roomID), zap.Error(err))
+		return
+	}
+	moves, err := h.MoveRepo.GetMovesByRoomID(ctx, roomID)
+	if err != nil {
+		utils.Logger.Error("GetMovesByRoomID: "+err.Error(), zap.Error(err))
+		return
+	}
+	if len(moves) == 0 {
+		if chatID != nil {
+			h.Bot.Send(tgbotapi.NewMessage(*chatID, "В партии не было ходов — анализировать нечего."))
+		}
+		return
+	}
+
+	if moves[0].Eval == nil {
+		if chatID != nil {
+			h.Bot.Send(tgbotapi.NewMessage(*chatID, "⏳ Анализирую партию, это займёт немного времени..."))
+		}
+		if moves, err = h.runAnalysis(ctx, room, moves); err != nil {
+			utils.Logger.Error("runAnalysis: "+err.Error(), zap.String("roomID", roomID), zap.Error(err))
+			if chatID != nil {
+				h.Bot.Send(tgbotapi.NewMessage(*chatID, "Не удалось проанализировать партию."))
+			}
+			return
+		}
+	}
+
+	text := formatAnalysisReport(room, moves)
+	if chatID != nil {
+		msg := tgbotapi.NewMessage(*chatID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.Bot.Send(msg)
+		return
+	}
+	h.sendMessageToRoomOrUsers(ctx, room, text, tgbotapi.ModeHTML)
+}
+
+// runAnalysis analyses the game of the room with h.Engine, stores the result on its moves and returns them.
+func (h *Handler) runAnalysis(ctx context.Context, room *models.Room, moves []models.RoomMove) ([]models.RoomMove, error) {
+	chGame, err := h.MoveRepo.ReplayGame(ctx, room.RoomID, room.InitialFEN)
+	if err != nil {
+		return nil, err
+	}
+	report, err := analysis.Analyze(ctx, h.Engine, chGame, engine.Limits{MoveTime: analysisMoveTime, Game: room.RoomID})
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Moves) != len(moves) {
+		return nil, fmt.Errorf("analysed %d moves, %d stored", len(report.Moves), len(moves))
+	}
+
+	for i, m := range report.Moves {
+		eval := m.EvalAfter
+		moves[i].Eval = &eval
+		moves[i].CPLoss = m.CPLoss
+		moves[i].Accuracy = m.Accuracy
+		moves[i].Judgment = m.Judgment
+		moves[i].BestSAN = ""
+		if m.Judgment != analysis.JudgmentNone {
+			moves[i].BestSAN = m.BestSAN
+		}
+	}
+	if err = h.MoveRepo.SaveAnalysis(ctx, moves); err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// formatAnalysisReport renders the stored analysis of a game as an HTML message: accuracy, average
+// centipawn loss and the count of inaccuracies/mistakes/blunders per player, then the marked moves
+// with the better alternative.
+func formatAnalysisReport(room *models.Room, moves []models.RoomMove) string {
+	whiteFirst, startNumber := true, 1
+	if room.InitialFEN != "" {
+		fields := strings.Fields(room.InitialFEN)
+		whiteFirst = len(fields) < 2 || fields[1] != "b"
+		startNumber = game.FullMoveNumber(room.InitialFEN)
+	}
+
+	analysed := make([]analysis.Move, len(moves))
+	for i, mv := range moves {
+		color := chess.White
+		if (i%2 == 0) != whiteFirst {
+			color = chess.Black
+		}
+		analysed[i] = analysis.Move{
+			Ply:      mv.Ply,
+			Color:    color,
+			SAN:      mv.SAN,
+			CPLoss:   mv.CPLoss,
+			Accuracy: mv.Accuracy,
+			Judgment: mv.Judgment,
+			BestSAN:  mv.BestSAN,
+		}
+		if mv.Eval != nil {
+			analysed[i].EvalAfter = *mv.Eval
+		}
+	}
+	white, black := analysis.Summarize(analysed)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 <b>Анализ партии</b> %s\n\n", html.EscapeString(room.RoomTitle)))
+	sb.WriteString(formatPlayerAnalysis("⚪ Белые", white))
+	sb.WriteString(formatPlayerAnalysis("⚫ Чёрные", black))
+
+	var moments []string
+	for i, m := range analysed {
+		if m.Judgment == analysis.JudgmentNone {
+			continue
+		}
+		offset := i
+		if !whiteFirst {
+			offset++
+		}
+		number := fmt.Sprintf("%d.", startNumber+offset/2)
+		if m.Color == chess.Black {
+			number = fmt.Sprintf("%d...", startNumber+offset/2)
+		}
+		line := fmt.Sprintf("%s %s%s — %s (%s)", number, m.SAN, analysis.Symbol(m.Judgment),
+			judgmentName(m.Judgment), analysis.FormatEval(m.EvalAfter))
+		if m.BestSAN != "" {
+			line += ", лучше " + m.BestSAN
+		}
+		moments = append(moments, line)
+	}
+	if len(moments) == 0 {
+		sb.WriteString("\nНи одной неточности — отличная партия! 👏")
+		return sb.String()
+	}
+
+	sb.WriteString("\n<b>Ключевые моменты:</b>\n")
+	for i, line := range moments {
+		if i == analysisMaxMoments {
+			sb.WriteString(fmt.Sprintf("…и ещё %d (полный разбор — в PGN)\n", len(moments)-i))
+			break
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// formatPlayerAnalysis renders the summary line of one side of the analysis report.
+func formatPlayerAnalysis(side string, p analysis.Player) string {
+	return fmt.Sprintf("%s: точность <b>%.1f%%</b>, средняя потеря %d сп.\n"+
+		"   неточностей: %d, ошибок: %d, зевков: %d\n",
+		side, p.Accuracy, p.ACPL, p.Inaccuracies, p.Mistakes, p.Blunders)
+}
+
+// judgmentName returns the Russian name of a move judgment.
+func judgmentName(judgment string) string {
+	switch judgment {
+	case analysis.JudgmentInaccuracy:
+		return "неточность"
+	case analysis.JudgmentMistake:
+		return "ошибка"
+	case analysis.JudgmentBlunder:
+		return "зевок"
+	default:
+		return ""
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"lvlchess/config"
 	// "lvlchess/internal/db" could be used if we needed direct db access here, but we rely on repos in Handler
 	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -45,14 +46,19 @@ func (h *Handler) handleStartCommand(ctx context.Context, update tgbotapi.Update
 	btnMyTournaments := tgbotapi.NewInlineKeyboardButtonData("📃 Мои турниры", "tournament_list")
 	btnPlayBot := tgbotapi.NewInlineKeyboardButtonData("🤖 Играть с ботом", PlayWithBot)
 	btnSetupRoom := tgbotapi.NewInlineKeyboardButtonData("⚙️ Создать и настроить комнату", SetupRoom)
+	btnFromPosition := tgbotapi.NewInlineKeyboardButtonData("♟ Комната из позиции (FEN/PGN)", CreateFromPosition)
+	btnLeaderboard := tgbotapi.NewInlineKeyboardButtonData("🏆 Рейтинг-лист", Leaderboard)
+	btnBoardStyle := tgbotapi.NewInlineKeyboardButtonData("🖼 Вид доски: картинка / текст", ToggleBoardStyle)
 
-	btnPlayGame := tgbotapi.NewInlineKeyboardButtonWebApp("▶️ Играть в lvlChess", tgbotapi.WebAppInfo{config.Cfg.GameURL})
+	btnPlayGame := tgbotapi.NewInlineKeyboardButtonWebApp("▶️ Играть в lvlChess", tgbotapi.WebAppInfo{URL: config.Cfg.GameURL})
 
 	// You can arrange these buttons in multiple rows as below.
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(btnCreateRoom, btnMyGames),
 		tgbotapi.NewInlineKeyboardRow(btnPlayBot, btnSetupRoom),
+		tgbotapi.NewInlineKeyboardRow(btnFromPosition, btnLeaderboard),
 		tgbotapi.NewInlineKeyboardRow(btnCreateTournament, btnMyTournaments),
+		tgbotapi.NewInlineKeyboardRow(btnBoardStyle),
 		tgbotapi.NewInlineKeyboardRow(btnPlayGame),
 	)
 
@@ -62,14 +68,23 @@ func (h *Handler) handleStartCommand(ctx context.Context, update tgbotapi.Update
 	h.Bot.Send(msg)
 }
 
-// handlePlayWithBotCommand is a placeholder for a future feature: playing vs an AI or local engine.
-// Currently, we simply send a message "In development."
-func (h *Handler) handlePlayWithBotCommand(_ context.Context, query *tgbotapi.CallbackQuery) {
-	msg := tgbotapi.NewMessage(query.Message.Chat.ID, "Игра с ботом в разработке.")
-	h.Bot.Send(msg)
+// handleToggleBoardStyle switches the way the board is shown to the user between the PNG picture
+// and the ASCII text board.
+func (h *Handler) handleToggleBoardStyle(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	style, text := models.BoardStyleASCII, "Теперь доска будет приходить текстом (ASCII)."
+	if h.boardStyle(ctx, query.From.ID) == models.BoardStyleASCII {
+		style, text = models.BoardStyleImage, "Теперь доска будет приходить картинкой."
+	}
+	if err := h.UserRepo.SetBoardStyle(ctx, query.From.ID, style); err != nil {
+		text = "Не удалось сохранить настройку."
+	}
+	h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, text))
 }
 
-// handleGameListCommand lists all active rooms (waiting/playing) for the user, if any.
+// finishedGamesInList is how many recently finished games are shown under the active ones in "Мои игры".
+const finishedGamesInList = 10
+
+// handleGameListCommand lists the user's active rooms (waiting/playing) and their most recently finished games.
 // Called when user presses a "Мои игры" (my games) button, or potentially some command callback.
 func (h *Handler) handleGameListCommand(ctx context.Context, query *tgbotapi.CallbackQuery) {
 	userID := query.From.ID
@@ -80,28 +95,80 @@ func (h *Handler) handleGameListCommand(ctx context.Context, query *tgbotapi.Cal
 			"Ошибка при получении списка игр: "+err.Error()))
 		return
 	}
+	finished, err := h.RoomRepo.GetFinishedRoomsForUser(ctx, userID, finishedGamesInList)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Ошибка при получении списка игр: "+err.Error()))
+		return
+	}
 
-	if len(rooms) == 0 {
+	if len(rooms) == 0 && len(finished) == 0 {
 		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
 			"У вас нет активных игр."))
 		return
 	}
 
-	// Construct an inline keyboard, one row per room, showing which side is to move.
-	var rows [][]tgbotapi.InlineKeyboardButton
-	for i, room := range rooms {
-		turnTitle := getCurrentTurnUsername(&room)
-		buttonText := fmt.Sprintf("Комната_№%d: %s (ход @%s)",
-			i+1, room.RoomTitle, turnTitle)
-		callbackData := fmt.Sprintf("%s:%s", RoomID, room.RoomID)
-		btn := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
-		rows = append(rows, []tgbotapi.InlineKeyboardButton{btn})
+	// Active games: one row per room, showing which side is to move,
+	// plus an "Export PGN" button next to each room.
+	if len(rooms) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "У вас нет активных игр."))
+	} else {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for i, room := range rooms {
+			turnTitle := getCurrentTurnUsername(&room)
+			buttonText := fmt.Sprintf("Комната_№%d: %s (ход @%s)",
+				i+1, room.RoomTitle, turnTitle)
+			if room.Status == models.RoomStatusWaiting {
+				buttonText = fmt.Sprintf("Комната_№%d: %s (ждём соперника)", i+1, room.RoomTitle)
+			}
+			callbackData := fmt.Sprintf("%s:%s", RoomID, room.RoomID)
+			btn := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
+			pgnBtn := tgbotapi.NewInlineKeyboardButtonData("📄 PGN", fmt.Sprintf("%s:%s", ExportPGN, room.RoomID))
+			rows = append(rows, []tgbotapi.InlineKeyboardButton{btn, pgnBtn})
+		}
+
+		msg := tgbotapi.NewMessage(query.Message.Chat.ID, "Ваши активные игры:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		h.Bot.Send(msg)
 	}
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
-	msg := tgbotapi.NewMessage(query.Message.Chat.ID, "Ваши активные игры:")
-	msg.ReplyMarkup = keyboard
-	h.Bot.Send(msg)
+	// Finished games: the result from the user's point of view, with the PGN export
+	// and (unless the game was aborted) the post-game analysis and the animated replay.
+	if len(finished) > 0 {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, room := range finished {
+			buttonText := fmt.Sprintf("%s %s: %s", resultIconFor(&room, userID), room.RoomTitle, game.FinalSummary(&room))
+			btn := tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("%s:%s", ExportPGN, room.RoomID))
+			row := tgbotapi.NewInlineKeyboardRow(btn)
+			if room.Termination != models.TerminationAborted {
+				row = append(row,
+					tgbotapi.NewInlineKeyboardButtonData("📊 Анализ", fmt.Sprintf("%s:%s", ShowAnalysis, room.RoomID)),
+					tgbotapi.NewInlineKeyboardButtonData("🎞", fmt.Sprintf("%s:%s", ShowReplay, room.RoomID)))
+			}
+			rows = append(rows, row)
+		}
+
+		msg := tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Завершённые игры (нажмите, чтобы получить PGN; 🎞 — повтор партии):")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		h.Bot.Send(msg)
+	}
+}
+
+// resultIconFor returns 🏆 for a won game, 💀 for a lost one, 🤝 for a draw and ✖ for an aborted game,
+// from the point of view of userID.
+func resultIconFor(r *models.Room, userID int64) string {
+	switch {
+	case r.Result == models.ResultDraw:
+		return "🤝"
+	case r.Result == models.ResultWhiteWon && r.WhiteID != nil && *r.WhiteID == userID,
+		r.Result == models.ResultBlackWon && r.BlackID != nil && *r.BlackID == userID:
+		return "🏆"
+	case r.Result == models.ResultNone:
+		return "✖"
+	default:
+		return "💀"
+	}
 }
 
 // getCurrentTurnUsername is a helper that returns who is to move: "белых" or "чёрных."
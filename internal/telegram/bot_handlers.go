@@ -0,0 +1,232 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"lvlchess/config"
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/engine"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// botMoveTime is how long the engine may think about each of its moves
+	// (lower levels think less, see engine.LevelStrength).
+	botMoveTime = 2 * time.Second
+	// botDrawMoveTime bounds the quick evaluation the bot makes when offered a draw.
+	botDrawMoveTime = 500 * time.Millisecond
+	// botDrawAcceptScore is the evaluation (in centipawns, from the bot's side) at or below which
+	// the bot accepts a draw offer.
+	botDrawAcceptScore = -150
+)
+
+// newEngine returns the engine configured in config.Cfg: the external UCI binary from UCI_ENGINE_PATH
+// if it is set and starts correctly, the built-in engine otherwise.
+func newEngine() engine.Engine {
+	cfg := config.Cfg.EngineConfig
+	if cfg.UCIEnginePath == "" {
+		return engine.NewBuiltin()
+	}
+	uci, err := engine.NewUCI(engine.UCIConfig{
+		Path:     cfg.UCIEnginePath,
+		Args:     cfg.UCIEngineArgs,
+		PoolSize: cfg.UCIEnginePool,
+		Options:  cfg.UCIEngineOptions,
+	})
+	if err != nil {
+		utils.Logger.Error("UCI engine unavailable, using the built-in engine: "+err.Error(), zap.Error(err))
+		return engine.NewBuiltin()
+	}
+	utils.Logger.Info("Using UCI engine: " + uci.Name())
+	return uci
+}
+
+// isBot reports whether userID is the bot itself, i.e. the engine opponent of a "Play with bot" room.
+func (h *Handler) isBot(userID int64) bool {
+	return userID == h.Bot.Self.ID
+}
+
+// isBotRoom reports whether one of the room's players is the bot.
+func (h *Handler) isBotRoom(room *models.Room) bool {
+	return room.Player2ID != nil && h.isBot(*room.Player2ID)
+}
+
+// ensureBotUser makes sure the bot has a "users" row, so it can be stored as a room player and move author.
+func (h *Handler) ensureBotUser(ctx context.Context) error {
+	return h.UserRepo.CreateOrUpdateUser(ctx, &models.User{
+		ID:        h.Bot.Self.ID,
+		Username:  h.Bot.Self.UserName,
+		FirstName: h.Bot.Self.FirstName,
+		ChatID:    h.Bot.Self.ID, // never used: messages to the bot are skipped in sendMessageToUser
+	})
+}
+
+// handlePlayWithBotCommand is triggered by the "🤖 Играть с ботом" button and offers the bot levels.
+// A user can have only one unfinished game with the bot: pressing the button again shows that game instead.
+func (h *Handler) handlePlayWithBotCommand(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	if h.resumeBotGame(ctx, chatID, query.From.ID) {
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for level := engine.MinLevel; level <= engine.MaxLevel; level++ {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			strconv.Itoa(level), fmt.Sprintf("%s%s%d", ChooseBotLevel, CommandDelimiter, level)))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Выберите уровень бота: 1 — новичок (бот нередко ошибается), %d — максимальная сила.", engine.MaxLevel))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.Bot.Send(msg)
+}
+
+// handleBotLevelCallback is triggered by "bot_level:<level>". It starts a casual game against the engine
+// at the chosen level, with random colors.
+func (h *Handler) handleBotLevelCallback(ctx context.Context, query *tgbotapi.CallbackQuery, param string) {
+	chatID := query.Message.Chat.ID
+	level, err := strconv.Atoi(param)
+	if err != nil || level < engine.MinLevel || level > engine.MaxLevel {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Неизвестный уровень бота."))
+		return
+	}
+	if err = h.ensureBotUser(ctx); err != nil {
+		utils.Logger.Error("ensureBotUser: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось начать игру с ботом."))
+		return
+	}
+	if h.resumeBotGame(ctx, chatID, query.From.ID) {
+		return
+	}
+
+	room := models.PrepareNewRoom(query.From.ID, "")
+	room.Rated = false // engine games do not count for the rating
+	room.BotLevel = level
+	if err = h.RoomRepo.CreateRoom(ctx, room); err != nil {
+		utils.Logger.Error("CreateRoom (bot): "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Ошибка создания комнаты: "+err.Error()))
+		return
+	}
+
+	botID := h.Bot.Self.ID
+	room.Player2ID = &botID
+	room.Status = models.RoomStatusPlaying
+	game.AssignRandomColors(room)
+	room.RoomTitle = h.MakeFinalTitle(ctx, room)
+	if err = h.RoomRepo.UpdateRoom(ctx, room); err != nil {
+		utils.Logger.Error("UpdateRoom (bot): "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Ошибка обновления комнаты: "+err.Error()))
+		return
+	}
+
+	h.notifyGameStarted(ctx, room)
+}
+
+// resumeBotGame looks for an unfinished game of the user against the bot. If there is one,
+// it shows that game again and returns true.
+func (h *Handler) resumeBotGame(ctx context.Context, chatID, userID int64) bool {
+	rooms, err := h.RoomRepo.GetPlayingRoomsForUser(ctx, userID)
+	if err != nil {
+		utils.Logger.Error("GetPlayingRoomsForUser: "+err.Error(), zap.Error(err))
+		return false
+	}
+	for i := range rooms {
+		if room := &rooms[i]; h.isBotRoom(room) && room.Status == models.RoomStatusPlaying {
+			h.Bot.Send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("У вас уже есть партия с ботом (уровень %d) — продолжим её.", room.BotLevel)))
+			h.forgetRoomMessages(ctx, room.RoomID, chatID)
+			h.SendBoardToRoomOrUsers(ctx, room)
+			h.promptNextMove(ctx, room)
+			return true
+		}
+	}
+	return false
+}
+
+// playBotMove lets the engine reply in the given room. It runs in its own goroutine (see promptNextMove),
+// so it reloads the room and silently gives up if the game has ended or it is not the bot's turn anymore.
+func (h *Handler) playBotMove(ctx context.Context, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		utils.Logger.Error("playBotMove: room not found", zap.String(RoomID, roomID), zap.Error(err))
+		return
+	}
+	if room.Status != models.RoomStatusPlaying || !h.isBot(h.sideToMoveID(room)) {
+		return
+	}
+
+	chGame, err := h.loadRoomGame(ctx, room)
+	if err != nil {
+		utils.Logger.Error("playBotMove: loadRoomGame: "+err.Error(), zap.Error(err))
+		return
+	}
+	res, err := h.Engine.Search(ctx, chGame, engine.Limits{MoveTime: botMoveTime, Level: room.BotLevel, Game: room.RoomID})
+	if err != nil {
+		utils.Logger.Error("playBotMove: Search: "+err.Error(), zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	if game.IsFlagged(room, now) {
+		h.finishOnTime(ctx, room)
+		return
+	}
+	prePos := chGame.Position()
+	if err = chGame.Move(res.Move); err != nil {
+		utils.Logger.Error("playBotMove: illegal engine move: "+err.Error(), zap.Error(err))
+		return
+	}
+	if err = h.recordMove(ctx, room, chGame, prePos, res.Move, h.Bot.Self.ID, now); err != nil {
+		// Most likely the player resigned or aborted while the engine was thinking.
+		utils.Logger.Warn("playBotMove: recordMove: "+err.Error(), zap.Error(err))
+		return
+	}
+	h.announceMove(ctx, room)
+}
+
+// answerBotDrawOffer replies to a draw offer made to the bot: the engine takes a quick look at the
+// position and agrees only if it is clearly worse.
+func (h *Handler) answerBotDrawOffer(ctx context.Context, room *models.Room) {
+	accept := false
+	if chGame, err := h.loadRoomGame(ctx, room); err == nil {
+		res, err := h.Engine.Search(ctx, chGame, engine.Limits{MoveTime: botDrawMoveTime, Game: room.RoomID})
+		if err == nil {
+			score := res.Score
+			if !h.isBot(h.sideToMoveID(room)) {
+				score = -score // the score is from the side to move, i.e. the player
+			}
+			accept = score <= botDrawAcceptScore
+		} else if !errors.Is(err, engine.ErrNoMoves) {
+			utils.Logger.Error("answerBotDrawOffer: Search: "+err.Error(), zap.Error(err))
+		}
+	}
+
+	if accept && h.finishGame(ctx, room, models.ResultDraw, models.TerminationAgreement, "🤖 Бот принял ничью.") {
+		return
+	}
+	h.sendMessageToRoomOrUsers(ctx, room, "🤖 Бот отклонил предложение ничьей. Игра продолжается.", tgbotapi.ModeHTML)
+}
+
+// sideToMoveID returns the ID of the player whose turn it is.
+func (h *Handler) sideToMoveID(room *models.Room) int64 {
+	if room.IsWhiteTurn {
+		return *room.WhiteID
+	}
+	return *room.BlackID
+}
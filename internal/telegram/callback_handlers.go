@@ -0,0 +1,211 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"lvlchess/config"
+	"lvlchess/internal/callbackdata"
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// newCallbackCodec creates the codec of the in-game buttons. Without CALLBACK_SECRET the signing key
+// is derived from the bot token, so it stays the same across restarts.
+func newCallbackCodec() *callbackdata.Codec {
+	key := []byte(config.Cfg.CallbackSecret)
+	if len(key) == 0 {
+		sum := sha256.Sum256([]byte("lvlchess callbacks:" + config.Cfg.BotToken))
+		key = sum[:]
+	}
+	return callbackdata.NewCodec(key, config.Cfg.CallbackTTL)
+}
+
+// signedCallback returns the signed callback data of an in-game button of the room.
+// It fails only for a room ID that is not a UUID or a payload that is too long; the keyboard
+// must not be sent then, as Telegram rejects a whole keyboard with an empty button.
+func (h *Handler) signedCallback(action callbackdata.Action, roomID string, payload ...byte) (string, error) {
+	data, err := h.Callbacks.Encode(action, roomID, payload...)
+	if err != nil {
+		return "", fmt.Errorf("signedCallback %s: %w", roomID, err)
+	}
+	return data, nil
+}
+
+// keyboardFailed logs why a room keyboard could not be built and tells the players.
+func (h *Handler) keyboardFailed(ctx context.Context, room *models.Room, err error) {
+	utils.Logger.Error("room keyboard: "+err.Error(), zap.String("roomID", room.RoomID), zap.Error(err))
+	h.sendMessageToRoomOrUsers(ctx, room, "Не удалось подготовить кнопки, попробуйте ещё раз.", tgbotapi.ModeHTML)
+}
+
+// handleSignedCallback decodes the data of a room button (in-game actions, time control) and dispatches it.
+// Expired buttons are replaced with a fresh keyboard if it is the user's turn; tampered or unknown ones
+// are rejected with an alert.
+func (h *Handler) handleSignedCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	d, err := h.Callbacks.Decode(query.Data)
+	if errors.Is(err, callbackdata.ErrExpired) {
+		h.answerAlert(query, "Эта кнопка устарела.")
+		h.refreshKeyboard(ctx, query, d.RoomID)
+		return
+	}
+	if err != nil {
+		utils.Logger.Warn("rejected callback data: "+err.Error(), zap.Int64("userID", query.From.ID), zap.Error(err))
+		h.answerAlert(query, "Некорректная кнопка.")
+		return
+	}
+
+	switch d.Action {
+	case callbackdata.ChooseFigure:
+		if squares, ok := payloadSquares(d.Payload, 1); ok && len(d.Payload) == 1+plySize {
+			if h.isCurrentPly(ctx, query, d.RoomID, d.Payload[1:]) {
+				h.handleChooseFigureCallback(ctx, query, d.RoomID, squares[0])
+			}
+			return
+		}
+	case callbackdata.ChoosePromo:
+		if squares, ok := payloadSquares(d.Payload, 2); ok && len(d.Payload) == 2+plySize {
+			if h.isCurrentPly(ctx, query, d.RoomID, d.Payload[2:]) {
+				h.handleChoosePromotionCallback(ctx, query, d.RoomID, squares[0], squares[1])
+			}
+			return
+		}
+	case callbackdata.Move:
+		if squares, ok := payloadSquares(d.Payload, 2); ok && len(d.Payload) == 3+plySize {
+			promo := chess.PieceType(d.Payload[2])
+			if promo == chess.NoPieceType || promo >= chess.Queen && promo <= chess.Knight {
+				if h.isCurrentPly(ctx, query, d.RoomID, d.Payload[3:]) {
+					h.handleMoveCallback(ctx, query, d.RoomID, squares[0], squares[1], promo)
+				}
+				return
+			}
+		}
+	case callbackdata.BackToFigures:
+		if len(d.Payload) == plySize {
+			if h.isCurrentPly(ctx, query, d.RoomID, d.Payload) {
+				h.handleBackToFiguresCallback(ctx, query, d.RoomID)
+			}
+			return
+		}
+	case callbackdata.Resign:
+		h.handleResignCallback(ctx, query, d.RoomID)
+		return
+	case callbackdata.OfferDraw:
+		h.handleOfferDrawCallback(ctx, query, d.RoomID)
+		return
+	case callbackdata.AcceptDraw:
+		h.handleAcceptDrawCallback(ctx, query, d.RoomID)
+		return
+	case callbackdata.DeclineDraw:
+		h.handleDeclineDrawCallback(ctx, query, d.RoomID)
+		return
+	case callbackdata.Abort:
+		h.handleAbortCallback(ctx, query, d.RoomID)
+		return
+	case callbackdata.SetTimeControl:
+		h.handleSetTimeControlCallback(ctx, query, d.RoomID, string(d.Payload))
+		return
+	}
+	utils.Logger.Warn("bad callback payload", zap.Any("action", d.Action), zap.Binary("payload", d.Payload))
+	h.answerAlert(query, "Некорректная кнопка.")
+}
+
+// payloadSquares reads the first n squares from the payload of a button.
+func payloadSquares(payload []byte, n int) ([]chess.Square, bool) {
+	if len(payload) < n {
+		return nil, false
+	}
+	squares := make([]chess.Square, n)
+	for i := range squares {
+		if payload[i] > byte(chess.H8) {
+			return nil, false
+		}
+		squares[i] = chess.Square(payload[i])
+	}
+	return squares, true
+}
+
+// plySize is the length of the ply counter at the end of the payload of the piece, move, promotion
+// and back-to-pieces buttons.
+const plySize = 2
+
+// plyPayload returns the number of plies played in the room, encoded for a move keyboard button.
+// A button carries the ply it was made for, so a keyboard left from an earlier position can be told apart
+// from the current one even when it has not expired yet. Without the count the keyboard must not be sent:
+// buttons with a wrong ply would all be rejected as stale.
+func (h *Handler) plyPayload(ctx context.Context, roomID string) ([]byte, error) {
+	plies, err := h.MoveRepo.CountMoves(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("plyPayload: %w", err)
+	}
+	return binary.BigEndian.AppendUint16(nil, uint16(plies)), nil
+}
+
+// isCurrentPly reports whether a move keyboard button was made for the current position of the room.
+// A button of an earlier position (the move was already made, e.g. by a double tap, from another message,
+// or typed) is rejected with an alert, and the keyboard is refreshed.
+func (h *Handler) isCurrentPly(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string, ply []byte) bool {
+	plies, err := h.MoveRepo.CountMoves(ctx, roomID)
+	if err != nil {
+		utils.Logger.Error("CountMoves: "+err.Error(), zap.Error(err))
+		h.answerAlert(query, "Не удалось проверить позицию, попробуйте ещё раз.")
+		return false
+	}
+	if binary.BigEndian.Uint16(ply) == uint16(plies) {
+		return true
+	}
+	utils.Logger.Info("stale move button",
+		zap.String("roomID", roomID), zap.Uint16("buttonPly", binary.BigEndian.Uint16(ply)), zap.Int("plies", plies))
+	h.answerAlert(query, "Позиция на доске изменилась, эта кнопка устарела.")
+	h.refreshKeyboard(ctx, query, roomID)
+	return false
+}
+
+// refreshKeyboard replaces a keyboard with outdated buttons: the side to move gets a fresh move keyboard,
+// otherwise the buttons are removed.
+func (h *Handler) refreshKeyboard(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err == nil && room != nil && room.Status == models.RoomStatusPlaying && h.sideToMoveID(room) == query.From.ID {
+		h.prepareMoveButtons(ctx, room, query.From.ID)
+		return
+	}
+	h.removeButtons(query.Message)
+}
+
+// legacyGameActions are the in-game actions of the plain "action:param" buttons sent before the callback data
+// was signed.
+var legacyGameActions = []string{
+	ActionChooseFigure, ActionChoosePromo, ActionBackToFigures, ActionMove,
+	ActionResign, ActionOfferDraw, ActionAcceptDraw, ActionDeclineDraw, ActionAbort, SetTimeControl,
+}
+
+// isLegacyGameCallback reports whether data comes from an in-game button of the old, unsigned format.
+func isLegacyGameCallback(data string) bool {
+	for _, action := range legacyGameActions {
+		if strings.HasPrefix(data, fmt.Sprintf("%s%s", action, CommandDelimiter)) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleLegacyGameCallback rejects an in-game button of the old format: such buttons are not signed, so they
+// are not trusted anymore. The next move brings a new keyboard.
+func (h *Handler) handleLegacyGameCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	h.answerAlert(query, "Эта кнопка устарела.")
+	h.removeButtons(query.Message)
+}
+
+// answerAlert answers a callback query with a pop-up alert.
+func (h *Handler) answerAlert(query *tgbotapi.CallbackQuery, text string) {
+	if _, err := h.Bot.Request(tgbotapi.NewCallbackWithAlert(query.ID, text)); err != nil {
+		utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+	}
+}
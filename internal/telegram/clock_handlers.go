@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lvlchess/internal/callbackdata"
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// handleTimeControlMenu shows the time control presets for a room that is still waiting for its second player.
+// Triggered by the "⏱ Контроль времени" button ("tc_menu:<roomID>").
+func (h *Handler) handleTimeControlMenu(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if room.Player1ID != query.From.ID || room.Status != models.RoomStatusWaiting {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Контроль времени может выбрать только создатель комнаты до начала игры."))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for i, p := range game.TimeControlPresets {
+		callbackData, err := h.signedCallback(callbackdata.SetTimeControl, room.RoomID, []byte(p.Code)...)
+		if err != nil {
+			h.keyboardFailed(ctx, room, err)
+			return
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(p.Label, callbackData))
+
+		// Three presets per row keeps the keyboard compact.
+		if (i+1)%3 == 0 {
+			rows = append(rows, row)
+			row = []tgbotapi.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	noClock, err := h.signedCallback(callbackdata.SetTimeControl, room.RoomID, []byte(models.TimeControlNone)...)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("♾ Без контроля времени", noClock),
+	))
+
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID,
+		fmt.Sprintf("Текущий контроль: %s\nВыберите контроль времени:", game.TimeControlLabel(room)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.Bot.Send(msg)
+}
+
+// handleSetTimeControlCallback applies the chosen preset (callbackdata.SetTimeControl with the preset code,
+// e.g. "3+2"). An empty code removes the clock.
+func (h *Handler) handleSetTimeControlCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID, code string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if room.Player1ID != query.From.ID || room.Status != models.RoomStatusWaiting {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Контроль времени может выбрать только создатель комнаты до начала игры."))
+		return
+	}
+
+	if code == models.TimeControlNone {
+		game.ApplyTimeControl(room, game.TimeControlPreset{})
+	} else {
+		preset, ok := game.FindTimeControlPreset(code)
+		if !ok {
+			h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Неизвестный контроль времени."))
+			return
+		}
+		game.ApplyTimeControl(room, preset)
+	}
+
+	if err = h.RoomRepo.UpdateRoom(ctx, room); err != nil {
+		utils.Logger.Error("UpdateRoom (time control): "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось сохранить контроль времени."))
+		return
+	}
+
+	h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+		"Контроль времени установлен: "+game.TimeControlLabel(room)))
+}
+
+// finishOnTime ends the game of a player whose flag has fallen. The side to move loses on time,
+// unless the opponent has no mating material left — then the game is drawn.
+// It returns false if the room could not be finished (e.g. a move was made concurrently).
+func (h *Handler) finishOnTime(ctx context.Context, room *models.Room) bool {
+	loser, winner := "белых", chess.Black
+	if !room.IsWhiteTurn {
+		loser, winner = "чёрных", chess.White
+	}
+
+	text := fmt.Sprintf("Время %s вышло! ", loser)
+	result := game.ResultForWinner(winner)
+	fenOption, err := chess.FEN(room.BoardState)
+	if err == nil && !game.HasMatingMaterial(chess.NewGame(fenOption).Position().Board(), winner) {
+		text += "У соперника недостаточно материала для мата — ничья."
+		result = models.ResultDraw
+	} else if winner == chess.White {
+		text += "Победили белые."
+	} else {
+		text += "Победили чёрные."
+	}
+	return h.finishGame(ctx, room, result, models.TerminationTimeout, text)
+}
+
+// RunClockWatcher periodically checks all rooms with a running clock and finishes the games
+// whose side to move has run out of time, even if nobody presses any button. Blocks until ctx is done.
+func (h *Handler) RunClockWatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rooms, err := h.RoomRepo.GetRoomsWithRunningClock(ctx)
+			if err != nil {
+				utils.Logger.Error("GetRoomsWithRunningClock: "+err.Error(), zap.Error(err))
+				continue
+			}
+			now := time.Now()
+			for i := range rooms {
+				if game.IsFlagged(&rooms[i], now) {
+					// A game being finished is finished completely even if the watcher is stopped meanwhile.
+					h.finishOnTime(context.WithoutCancel(ctx), &rooms[i])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"lvlchess/internal/callbackdata"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateQueueSize is how many updates may wait for each worker before HandleUpdate blocks.
+const updateQueueSize = 64
+
+// updateJob is an update waiting for its worker, with the context it was received with.
+type updateJob struct {
+	ctx    context.Context
+	update tgbotapi.Update
+}
+
+/*
+dispatcher runs updates on a fixed set of workers. Updates with the same key (see updateKey) always go
+to the same worker, so the updates of one room or chat are handled one after another, in the order
+they came, while other rooms are handled in parallel by the other workers.
+*/
+type dispatcher struct {
+	queues  []chan updateJob
+	handle  func(ctx context.Context, update tgbotapi.Update)
+	running sync.WaitGroup
+}
+
+// newDispatcher starts workers goroutines (at least one) that pass the updates to handle.
+func newDispatcher(workers int, handle func(ctx context.Context, update tgbotapi.Update)) *dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &dispatcher{
+		queues: make([]chan updateJob, workers),
+		handle: handle,
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan updateJob, updateQueueSize)
+		d.running.Add(1)
+		go d.run(d.queues[i])
+	}
+	return d
+}
+
+// dispatch queues the update on the worker of key. It blocks while that worker's queue is full.
+func (d *dispatcher) dispatch(ctx context.Context, key string, update tgbotapi.Update) {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	d.queues[hash.Sum32()%uint32(len(d.queues))] <- updateJob{ctx: ctx, update: update}
+}
+
+// run handles the updates of one worker's queue, one at a time.
+func (d *dispatcher) run(queue <-chan updateJob) {
+	defer d.running.Done()
+	for job := range queue {
+		d.handle(job.ctx, job.update)
+	}
+}
+
+// close lets the workers finish the queued updates and waits for them. dispatch must not be called afterwards.
+func (d *dispatcher) close() {
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.running.Wait()
+}
+
+/*
+updateKey returns the key that orders an update against the others: the room for a signed in-game button
+(both players of a private game press buttons in different chats), otherwise the chat the update comes from,
+or the user for callbacks of inline messages. Updates of one room that end up under different keys
+(e.g. a typed move and a button in a private game) may still run concurrently; the version check
+of RoomsRepository.UpdateRoom keeps them from overwriting each other.
+*/
+func (h *Handler) updateKey(update tgbotapi.Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		query := update.CallbackQuery
+		// The signature is checked once, by handleSignedCallback: a forged room only picks another worker.
+		if roomID, ok := callbackdata.PeekRoom(query.Data); ok {
+			return "room:" + roomID
+		}
+		if query.Message != nil {
+			return fmt.Sprintf("chat:%d", query.Message.Chat.ID)
+		}
+		return fmt.Sprintf("user:%d", query.From.ID)
+	case update.Message != nil:
+		return fmt.Sprintf("chat:%d", update.Message.Chat.ID)
+	case update.MyChatMember != nil:
+		return fmt.Sprintf("chat:%d", update.MyChatMember.Chat.ID)
+	case update.ChatMember != nil:
+		return fmt.Sprintf("chat:%d", update.ChatMember.Chat.ID)
+	}
+	return fmt.Sprintf("update:%d", update.UpdateID)
+}
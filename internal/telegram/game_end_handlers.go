@@ -0,0 +1,251 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lvlchess/internal/callbackdata"
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// maxAbortPlies is the number of plies after which a game can no longer be aborted.
+const maxAbortPlies = 2
+
+// gameActionsRow builds the row of "end of game" buttons shown under the move keyboard:
+// resign, offer a draw and — during the first two plies only — abort.
+func (h *Handler) gameActionsRow(ctx context.Context, room *models.Room) ([]tgbotapi.InlineKeyboardButton, error) {
+	resign, err := h.signedCallback(callbackdata.Resign, room.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	offerDraw, err := h.signedCallback(callbackdata.OfferDraw, room.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏳 Сдаться", resign),
+		tgbotapi.NewInlineKeyboardButtonData("🤝 Предложить ничью", offerDraw),
+	)
+	if plies, err := h.MoveRepo.CountMoves(ctx, room.RoomID); err == nil && plies < maxAbortPlies {
+		abort, err := h.signedCallback(callbackdata.Abort, room.RoomID)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("✖ Отменить партию", abort))
+	}
+	return row, nil
+}
+
+// loadPlayingRoomForPlayer fetches the room and makes sure that it is still being played
+// and that userID is one of its two players. Otherwise, it tells the user why and returns nil.
+func (h *Handler) loadPlayingRoomForPlayer(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) *models.Room {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return nil
+	}
+	if room.Status != models.RoomStatusPlaying {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Партия в этой комнате не идёт."))
+		return nil
+	}
+	if _, ok := opponentOf(room, query.From.ID); !ok {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Вы не играете в этой партии."))
+		return nil
+	}
+	return room
+}
+
+// handleResignCallback is triggered by "resign:<roomID>": the player resigns and the opponent wins.
+func (h *Handler) handleResignCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room := h.loadPlayingRoomForPlayer(ctx, query, roomID)
+	if room == nil {
+		return
+	}
+
+	side, winner, winnerColor := "Белые", "чёрные", chess.Black
+	if room.BlackID != nil && *room.BlackID == query.From.ID {
+		side, winner, winnerColor = "Чёрные", "белые", chess.White
+	}
+	text := fmt.Sprintf("%s сдались. Победили %s.", side, winner)
+	if !h.finishGame(ctx, room, game.ResultForWinner(winnerColor), models.TerminationResignation, text) {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось завершить партию, попробуйте ещё раз."))
+	}
+}
+
+// handleOfferDrawCallback is triggered by "draw_offer:<roomID>". The offer is stored on the room
+// and the opponent receives Accept/Decline buttons. It stays valid until the opponent answers or moves.
+func (h *Handler) handleOfferDrawCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room := h.loadPlayingRoomForPlayer(ctx, query, roomID)
+	if room == nil {
+		return
+	}
+	if room.DrawOfferedBy != nil {
+		if *room.DrawOfferedBy == query.From.ID {
+			h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Вы уже предложили ничью, ждём ответа соперника."))
+		} else {
+			h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Соперник уже предложил ничью — ответьте на его предложение."))
+		}
+		return
+	}
+	if h.isBotRoom(room) {
+		h.answerBotDrawOffer(ctx, room)
+		return
+	}
+
+	offeredBy := query.From.ID
+	room.DrawOfferedBy = &offeredBy
+	if err := h.RoomRepo.UpdateRoom(ctx, room); err != nil {
+		utils.Logger.Error("UpdateRoom (draw offer): "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось сохранить предложение ничьей."))
+		return
+	}
+
+	opponentID, _ := opponentOf(room, offeredBy)
+	accept, err := h.signedCallback(callbackdata.AcceptDraw, room.RoomID)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	decline, err := h.signedCallback(callbackdata.DeclineDraw, room.RoomID)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Принять ничью", accept),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", decline),
+	))
+	text := fmt.Sprintf("%s предлагает ничью.", h.playerDisplayName(ctx, &offeredBy))
+
+	if room.ChatID != nil {
+		msg := tgbotapi.NewMessage(*room.ChatID, text)
+		msg.ReplyMarkup = kb
+		h.Bot.Send(msg)
+		return
+	}
+	opponent, err := h.UserRepo.GetUserByID(ctx, opponentID)
+	if err != nil || opponent.ChatID == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось отправить предложение сопернику."))
+		return
+	}
+	msg := tgbotapi.NewMessage(opponent.ChatID, text)
+	msg.ReplyMarkup = kb
+	h.Bot.Send(msg)
+	h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Предложение ничьей отправлено сопернику."))
+}
+
+// handleAcceptDrawCallback is triggered by "draw_accept:<roomID>". Only the opponent of the player
+// who offered the draw may accept it.
+func (h *Handler) handleAcceptDrawCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room := h.loadPlayingRoomForPlayer(ctx, query, roomID)
+	if room == nil {
+		return
+	}
+	if room.DrawOfferedBy == nil || *room.DrawOfferedBy == query.From.ID {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Нет предложения ничьей, которое вы могли бы принять."))
+		return
+	}
+
+	if !h.finishGame(ctx, room, models.ResultDraw, models.TerminationAgreement, "Ничья по соглашению сторон.") {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось завершить партию, попробуйте ещё раз."))
+	}
+}
+
+// handleDeclineDrawCallback is triggered by "draw_decline:<roomID>": the pending offer is removed.
+func (h *Handler) handleDeclineDrawCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room := h.loadPlayingRoomForPlayer(ctx, query, roomID)
+	if room == nil {
+		return
+	}
+	if room.DrawOfferedBy == nil || *room.DrawOfferedBy == query.From.ID {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Нет предложения ничьей, которое вы могли бы отклонить."))
+		return
+	}
+
+	room.DrawOfferedBy = nil
+	if err := h.RoomRepo.UpdateRoom(ctx, room); err != nil {
+		utils.Logger.Error("UpdateRoom (draw decline): "+err.Error(), zap.Error(err))
+		return
+	}
+	h.sendMessageToRoomOrUsers(ctx, room, "Предложение ничьей отклонено. Игра продолжается.", tgbotapi.ModeHTML)
+}
+
+// handleAbortCallback is triggered by "abort:<roomID>". A game may be aborted by either player
+// as long as fewer than two plies have been played; it then ends without a result.
+func (h *Handler) handleAbortCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room := h.loadPlayingRoomForPlayer(ctx, query, roomID)
+	if room == nil {
+		return
+	}
+	plies, err := h.MoveRepo.CountMoves(ctx, room.RoomID)
+	if err != nil {
+		utils.Logger.Error("CountMoves: "+err.Error(), zap.Error(err))
+		return
+	}
+	if plies >= maxAbortPlies {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Партию можно отменить только до второго хода. Вы можете сдаться или предложить ничью."))
+		return
+	}
+
+	text := fmt.Sprintf("Партия отменена игроком %s.", h.playerDisplayName(ctx, &query.From.ID))
+	if !h.finishGame(ctx, room, models.ResultNone, models.TerminationAborted, text) {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось отменить партию, попробуйте ещё раз."))
+	}
+}
+
+// finishGame stops the clocks, marks the room finished with the given result and termination method
+// (updating both players' statistics) and announces text followed by the final result to the players.
+// The post-game analysis is then started in the background.
+// It returns false if the room could not be finished (e.g. a move was made concurrently
+// or the game is already over).
+func (h *Handler) finishGame(ctx context.Context, room *models.Room, result, termination, text string) bool {
+	if room.HasClock() {
+		white, black := game.RemainingTime(room, time.Now())
+		room.WhiteTimeMs = max(white.Milliseconds(), 0)
+		room.BlackTimeMs = max(black.Milliseconds(), 0)
+	}
+	room.Result = result
+	room.Termination = termination
+
+	if err := h.RoomRepo.FinishRoom(ctx, room); errors.Is(err, repositories.ErrRoomVersionConflict) {
+		// A move or another update of the room got in first: the game is decided on the new state, if at all.
+		utils.Logger.Info("finish rejected: "+err.Error(), zap.String("roomID", room.RoomID))
+		return false
+	} else if err != nil {
+		utils.Logger.Error("FinishRoom: "+err.Error(), zap.Error(err))
+		return false
+	}
+
+	h.closeKeyboards(ctx, room)
+	text += "\n" + game.FinalSummary(room)
+	if summary := h.ratingSummary(ctx, room); summary != "" {
+		text += "\n" + summary
+	}
+	h.sendMessageToRoomOrUsers(ctx, room, text, tgbotapi.ModeHTML)
+	h.startAnalysis(ctx, room)
+	return true
+}
+
+// opponentOf returns the other player of the room. ok is false if userID does not play in it.
+func opponentOf(room *models.Room, userID int64) (opponentID int64, ok bool) {
+	if room.WhiteID == nil || room.BlackID == nil {
+		return 0, false
+	}
+	switch userID {
+	case *room.WhiteID:
+		return *room.BlackID, true
+	case *room.BlackID:
+		return *room.WhiteID, true
+	}
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// runInBackground runs fn in a goroutine that Shutdown waits for: bot replies, analyses, the clock watcher.
+// fn gets the handler's lifetime context instead of the context of the update that started it: the task
+// outlives the update, and Shutdown cancels it, so a long engine search does not hold up the shutdown.
+func (h *Handler) runInBackground(fn func(ctx context.Context)) {
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		fn(h.lifetime)
+	}()
+}
+
+// StartClockWatcher runs RunClockWatcher in the background until ctx is cancelled.
+func (h *Handler) StartClockWatcher(ctx context.Context, interval time.Duration) {
+	h.runInBackground(func(context.Context) { h.RunClockWatcher(ctx, interval) })
+}
+
+/*
+Shutdown stops the bot once no more updates come in (HandleUpdate must not be called anymore):
+the workers process the updates already queued, then the background tasks are cancelled and waited for,
+and finally the engine is closed. If ctx ends first, Shutdown cancels the background tasks and returns
+the context error without waiting for them: they may still use the engine and the database, so neither
+must be closed then (the engine processes exit with the bot, when their input is closed).
+*/
+func (h *Handler) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		h.updates.close()
+		// Background tasks are started by updates, so they are only stopped after the workers are done.
+		h.stopBackground()
+		h.background.Wait()
+		done <- h.Engine.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("Shutdown: close engine: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		h.stopBackground()
+		return fmt.Errorf("Shutdown: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// Texts of the move keyboard message while it has no buttons.
+const (
+	keyboardWaitingText  = "⏳ Ход соперника."
+	keyboardFinishedText = "Партия окончена."
+)
+
+/*
+showLiveMessage keeps one live message of the given kind (board or keyboard) per room and chat.
+If the chat already has one of the same type (picture or text), it is edited with edit(messageID);
+otherwise — or if editing fails, e.g. because the user deleted the message — send is sent as a new
+message, which becomes the live one. A previous live message of the other type (the user switched
+between the picture and the ASCII board) is deleted. It returns false if nothing could be shown.
+*/
+func (h *Handler) showLiveMessage(
+	ctx context.Context,
+	roomID string,
+	chatID int64,
+	kind string,
+	isPhoto bool,
+	send tgbotapi.Chattable,
+	edit func(messageID int) tgbotapi.Chattable,
+) bool {
+	prev, err := h.MessageRepo.GetRoomMessage(ctx, roomID, chatID, kind)
+	if err != nil {
+		utils.Logger.Warn("GetRoomMessage: "+err.Error(), zap.Error(err))
+	}
+	if prev != nil && prev.IsPhoto == isPhoto {
+		_, err = h.Bot.Send(edit(prev.MessageID))
+		if err == nil || isNotModified(err) {
+			return true
+		}
+		utils.Logger.Warn("edit live message: "+err.Error(), zap.String("kind", kind), zap.Error(err))
+	}
+
+	sent, err := h.Bot.Send(send)
+	if err != nil {
+		utils.Logger.Error("send live message: "+err.Error(), zap.String("kind", kind), zap.Error(err))
+		return false
+	}
+	if prev != nil && prev.IsPhoto != isPhoto {
+		h.Bot.Request(tgbotapi.NewDeleteMessage(chatID, prev.MessageID))
+	}
+	err = h.MessageRepo.SaveRoomMessage(ctx, &models.RoomMessage{
+		RoomID:    roomID,
+		ChatID:    chatID,
+		Kind:      kind,
+		MessageID: sent.MessageID,
+		IsPhoto:   isPhoto,
+	})
+	if err != nil {
+		utils.Logger.Error("SaveRoomMessage: "+err.Error(), zap.Error(err))
+	}
+	return true
+}
+
+// showMoveKeyboard shows the move keyboard (piece, move or promotion choice) of the room in the live keyboard
+// message: in the group chat, or in the private chat of the side to move. In a private game the other player's
+// keyboard message loses its buttons until it is their turn again.
+func (h *Handler) showMoveKeyboard(ctx context.Context, room *models.Room, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	chatID, parseMode := room.Player1ID, tgbotapi.ModeMarkdownV2
+	switch {
+	case room.ChatID != nil:
+		chatID, parseMode = *room.ChatID, ""
+	case room.Player2ID != nil && room.WhiteID != nil && room.BlackID != nil:
+		// If there's no group chat, the keyboard goes to whoever's turn it is.
+		chatID = h.sideToMoveID(room)
+		if waitingID, ok := opponentOf(room, chatID); ok && !h.isBot(waitingID) {
+			h.clearKeyboard(ctx, room.RoomID, waitingID, keyboardWaitingText)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = parseMode
+	msg.ReplyMarkup = keyboard
+	edit := func(messageID int) tgbotapi.Chattable {
+		e := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+		e.ParseMode = parseMode
+		return e
+	}
+	h.showLiveMessage(ctx, room.RoomID, chatID, models.RoomMessageKeyboard, false, msg, edit)
+}
+
+// clearKeyboard replaces the live keyboard message of the room in chatID with text without buttons.
+func (h *Handler) clearKeyboard(ctx context.Context, roomID string, chatID int64, text string) {
+	prev, err := h.MessageRepo.GetRoomMessage(ctx, roomID, chatID, models.RoomMessageKeyboard)
+	if err != nil || prev == nil {
+		return
+	}
+	if _, err = h.Bot.Send(tgbotapi.NewEditMessageText(chatID, prev.MessageID, text)); err != nil && !isNotModified(err) {
+		utils.Logger.Warn("clear keyboard: "+err.Error(), zap.Error(err))
+	}
+}
+
+// closeKeyboards removes the buttons from every live keyboard message of a finished room.
+func (h *Handler) closeKeyboards(ctx context.Context, room *models.Room) {
+	keyboards, err := h.MessageRepo.GetRoomMessages(ctx, room.RoomID, models.RoomMessageKeyboard)
+	if err != nil {
+		utils.Logger.Warn("GetRoomMessages: "+err.Error(), zap.Error(err))
+		return
+	}
+	for _, kb := range keyboards {
+		h.clearKeyboard(ctx, room.RoomID, kb.ChatID, keyboardFinishedText)
+	}
+}
+
+// removeButtons strips the inline keyboard from a message, e.g. a stale keyboard of a game that is over.
+func (h *Handler) removeButtons(msg *tgbotapi.Message) {
+	if msg == nil {
+		return
+	}
+	empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	if _, err := h.Bot.Request(tgbotapi.NewEditMessageReplyMarkup(msg.Chat.ID, msg.MessageID, empty)); err != nil && !isNotModified(err) {
+		utils.Logger.Warn("remove buttons: "+err.Error(), zap.Error(err))
+	}
+}
+
+// forgetRoomMessages drops the live messages of the room in chatID, so that the board and the keyboard
+// are sent anew at the bottom of the chat, e.g. when a player comes back to the game.
+func (h *Handler) forgetRoomMessages(ctx context.Context, roomID string, chatID int64) {
+	if err := h.MessageRepo.DeleteRoomMessages(ctx, roomID, chatID); err != nil {
+		utils.Logger.Warn("DeleteRoomMessages: "+err.Error(), zap.Error(err))
+	}
+}
+
+// isNotModified reports whether Telegram rejected an edit because the message already looks like that.
+func isNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"lvlchess/config"
+	"lvlchess/internal/callbackdata"
 	"lvlchess/internal/db"
 	"lvlchess/internal/db/models"
 	"lvlchess/internal/db/repositories"
+	"lvlchess/internal/engine"
 	"lvlchess/internal/game"
 	"lvlchess/internal/utils"
 
@@ -18,22 +22,39 @@ import (
 
 // CallbackAction constants to help us parse or handle user interactions in inline keyboards etc.
 const (
-	CommandDelimiter   = ":"
-	ActionMove         = "move"
-	ActionChooseFigure = "choose_figure"
-	CreateRoom         = "create_room"
-	PlayWithBot        = "play_with_bot"
-	SetupRoom          = "setup_room"
-	RetryRename        = "retry_rename"
-	SetupRoomWhite     = "setup_room_white"
-	ContinueSetup      = "continue_setup"
-	ManageRoom         = "manage_room"
-	RoomID             = "roomID"
-	JoinThisRoom       = "join_this_room"
-	CreateChat         = "create_chat_"
-	GameList           = "game_list"
-	RoomEntrance       = "room_entrance"
-	Delete             = "delete_"
+	CommandDelimiter    = ":"
+	ActionMove          = "move"
+	ActionChooseFigure  = "choose_figure"
+	ActionChoosePromo   = "choose_promo"
+	ActionBackToFigures = "back_to_figures"
+	CreateRoom          = "create_room"
+	PlayWithBot         = "play_with_bot"
+	SetupRoom           = "setup_room"
+	RetryRename         = "retry_rename"
+	SetupRoomWhite      = "setup_room_white"
+	ContinueSetup       = "continue_setup"
+	ManageRoom          = "manage_room"
+	RoomID              = "roomID"
+	JoinThisRoom        = "join_this_room"
+	CreateChat          = "create_chat_"
+	GameList            = "game_list"
+	RoomEntrance        = "room_entrance"
+	Delete              = "delete_"
+	ExportPGN           = "export_pgn"
+	CreateFromPosition  = "create_from_position"
+	TimeControlMenu     = "tc_menu"
+	SetTimeControl      = "tc"
+	ActionResign        = "resign"
+	ActionOfferDraw     = "draw_offer"
+	ActionAcceptDraw    = "draw_accept"
+	ActionDeclineDraw   = "draw_decline"
+	ActionAbort         = "abort"
+	ToggleRated         = "rated_toggle"
+	Leaderboard         = "leaderboard"
+	ChooseBotLevel      = "bot_level"
+	ShowAnalysis        = "analysis"
+	ToggleBoardStyle    = "board_style"
+	ShowReplay          = "replay"
 )
 
 // TelegramHandler is a global-like reference, but ideally you'd keep it in your main
@@ -42,30 +63,57 @@ var TelegramHandler *Handler
 
 // Handler is the core structure that has references to the bot, plus repos. This is used
 // by all callback and command handlers. You register it in NewHandler().
+// The storage is reached through the repositories.*Store interfaces, so tests can run the handlers
+// on the in-memory store of package memory instead of PostgreSQL.
 type Handler struct {
 	Bot                   *tgbotapi.BotAPI
-	UserRepo              *repositories.UsersRepository
-	RoomRepo              *repositories.RoomsRepository
-	TournamentRepo        *repositories.TournamentRepository
-	TournamentSettingRepo *repositories.TournamentSettingsRepository
+	UserRepo              repositories.UserStore
+	RoomRepo              repositories.RoomStore
+	MoveRepo              repositories.MoveStore
+	RatingRepo            repositories.RatingStore
+	MessageRepo           repositories.MessageStore
+	Engine                engine.Engine
+	Callbacks             *callbackdata.Codec
+	TournamentRepo        repositories.TournamentStore
+	TournamentSettingRepo repositories.TournamentSettingsStore
+
+	updates        *dispatcher        // per-room/per-chat workers of HandleUpdate
+	background     sync.WaitGroup     // goroutines started with runInBackground
+	lifetime       context.Context    // context of the background tasks, cancelled by Shutdown
+	stopBackground context.CancelFunc // cancels lifetime
 }
 
 // NewHandler initializes the global TelegramHandler with references
 // to the repositories (taken from db.GetRoomsRepo() etc.).
 func NewHandler(bot *tgbotapi.BotAPI) {
 	TelegramHandler = &Handler{
-		Bot:      bot,
-		RoomRepo: db.GetRoomsRepo(),
-		UserRepo: db.GetUsersRepo(),
+		Bot:         bot,
+		RoomRepo:    db.GetRoomsRepo(),
+		UserRepo:    db.GetUsersRepo(),
+		MoveRepo:    db.GetMovesRepo(),
+		RatingRepo:  db.GetRatingsRepo(),
+		MessageRepo: db.GetMessagesRepo(),
+		Engine:      newEngine(),
+		Callbacks:   newCallbackCodec(),
 		// If you want to handle tournaments here:
 		TournamentRepo:        db.GetTournamentsRepo(),
 		TournamentSettingRepo: db.GetTournamentSettingsRepo(),
 	}
+	TelegramHandler.updates = newDispatcher(config.Cfg.UpdateWorkers, TelegramHandler.processUpdate)
+	TelegramHandler.lifetime, TelegramHandler.stopBackground = context.WithCancel(context.Background())
 }
 
 // HandleUpdate is the primary entrypoint for every incoming message/update from Telegram.
-// We route them based on whether it's a message, callbackQuery, or chatMember event, etc.
+// It queues the update on the worker of its room or chat (see updateKey) and returns; updates of the same
+// room or chat are processed in order, different rooms in parallel. It blocks while that worker is busy
+// with a full queue.
 func (h *Handler) HandleUpdate(ctx context.Context, update tgbotapi.Update) {
+	h.updates.dispatch(ctx, h.updateKey(update), update)
+}
+
+// processUpdate handles a single update on a dispatcher worker.
+// We route them based on whether it's a message, callbackQuery, or chatMember event, etc.
+func (h *Handler) processUpdate(ctx context.Context, update tgbotapi.Update) {
 	switch {
 	case update.Message != nil:
 		// An incoming message or command in text form.
@@ -89,7 +137,7 @@ func (h *Handler) HandleUpdate(ctx context.Context, update tgbotapi.Update) {
 
 // handleMessage processes text-based messages in private or group chats.
 // If it's a command, we dispatch to the relevant command (like /start).
-// If it’s a non-command text in a group, we might ignore or respond with “Use the inline buttons.”
+// A non-command text in a chat with a game of the sender in progress is read as a typed move.
 func (h *Handler) handleMessage(ctx context.Context, update tgbotapi.Update) {
 	msg := update.Message
 
@@ -101,16 +149,23 @@ func (h *Handler) handleMessage(ctx context.Context, update tgbotapi.Update) {
 	// If it's a group or supergroup:
 	if msg.Chat.IsGroup() || msg.Chat.IsSuperGroup() {
 		if msg.IsCommand() {
-			if msg.Command() == "setroom" {
+			switch msg.Command() {
+			case "setroom":
 				h.handleSetRoomCommand(ctx, update)
-			} else {
+			case "pgn":
+				h.handlePGNCommand(ctx, update)
+			case "replay":
+				h.handleReplayCommand(ctx, update)
+			case "top":
+				h.handleTopCommand(ctx, update)
+			default:
 				// We can ignore all other commands in group context or warn user.
 				reply := tgbotapi.NewMessage(msg.Chat.ID,
-					"Commands in group chat are restricted. Use /setroom <room_id> or inline buttons.")
+					"Commands in group chat are restricted. Use /setroom <room_id>, /pgn, /replay, /top or inline buttons.")
 				h.Bot.Send(reply)
 			}
-		} else {
-			// Non-command text in a group → optional minimal response.
+		} else if !h.handleTextMove(ctx, msg) {
+			// Non-command text in a group without a game of the sender → optional minimal response.
 			reply := tgbotapi.NewMessage(msg.Chat.ID, "🌚")
 			h.Bot.Send(reply)
 		}
@@ -122,12 +177,23 @@ func (h *Handler) handleMessage(ctx context.Context, update tgbotapi.Update) {
 		switch msg.Command() {
 		case "start":
 			h.handleStartCommand(ctx, update)
+		case "pgn":
+			h.handlePGNCommand(ctx, update)
+		case "replay":
+			h.handleReplayCommand(ctx, update)
+		case "fen":
+			h.handleFENCommand(ctx, update)
+		case "top":
+			h.handleTopCommand(ctx, update)
 		default:
 			// If we get other commands we haven't recognized, just respond briefly.
 			h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Unrecognized command. Use /start or inline buttons."))
 		}
-	} else {
-		// A plain text message in private chat. Some implementations do a fallback or "Use /start".
+	} else if msg.Document != nil {
+		// A file in private chat: a PGN upload used to start a room from its final position.
+		h.handlePGNUpload(ctx, update)
+	} else if !h.handleTextMove(ctx, msg) {
+		// A plain text message in private chat without a game in progress. Some implementations do a fallback or "Use /start".
 		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "🌚"))
 	}
 }
@@ -174,9 +240,21 @@ func (h *Handler) handleCallback(ctx context.Context, query *tgbotapi.CallbackQu
 	case data == PlayWithBot:
 		h.handlePlayWithBotCommand(ctx, query)
 
+	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ChooseBotLevel, CommandDelimiter)):
+		h.handleBotLevelCallback(ctx, query, data[len(ChooseBotLevel+CommandDelimiter):])
+
+	case data == CreateFromPosition:
+		h.handleCreateFromPositionHint(ctx, query)
+
+	case data == Leaderboard || strings.HasPrefix(data, fmt.Sprintf("%s%s", Leaderboard, CommandDelimiter)):
+		h.handleLeaderboardCallback(ctx, query)
+
 	case data == GameList:
 		h.handleGameListCommand(ctx, query)
 
+	case data == ToggleBoardStyle:
+		h.handleToggleBoardStyle(ctx, query)
+
 	case data == SetupRoom:
 		h.handleAskWhoIsWhite(ctx, query)
 
@@ -184,11 +262,12 @@ func (h *Handler) handleCallback(ctx context.Context, query *tgbotapi.CallbackQu
 		choice := strings.TrimPrefix(data, fmt.Sprintf("%s%s", SetupRoomWhite, CommandDelimiter))
 		h.handleSetupRoomWhiteChoice(ctx, query, choice)
 
-	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ActionChooseFigure, CommandDelimiter)):
-		h.handleChooseFigureCallback(ctx, query)
+	case callbackdata.IsEncoded(data):
+		// Signed room buttons: moves, resignation, draw offers, abort, time control (see callback_handlers.go).
+		h.handleSignedCallback(ctx, query)
 
-	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ActionMove, CommandDelimiter)):
-		h.handleMoveCallback(ctx, query)
+	case isLegacyGameCallback(data):
+		h.handleLegacyGameCallback(ctx, query)
 
 	case data == ManageRoom:
 		h.handleManageRoomMenu(ctx, query)
@@ -219,6 +298,25 @@ func (h *Handler) handleCallback(ctx context.Context, query *tgbotapi.CallbackQu
 			"Room "+roomID+" will be deleted (placeholder).")
 		h.Bot.Send(msg)
 
+	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ExportPGN, CommandDelimiter)):
+		roomID := data[len(fmt.Sprintf("%s%s", ExportPGN, CommandDelimiter)):]
+		h.handleExportPGNCallback(ctx, query, roomID)
+
+	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ShowAnalysis, CommandDelimiter)):
+		roomID := data[len(fmt.Sprintf("%s%s", ShowAnalysis, CommandDelimiter)):]
+		h.handleAnalysisCallback(ctx, query, roomID)
+
+	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ShowReplay, CommandDelimiter)):
+		roomID := data[len(fmt.Sprintf("%s%s", ShowReplay, CommandDelimiter)):]
+		h.handleReplayCallback(ctx, query, roomID)
+
+	case strings.HasPrefix(data, fmt.Sprintf("%s%s", TimeControlMenu, CommandDelimiter)):
+		roomID := data[len(fmt.Sprintf("%s%s", TimeControlMenu, CommandDelimiter)):]
+		h.handleTimeControlMenu(ctx, query, roomID)
+
+	case strings.HasPrefix(data, fmt.Sprintf("%s%s", ToggleRated, CommandDelimiter)):
+		h.handleToggleRatedCallback(ctx, query, data[len(ToggleRated+CommandDelimiter):])
+
 	case strings.HasPrefix(data, fmt.Sprintf("%s%s", RoomEntrance, CommandDelimiter)):
 		roomID := data[len(fmt.Sprintf("%s%s", RoomEntrance, CommandDelimiter)):]
 		h.handleRoomEntrance(ctx, query, roomID)
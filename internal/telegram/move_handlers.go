@@ -2,10 +2,13 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
+	"lvlchess/internal/callbackdata"
 	"lvlchess/internal/db/models"
+	"lvlchess/internal/db/repositories"
 	"lvlchess/internal/game"
 	"lvlchess/internal/utils"
 
@@ -80,9 +83,18 @@ func (h *Handler) prepareMoveButtons(ctx context.Context, room *models.Room, use
 	var row []tgbotapi.InlineKeyboardButton
 
 	keyboardSort(figureSquares)
+	ply, err := h.plyPayload(ctx, room.RoomID)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
 	for i, sq := range figureSquares {
 		sqStr := sq.String()
-		callbackData := fmt.Sprintf("%s:%s&%s:%s", ActionChooseFigure, sqStr, RoomID, room.RoomID)
+		callbackData, err := h.signedCallback(callbackdata.ChooseFigure, room.RoomID, append([]byte{byte(sq)}, ply...)...)
+		if err != nil {
+			h.keyboardFailed(ctx, room, err)
+			return
+		}
 		buttonText := fmt.Sprintf("%s %s", figureIcon[sqStr], sqStr)
 		btn := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
 		row = append(row, btn)
@@ -96,25 +108,21 @@ func (h *Handler) prepareMoveButtons(ctx context.Context, room *models.Room, use
 	if len(row) > 0 {
 		rows = append(rows, row)
 	}
+	actions, err := h.gameActionsRow(ctx, room)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	rows = append(rows, actions)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
-	SendInlineKeyboard(h.Bot, room, "Выберите фигуру для хода:", keyboard)
+	h.showMoveKeyboard(ctx, room, "Выберите фигуру для хода:", keyboard)
 }
 
-// handleChooseFigureCallback is invoked when user picks a from-square, e.g. "choose_figure:b8" in the callback data.
-// We'll parse out which squares can be moved to from that square, then build a new inline keyboard
+// handleChooseFigureCallback is invoked when user picks a from-square (callbackdata.ChooseFigure).
+// We'll find which squares can be moved to from that square, then build a new inline keyboard
 // listing all possible moves.
-func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
-	action, figureSquare, roomID, err := parseCallbackData(query.Data)
-	utils.Logger.Error("handleChooseFigureCallback debugging",
-		zap.Any("action", action),
-		zap.Any("param", figureSquare),
-		zap.Any(RoomID, roomID),
-		zap.Any("err", err),
-	)
-	if err != nil || (action != ActionMove && action != ActionChooseFigure) {
-		utils.Logger.Error("handleChooseFigureCallback parse error", zap.Error(err))
-		return
-	}
+func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string, fromSq chess.Square) {
+	figureSquare := fromSq.String()
 
 	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
 	if err != nil {
@@ -129,6 +137,18 @@ func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotap
 		h.sendMessageToRoomOrUsers(ctx, room, "Нет состояния доски!", tgbotapi.ModeHTML)
 		return
 	}
+	if room.Status != models.RoomStatusPlaying {
+		h.removeButtons(query.Message)
+		return
+	}
+	// The keyboard is shared in a group chat: only the side to move may switch it to the moves of a piece.
+	if h.sideToMoveID(room) != query.From.ID {
+		callback := tgbotapi.NewCallback(query.ID, "Сейчас не ваш ход!")
+		if _, err = h.Bot.Request(callback); err != nil {
+			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+		}
+		return
+	}
 
 	// Parse the board to see valid moves from figureSquare
 	fenOption, err := chess.FEN(room.BoardState)
@@ -143,19 +163,9 @@ func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotap
 	}
 
 	validMoves := chGame.ValidMoves()
-	fromSq, errParseFrom := game.StrToSquare(figureSquare)
-	if errParseFrom != nil {
-		callback := tgbotapi.NewCallback(query.ID, "Некорректный квадрат фигуры.")
-		utils.Logger.Error("Bad square parse: "+errParseFrom.Error(), zap.Error(errParseFrom))
-		if _, err = h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-		}
-		return
-	}
-
 	var movesForThisSquare []chess.Move
 	for _, mv := range validMoves {
-		if mv.S1().String() == figureSquare {
+		if mv.S1() == fromSq {
 			movesForThisSquare = append(movesForThisSquare, *mv)
 		}
 	}
@@ -165,23 +175,44 @@ func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotap
 		return
 	}
 
-	// Build inline buttons for each possible "move" (like "move:b8-c6").
+	// Build inline buttons for each possible move (like b8-c6).
 	board := chGame.Position().Board()
 	piece := board.Piece(fromSq)
 	var rows [][]tgbotapi.InlineKeyboardButton
 	row := []tgbotapi.InlineKeyboardButton{}
 
-	for i, mv := range movesForThisSquare {
-		callbackData := fmt.Sprintf("move:%s-%s&%s:%s", mv.S1().String(), mv.S2().String(), RoomID, roomID)
+	promoTargets := make(map[chess.Square]bool)
+	ply, err := h.plyPayload(ctx, roomID)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	i := 0
+	for _, mv := range movesForThisSquare {
+		action, payload := callbackdata.Move, []byte{byte(mv.S1()), byte(mv.S2()), byte(chess.NoPieceType)}
 		btnText := fmt.Sprintf("%s ", buildMoveButtonText(piece, mv))
+		if mv.Promo() != chess.NoPieceType {
+			// The four promotions to a square share one button: the piece is chosen in the next step.
+			if promoTargets[mv.S2()] {
+				continue
+			}
+			promoTargets[mv.S2()] = true
+			action, payload = callbackdata.ChoosePromo, []byte{byte(mv.S1()), byte(mv.S2())}
+			btnText = fmt.Sprintf("🪄%s💨✨?✨\n %s->%s", piece.String(), mv.S1().String(), mv.S2().String())
+		}
 		// пример: "♔↷🛡♖\n e1->g1" (short castling),
 		// или "🪄♙💨✨♕✨\n d7->d8Q" (pawn transformation),
 		// или "♞⤵\n f5->h6" (normal move).
+		callbackData, err := h.signedCallback(action, roomID, append(payload, ply...)...)
+		if err != nil {
+			h.keyboardFailed(ctx, room, err)
+			return
+		}
 		btn := tgbotapi.NewInlineKeyboardButtonData(btnText, callbackData)
 		row = append(row, btn)
 
 		// For neatness, let's do up to 4 in a row:
-		if (i+1)%4 == 0 {
+		if i++; i%4 == 0 {
 			rows = append(rows, row)
 			row = []tgbotapi.InlineKeyboardButton{}
 		}
@@ -190,8 +221,15 @@ func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotap
 		rows = append(rows, row)
 	}
 
+	back, err := h.backToFiguresRow(roomID, ply)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	rows = append(rows, back)
+
 	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
-	SendInlineKeyboard(h.Bot, room, fmt.Sprintf("Ходы для фигуры %s:", figureSquare), kb)
+	h.showMoveKeyboard(ctx, room, fmt.Sprintf("Ходы для фигуры %s:", figureSquare), kb)
 
 	// Clear the callback spinner
 	callback := tgbotapi.NewCallback(query.ID, "Пожалуйста, выберите ход.")
@@ -200,117 +238,196 @@ func (h *Handler) handleChooseFigureCallback(ctx context.Context, query *tgbotap
 	}
 }
 
-// parseCallbackData splits something like "move:b8-c6&roomID:xxxx" into (action="move", param="b8-c6", roomID="xxxx").
-func parseCallbackData(data string) (action, param, roomID string, err error) {
-	mainParts := strings.Split(data, "&")
-	if len(mainParts) != 2 {
-		return "", "", "", fmt.Errorf("incorrect callback data format (missing &)")
+// handleChoosePromotionCallback is invoked when a pawn move to the last rank is picked (callbackdata.ChoosePromo).
+// It offers the pieces the pawn can be promoted to; each button is a callbackdata.Move with the piece.
+func (h *Handler) handleChoosePromotionCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string, fromSq, toSq chess.Square) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		callback := tgbotapi.NewCallback(query.ID, "Комната не найдена.")
+		if _, err = h.Bot.Request(callback); err != nil {
+			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+		}
+		return
+	}
+	if !h.mayUseMoveKeyboard(query, room) {
+		return
 	}
-	left, right := mainParts[0], mainParts[1]
+	h.sendPromotionPicker(ctx, room, fromSq, toSq)
 
-	leftParts := strings.Split(left, CommandDelimiter)
-	if len(leftParts) != 2 {
-		return "", "", "", fmt.Errorf("incorrect left part")
+	callback := tgbotapi.NewCallback(query.ID, "Выберите фигуру для превращения.")
+	if _, err = h.Bot.Request(callback); err != nil {
+		utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
 	}
-	action, param = leftParts[0], leftParts[1]
+}
 
-	rightParts := strings.Split(right, CommandDelimiter)
-	if len(rightParts) != 2 {
-		return "", "", "", fmt.Errorf("incorrect right part")
+// sendPromotionPicker sends the keyboard with the pieces a pawn moving from fromSq to toSq
+// can be promoted to: queen, rook, bishop and knight of the side to move.
+func (h *Handler) sendPromotionPicker(ctx context.Context, room *models.Room, fromSq, toSq chess.Square) {
+	color := chess.White
+	if !room.IsWhiteTurn {
+		color = chess.Black
 	}
-	if rightParts[0] != RoomID {
-		return "", "", "", fmt.Errorf("expected 'roomID:' got: %s", rightParts[0])
+	pieces := []struct {
+		pieceType chess.PieceType
+		name      string
+	}{
+		{chess.Queen, "Ферзь"},
+		{chess.Rook, "Ладья"},
+		{chess.Bishop, "Слон"},
+		{chess.Knight, "Конь"},
 	}
-	roomID = rightParts[1]
-	return action, param, roomID, nil
-}
 
-// SendInlineKeyboard decides where to post a message with inline keyboard
-// (group chat if room.ChatID is set, otherwise each player's private chat).
-func SendInlineKeyboard(bot *tgbotapi.BotAPI, room *models.Room, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
-	utils.Logger.Error(
-		"SendInlineKeyboard debug info",
-		zap.Any("room.ChatID", room.ChatID),
-		zap.Any("room.Player2ID", room.Player2ID),
-		zap.Any("(room.Player2ID != nil):", (room.Player2ID != nil)),
-		zap.Any("room.WhiteID", *room.WhiteID),
-		zap.Any("room.BlackID", *room.BlackID),
-		zap.Any("room.IsWhiteTurn", room.IsWhiteTurn),
-	)
-
-	if room.ChatID != nil {
-		msg := tgbotapi.NewMessage(*room.ChatID, text)
-		msg.ReplyMarkup = keyboard
-		bot.Send(msg)
-	} else if room.Player2ID != nil {
-		// If there's no group chat, we might only have private players.
-		// We guess who to show the move interface based on whose turn it is.
-		if room.IsWhiteTurn && room.WhiteID != nil {
-			msgWhite := tgbotapi.NewMessage(*room.WhiteID, text)
-			msgWhite.ReplyMarkup = keyboard
-			msgWhite.ParseMode = tgbotapi.ModeMarkdownV2
-			bot.Send(msgWhite)
-		} else if !room.IsWhiteTurn && room.BlackID != nil {
-			msgBlack := tgbotapi.NewMessage(*room.BlackID, text)
-			msgBlack.ReplyMarkup = keyboard
-			msgBlack.ParseMode = tgbotapi.ModeMarkdownV2
-			bot.Send(msgBlack)
+	ply, err := h.plyPayload(ctx, room.RoomID)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	for _, p := range pieces {
+		callbackData, err := h.signedCallback(callbackdata.Move, room.RoomID, append([]byte{byte(fromSq), byte(toSq), byte(p.pieceType)}, ply...)...)
+		if err != nil {
+			h.keyboardFailed(ctx, room, err)
+			return
 		}
-	} else {
-		// Possibly no second player => just send to Player1 as fallback
-		msgP1 := tgbotapi.NewMessage(room.Player1ID, text)
-		msgP1.ReplyMarkup = keyboard
-		msgP1.ParseMode = tgbotapi.ModeMarkdownV2
-		bot.Send(msgP1)
+		btnText := fmt.Sprintf("%s %s", chess.NewPiece(p.pieceType, color).String(), p.name)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(btnText, callbackData))
+	}
+	back, err := h.backToFiguresRow(room.RoomID, ply)
+	if err != nil {
+		h.keyboardFailed(ctx, room, err)
+		return
 	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(row, back)
+	h.showMoveKeyboard(ctx, room, fmt.Sprintf("Превращение пешки на %s, выберите фигуру:", toSq), kb)
 }
 
-// handleMoveCallback processes an actual move command like "move:b8-c6&roomID:123".
-// We parse the squares, check if it's the user's turn, attempt the move, update board state,
-// then broadcast the updated position or finishing message if game ended.
-func (h *Handler) handleMoveCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
-	action, moveStr, roomID, err := parseCallbackData(query.Data)
-	if err != nil || action != ActionMove {
-		return
+// mayUseMoveKeyboard reports whether the game goes on and it is the turn of the user who pressed a button
+// of the move keyboard. Otherwise the press is answered with an alert and the keyboard is left alone.
+func (h *Handler) mayUseMoveKeyboard(query *tgbotapi.CallbackQuery, room *models.Room) bool {
+	if room.Status != models.RoomStatusPlaying {
+		h.answerAlert(query, "Партия уже окончена.")
+		return false
+	}
+	if h.sideToMoveID(room) != query.From.ID {
+		h.answerAlert(query, "Сейчас не ваш ход!")
+		return false
 	}
+	return true
+}
 
-	figureParts := strings.Split(moveStr, "-")
-	if len(figureParts) != 2 {
-		callback := tgbotapi.NewCallback(query.ID, "Некорректный формат хода.")
-		if _, err := h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-		}
+// backToFiguresRow returns the keyboard row leading from the move or promotion choice back to the piece choice.
+// ply is the position the keyboard was made for (see plyPayload).
+func (h *Handler) backToFiguresRow(roomID string, ply []byte) ([]tgbotapi.InlineKeyboardButton, error) {
+	data, err := h.signedCallback(callbackdata.BackToFigures, roomID, ply...)
+	if err != nil {
+		return nil, err
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅ Другая фигура", data)), nil
+}
+
+// handleBackToFiguresCallback shows the piece choice again (callbackdata.BackToFigures).
+func (h *Handler) handleBackToFiguresCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if room.Status != models.RoomStatusPlaying {
+		h.removeButtons(query.Message)
 		return
 	}
-	fromSquare, toSquare := figureParts[0], figureParts[1]
+	// Only the side to move may switch the shared keyboard back; prepareMoveButtons would tell the whole room otherwise.
+	if h.sideToMoveID(room) != query.From.ID {
+		h.answerAlert(query, "Сейчас не ваш ход!")
+		return
+	}
+	h.prepareMoveButtons(ctx, room, query.From.ID)
 
+	callback := tgbotapi.NewCallback(query.ID, "Выберите фигуру для хода.")
+	if _, err = h.Bot.Request(callback); err != nil {
+		utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+	}
+}
+
+// handleMoveCallback processes an actual move button (callbackdata.Move): the squares and, for a promotion,
+// the piece. We let playMove check the turn, make the move and announce it;
+// the outcome is shown in the callback answer.
+func (h *Handler) handleMoveCallback(
+	ctx context.Context,
+	query *tgbotapi.CallbackQuery,
+	roomID string,
+	fromSq, toSq chess.Square,
+	promo chess.PieceType,
+) {
 	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
 	if err != nil || room == nil {
 		callback := tgbotapi.NewCallback(query.ID, "Комната не найдена.")
-		utils.Logger.Error("Room not found: "+err.Error(), zap.Error(err))
+		utils.Logger.Error("Room not found", zap.String("roomID", roomID), zap.Error(err))
 		if _, err = h.Bot.Request(callback); err != nil {
 			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
 		}
 		return
 	}
+
+	// A pawn move to the last rank without the promotion piece: ask for the piece.
+	if promo == chess.NoPieceType && isPromotionMove(room.BoardState, fromSq, toSq) {
+		if !h.mayUseMoveKeyboard(query, room) {
+			return
+		}
+		h.sendPromotionPicker(ctx, room, fromSq, toSq)
+		callback := tgbotapi.NewCallback(query.ID, "Выберите фигуру для превращения.")
+		if _, err = h.Bot.Request(callback); err != nil {
+			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+		}
+		return
+	}
+
+	// Attempt to decode the move "b8c6" (or "e7e8q" with a promotion) as UCINotation.
+	uci := fromSq.String() + toSq.String() + promo.String()
+	decode := func(pos *chess.Position) (*chess.Move, error) {
+		mv, parseErr := chess.UCINotation{}.Decode(pos, uci)
+		if parseErr != nil {
+			utils.Logger.Error("Parse move error: "+parseErr.Error(), zap.Error(parseErr))
+			return nil, fmt.Errorf("Невозможно распарсить ход %s: %v", uci, parseErr)
+		}
+		return mv, nil
+	}
+	callbackText, _ := h.playMove(ctx, room, query.From.ID, decode)
+	if room.Status != models.RoomStatusPlaying && query.Message != nil {
+		// The game is over (possibly with this very move): a keyboard that is not the live one keeps no buttons.
+		h.removeButtons(query.Message)
+	}
+	callback := tgbotapi.NewCallback(query.ID, callbackText)
+	if _, err = h.Bot.Request(callback); err != nil {
+		utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+	}
+}
+
+// playMove makes a move of userID in the room, whichever way it was entered: decode reads the move
+// from the current position. We check that the game goes on and that it is the user's turn, attempt
+// the move, store it and broadcast the updated position or the finishing message if the game ended.
+// The returned text tells the player the outcome; played reports whether the move was made.
+func (h *Handler) playMove(
+	ctx context.Context,
+	room *models.Room,
+	userID int64,
+	decode func(pos *chess.Position) (*chess.Move, error),
+) (text string, played bool) {
 	if room.BoardState == "" {
 		h.sendMessageToRoomOrUsers(ctx, room, "Нет текущего состояния доски!", tgbotapi.ModeHTML)
-		return
+		return "", false
+	}
+	if room.Status != models.RoomStatusPlaying {
+		return "Партия уже окончена.", false
 	}
 
-	fenOption, err := chess.FEN(room.BoardState)
+	chGame, err := h.loadRoomGame(ctx, room)
 	if err != nil {
 		h.sendMessageToRoomOrUsers(ctx, room, "Не получилось проанализировать доску!", tgbotapi.ModeHTML)
-		return
-	}
-	chGame := chess.NewGame(fenOption)
-	if chGame == nil {
-		h.sendMessageToRoomOrUsers(ctx, room, "Ошибка восстановления FEN!", tgbotapi.ModeHTML)
-		return
+		return "", false
 	}
 
 	// Check if user is indeed the correct side to move.
-	userID := query.From.ID
 	sideToMove := chGame.Position().Turn()
 	var mustMoveUserID int64
 	if sideToMove == chess.White /* && room.WhiteID != nil*/ {
@@ -319,99 +436,158 @@ func (h *Handler) handleMoveCallback(ctx context.Context, query *tgbotapi.Callba
 		mustMoveUserID = *room.BlackID
 	}
 	if mustMoveUserID != userID {
-		callback := tgbotapi.NewCallback(query.ID, "Сейчас не ваш ход!")
-		if _, err = h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-		}
-		return
+		return "Сейчас не ваш ход!", false
 	}
 
-	// Attempt to decode the move "b8c6" as UCINotation or fallback.
-	mv, parseErr := chess.UCINotation{}.Decode(chGame.Position(), fromSquare+toSquare)
-	if parseErr != nil {
-		callback := tgbotapi.NewCallback(query.ID,
-			fmt.Sprintf("Невозможно распарсить ход %s-%s: %v", fromSquare, toSquare, parseErr))
-		utils.Logger.Error("Parse move error: "+parseErr.Error(), zap.Error(parseErr))
-		if _, err = h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-		}
-		return
+	// With a time control, the mover's flag may already have fallen: then the move is too late.
+	now := time.Now()
+	if game.IsFlagged(room, now) {
+		h.finishOnTime(ctx, room)
+		return "Время вышло!", false
 	}
 
+	mv, err := decode(chGame.Position())
+	if err != nil {
+		return err.Error(), false
+	}
+
+	// Keep the position before the move: SAN/UCI are encoded relative to it.
+	prePos := chGame.Position()
+
 	// Try performing the move in the notnil/chess library.
 	if errMove := chGame.Move(mv); errMove != nil {
 		// If move is illegal, send an error.
 		h.sendMessageToRoomOrUsers(ctx, room, "Невозможный ход!", tgbotapi.ModeHTML)
-		callback := tgbotapi.NewCallback(query.ID, "")
 		utils.Logger.Error("Illegal move: "+errMove.Error(), zap.Error(errMove))
-		if _, err = h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-		}
-		return
+		return "", false
+	}
+
+	if err = h.recordMove(ctx, room, chGame, prePos, mv, userID, now); errors.Is(err, repositories.ErrRoomVersionConflict) {
+		// Another update of the room (e.g. a second click) got in first: this move was made for an outdated position.
+		utils.Logger.Info("move rejected: "+err.Error(), zap.String("roomID", room.RoomID))
+		return "Позиция изменилась, ход не сделан. Попробуйте ещё раз.", false
+	} else if err != nil {
+		h.sendMessageToRoomOrUsers(ctx, room, "Ошибка при сохранении нового состояния доски!", tgbotapi.ModeHTML)
+		utils.Logger.Error("UpdateRoomWithMove error: "+err.Error(), zap.Error(err))
+		return "", false
 	}
+	h.announceMove(ctx, room)
 
-	// If successful, store the new FEN.
+	if room.Status == models.RoomStatusFinished {
+		return "Ход сделан! Игра окончена.", true
+	}
+	return "Ход успешен!", true
+}
+
+// recordMove stores a move that has just been played in chGame (prePos is the position before it):
+// the new FEN, the clocks, the turn and — if the move ended the game — the result, all in the same
+// transaction as the room_moves row. It is shared by human moves and the bot's replies.
+func (h *Handler) recordMove(
+	ctx context.Context,
+	room *models.Room,
+	chGame *chess.Game,
+	prePos *chess.Position,
+	mv *chess.Move,
+	moverID int64,
+	now time.Time,
+) error {
 	newFEN := chGame.FEN()
 	room.BoardState = newFEN
+	game.PressClock(room, now) // must run while IsWhiteTurn still points at the mover
 	room.IsWhiteTurn = !room.IsWhiteTurn
-	if err = h.RoomRepo.UpdateRoom(ctx, room); err != nil {
-		h.sendMessageToRoomOrUsers(ctx, room, "Ошибка при сохранении нового состояния доски!", tgbotapi.ModeHTML)
-		callback := tgbotapi.NewCallback(query.ID, "")
-		utils.Logger.Error("UpdateRoom error: "+err.Error(), zap.Error(err))
-		if _, err = h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-		}
-		return
-	}
+	if room.DrawOfferedBy != nil && *room.DrawOfferedBy != moverID {
+		room.DrawOfferedBy = nil // the opponent moved instead of answering: the draw offer is declined
+	}
+	if result, termination := game.ConcludeGame(chGame); result != models.ResultNone {
+		// The game is over: stop the clocks so the flag-fall watcher leaves this room alone.
+		// The players' statistics are updated in the same transaction as the move.
+		room.Status = models.RoomStatusFinished
+		room.Result = result
+		room.Termination = termination
+		room.TurnStartedAt = nil
+	}
+	roomMove := &models.RoomMove{
+		RoomID:   room.RoomID,
+		UCI:      chess.UCINotation{}.Encode(prePos, mv),
+		SAN:      chess.AlgebraicNotation{}.Encode(prePos, mv),
+		FENAfter: newFEN,
+		MoverID:  moverID,
+	}
+	return h.RoomRepo.UpdateRoomWithMove(ctx, room, roomMove)
+}
 
+// announceMove tells the players about a recorded move: the final result (followed by the post-game
+// analysis) if the game is over, otherwise the new board with the move under it, followed by the prompt for the next move.
+func (h *Handler) announceMove(ctx context.Context, room *models.Room) {
 	// Check for game completion (checkmate, draw, etc.)
-	outcome := chGame.Outcome()
-	if outcome != chess.NoOutcome {
-		switch outcome {
-		case chess.WhiteWon:
-			h.sendMessageToRoomOrUsers(ctx, room, "Игра завершена! Победили белые.", tgbotapi.ModeHTML)
-		case chess.BlackWon:
-			h.sendMessageToRoomOrUsers(ctx, room, "Игра завершена! Победили чёрные.", tgbotapi.ModeHTML)
-		case chess.Draw:
-			h.sendMessageToRoomOrUsers(ctx, room, "Игра завершена! Ничья.", tgbotapi.ModeHTML)
+	if room.Status == models.RoomStatusFinished {
+		text := "Игра завершена! Ничья."
+		switch room.Result {
+		case models.ResultWhiteWon:
+			text = "Игра завершена! Победили белые."
+		case models.ResultBlackWon:
+			text = "Игра завершена! Победили чёрные."
 		}
-		// Possibly mark room.Status="finished" here or do other final logic.
-		callback := tgbotapi.NewCallback(query.ID, "Ход сделан! Игра окончена.")
-		if _, err = h.Bot.Request(callback); err != nil {
-			utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
-			return
+		h.SendBoardToRoomOrUsers(ctx, room)
+		h.closeKeyboards(ctx, room)
+		text += "\n" + game.FinalSummary(room)
+		if summary := h.ratingSummary(ctx, room); summary != "" {
+			text += "\n" + summary
 		}
-		// Return because the game is done.
+		h.sendMessageToRoomOrUsers(ctx, room, text, tgbotapi.ModeHTML)
+		h.startAnalysis(ctx, room)
 		return
 	}
 
-	// If the game continues, we announce the move to chat or private messages.
-	moveMsg := "Ход сделан:\n"
-	if mv.HasTag(chess.Capture) {
-		moveMsg = fmt.Sprintf("```\n%s\n```", buildMoveButtonText(chGame.Position().Board().Piece(mv.S1()), *mv))
-	} else {
-		moveMsg = fmt.Sprintf("```%s-%s```", fromSquare, toSquare)
-	}
-	h.sendMessageToRoomOrUsers(ctx, room, moveMsg, tgbotapi.ModeMarkdownV2)
-
-	// Send the updated ASCII board to relevant place(s).
+	// If the game continues, the live board message(s) are updated; the move is shown under the board.
 	h.SendBoardToRoomOrUsers(ctx, room)
 
 	// Then prepare next player's move.
-	nextTurn := chGame.Position().Turn()
-	var nextUserID int64
-	if nextTurn == chess.White /* && room.WhiteID != nil*/ {
-		nextUserID = *room.WhiteID
-	} else /* if nextTurn == chess.Black && room.BlackID != nil*/ {
-		nextUserID = *room.BlackID
+	h.promptNextMove(ctx, room)
+}
+
+// promptNextMove asks the side to move for its move: a human player gets the move keyboard,
+// the bot starts thinking in the background.
+func (h *Handler) promptNextMove(ctx context.Context, room *models.Room) {
+	nextUserID := h.sideToMoveID(room)
+	if h.isBot(nextUserID) {
+		if playerID, ok := opponentOf(room, nextUserID); ok && room.ChatID == nil {
+			h.clearKeyboard(ctx, room.RoomID, playerID, keyboardWaitingText)
+		}
+		roomID := room.RoomID
+		h.runInBackground(func(ctx context.Context) { h.playBotMove(ctx, roomID) })
+		return
 	}
 	h.prepareMoveButtons(ctx, room, nextUserID)
+}
 
-	// Confirm callback with "Move successful!"
-	callback := tgbotapi.NewCallback(query.ID, "Ход успешен!")
-	if _, err = h.Bot.Request(callback); err != nil {
-		utils.Logger.Error("AnswerCallbackQuery error: "+err.Error(), zap.Error(err))
+// loadRoomGame rebuilds the game of the room from its stored move history, so that the position
+// history needed for repetition detection is available. Rooms whose history does not lead to the
+// current board (e.g. created before moves were recorded) fall back to a game built from the FEN alone.
+func (h *Handler) loadRoomGame(ctx context.Context, room *models.Room) (*chess.Game, error) {
+	chGame, err := h.MoveRepo.ReplayGame(ctx, room.RoomID, room.InitialFEN)
+	if err == nil && chGame.FEN() == room.BoardState {
+		return chGame, nil
+	}
+	if err != nil {
+		utils.Logger.Warn("ReplayGame: "+err.Error(), zap.Error(err))
+	}
+
+	fenOption, err := chess.FEN(room.BoardState)
+	if err != nil {
+		return nil, err
+	}
+	return chess.NewGame(fenOption), nil
+}
+
+// isPromotionMove reports whether moving from one square to another in the position fen is a pawn reaching the last rank.
+func isPromotionMove(fen string, from, to chess.Square) bool {
+	fenOption, err := chess.FEN(fen)
+	if err != nil {
+		return false
 	}
+	piece := chess.NewGame(fenOption).Position().Board().Piece(from)
+	return piece.Type() == chess.Pawn && (to.Rank() == chess.Rank8 || to.Rank() == chess.Rank1)
 }
 
 // buildMoveButtonText returns a fancy Unicode string describing the move (e.g. castling, capture, promotion).
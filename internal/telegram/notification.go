@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	"lvlchess/internal/db/models"
 	"lvlchess/internal/game"
@@ -35,6 +37,9 @@ func (h *Handler) MakeFinalTitle(ctx context.Context, r *models.Room) (title str
 				return
 			}
 			title = fmt.Sprintf("@%s_⚔️_@%s", p1.Username, p2.Username)
+			if r.BotLevel > 0 {
+				title += fmt.Sprintf(" 🤖 ур. %d", r.BotLevel)
+			}
 		}
 	}
 
@@ -76,7 +81,7 @@ func (h *Handler) tryRenameGroup(bot *tgbotapi.BotAPI, chatID int64, newTitle st
 // notifyGameStarted is used once a room has two players and we want to announce "the game has started."
 // It also sends the ASCII board and prompts the first player for their move.
 func (h *Handler) notifyGameStarted(ctx context.Context, room *models.Room) {
-	introMsg := "Игра началась!\n" + room.RoomTitle
+	introMsg := "Игра началась!\n" + room.RoomTitle + "\n" + h.MakeRatingHeader(ctx, room)
 
 	// 1) Announce the start in group or private chats
 	h.sendMessageToRoomOrUsers(ctx, room, introMsg, tgbotapi.ModeHTML)
@@ -84,8 +89,9 @@ func (h *Handler) notifyGameStarted(ctx context.Context, room *models.Room) {
 	// 2) Show the current board (ASCII-based)
 	h.SendBoardToRoomOrUsers(ctx, room)
 
-	// 3) We call prepareMoveButtons for the White side (since typically White starts).
-	h.prepareMoveButtons(ctx, room, *room.WhiteID)
+	// 3) Prompt the side to move: White in the standard position, but a room created from a custom FEN
+	//    may start with Black. If that side is the bot, it makes its move right away.
+	h.promptNextMove(ctx, room)
 }
 
 // sendMessageToRoom tries to post the message directly to the group's chatID.
@@ -111,6 +117,9 @@ func (h *Handler) sendMessageToRoom(ctx context.Context, room *models.Room, text
 // sendMessageToUser sends a private message to a known user, if we have chatID in the DB.
 // Usually, user.ChatID corresponds to private chat with the bot.
 func (h *Handler) sendMessageToUser(ctx context.Context, userID int64, text string, mode string) {
+	if h.isBot(userID) {
+		return // the bot opponent has no chat of its own
+	}
 	u1, err1 := h.UserRepo.GetUserByID(ctx, userID)
 	if err1 == nil && u1.ChatID != 0 {
 		m1 := tgbotapi.NewMessage(u1.ChatID, text)
@@ -136,40 +145,124 @@ func (h *Handler) sendMessageToRoomOrUsers(ctx context.Context, room *models.Roo
 	}
 }
 
-// SendBoardToRoomOrUsers dispatches an ASCII board representation. The orientation depends on whether
-// a group is used (horizontal) or a private scenario (white sees "normal" board, black sees "flipped").
+// SendBoardToRoomOrUsers dispatches the board. By default it is a PNG picture (see game.RenderBoardPNG)
+// highlighting the last move and a king in check; users who prefer text get the ASCII board instead.
+// A group chat gets a White-oriented picture, or the horizontal ASCII board if the room creator
+// prefers text; in private games White sees the normal orientation and Black the flipped one.
+// The last move and, with a time control, the remaining clocks are added under the board.
+// Each chat has one live board message per room, which is edited in place (see showLiveMessage).
 func (h *Handler) SendBoardToRoomOrUsers(ctx context.Context, r *models.Room) {
 	var asciiBoard string
 	var err error
 
+	lastMove := ""
+	caption := game.FormatClocks(r, time.Now())
+	if mv, err := h.MoveRepo.GetLastMove(ctx, r.RoomID); err != nil {
+		utils.Logger.Warn("GetLastMove: "+err.Error(), zap.Error(err))
+	} else if mv != nil {
+		lastMove = mv.UCI
+		caption = strings.TrimSpace(fmt.Sprintf("Последний ход: %s\n%s", mv.SAN, caption))
+	}
+
 	if r.ChatID != nil {
+		if h.boardStyle(ctx, r.Player1ID) == models.BoardStyleImage &&
+			h.showBoardPhoto(ctx, *r.ChatID, r, chess.White, lastMove, caption) {
+			return
+		}
 		// If a group chat is linked, we typically show "horizontal" style
 		asciiBoard, err = game.RenderASCIIBoardHorizontal(r.BoardState)
 		if err != nil {
 			utils.Logger.Error("game.RenderASCIIBoardHorizontal:"+err.Error(), zap.Error(err))
 			asciiBoard = "Ошибка формирования горизонтальной доски"
 		}
-		h.sendMessageToRoomOrUsers(ctx, r, asciiBoard, tgbotapi.ModeMarkdownV2)
+		h.showBoardText(ctx, *r.ChatID, r, asciiBoard+escapedCaption(caption))
 	} else {
 		// In private games, show White's perspective to White, Black's perspective to Black
-		asciiBoard, err = game.RenderASCIIBoardWhite(r.BoardState)
-		if err != nil {
-			utils.Logger.Error("game.RenderASCIIBoardWhite:"+err.Error(), zap.Error(err))
-			asciiBoard = "Ошибка формирования доски (white)."
-		}
 		if r.WhiteID != nil { // !!!
-			h.sendMessageToUser(ctx, *r.WhiteID, asciiBoard, tgbotapi.ModeMarkdownV2)
-		}
-
-		asciiBoard, err = game.RenderASCIIBoardBlack(r.BoardState)
-		if err != nil {
-			utils.Logger.Error("game.RenderASCIIBoardBlack:"+err.Error(), zap.Error(err))
-			asciiBoard = "Ошибка формирования доски (black)."
+			h.sendBoardToUser(ctx, *r.WhiteID, r, chess.White, lastMove, caption)
 		}
 		if r.BlackID != nil { // !!!
-			h.sendMessageToUser(ctx, *r.BlackID, asciiBoard, tgbotapi.ModeMarkdownV2)
+			h.sendBoardToUser(ctx, *r.BlackID, r, chess.Black, lastMove, caption)
+		}
+	}
+}
+
+// sendBoardToUser shows the board of the room to a player in private chat, oriented for the given color,
+// as a picture or as ASCII text depending on the player's preference.
+func (h *Handler) sendBoardToUser(ctx context.Context, userID int64, r *models.Room, orientation chess.Color, lastMove, caption string) {
+	if h.isBot(userID) {
+		return // the bot opponent has no chat of its own
+	}
+	u, err := h.UserRepo.GetUserByID(ctx, userID)
+	if err != nil || u.ChatID == 0 {
+		return
+	}
+	if u.BoardStyle != models.BoardStyleASCII && h.showBoardPhoto(ctx, u.ChatID, r, orientation, lastMove, caption) {
+		return
+	}
+
+	render, failure := game.RenderASCIIBoardWhite, "Ошибка формирования доски (white)."
+	if orientation == chess.Black {
+		render, failure = game.RenderASCIIBoardBlack, "Ошибка формирования доски (black)."
+	}
+	asciiBoard, err := render(r.BoardState)
+	if err != nil {
+		utils.Logger.Error("game.RenderASCIIBoard:"+err.Error(), zap.Error(err))
+		asciiBoard = failure
+	}
+	h.showBoardText(ctx, u.ChatID, r, asciiBoard+escapedCaption(caption))
+}
+
+// showBoardPhoto renders the board of the room as a PNG and shows it in chatID with the caption.
+// It returns false if the picture could not be rendered or sent, so that the caller can fall back to ASCII.
+func (h *Handler) showBoardPhoto(ctx context.Context, chatID int64, r *models.Room, orientation chess.Color, lastMove, caption string) bool {
+	data, err := game.RenderBoardPNG(game.BoardImage{FEN: r.BoardState, Orientation: orientation, LastMove: lastMove})
+	if err != nil {
+		utils.Logger.Error("game.RenderBoardPNG: "+err.Error(), zap.Error(err))
+		return false
+	}
+	file := tgbotapi.FileBytes{Name: "board.png", Bytes: data}
+
+	photo := tgbotapi.NewPhoto(chatID, file)
+	photo.Caption = caption
+	edit := func(messageID int) tgbotapi.Chattable {
+		media := tgbotapi.NewInputMediaPhoto(file)
+		media.Caption = caption
+		return tgbotapi.EditMessageMediaConfig{
+			BaseEdit: tgbotapi.BaseEdit{ChatID: chatID, MessageID: messageID},
+			Media:    media,
 		}
 	}
+	return h.showLiveMessage(ctx, r.RoomID, chatID, models.RoomMessageBoard, true, photo, edit)
+}
+
+// showBoardText shows an ASCII board (MarkdownV2) of the room in chatID.
+func (h *Handler) showBoardText(ctx context.Context, chatID int64, r *models.Room, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdownV2
+	edit := func(messageID int) tgbotapi.Chattable {
+		e := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		e.ParseMode = tgbotapi.ModeMarkdownV2
+		return e
+	}
+	h.showLiveMessage(ctx, r.RoomID, chatID, models.RoomMessageBoard, false, msg, edit)
+}
+
+// boardStyle returns the board style preferred by the user, defaulting to the picture.
+func (h *Handler) boardStyle(ctx context.Context, userID int64) string {
+	u, err := h.UserRepo.GetUserByID(ctx, userID)
+	if err != nil || u.BoardStyle == "" {
+		return models.BoardStyleImage
+	}
+	return u.BoardStyle
+}
+
+// escapedCaption returns the caption (last move, clocks) prepared to be appended under an ASCII board (MarkdownV2).
+func escapedCaption(caption string) string {
+	if caption == "" {
+		return ""
+	}
+	return "\n" + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, caption)
 }
 
 // keyboardSort is a helper that sorts squares in descending rank (8..1) and ascending file (a..h).
@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// handlePGNCommand processes "/pgn <room_id>". In a group chat linked to a room, the room_id may be omitted.
+// The PGN document is sent back to the same chat as a file.
+func (h *Handler) handlePGNCommand(ctx context.Context, update tgbotapi.Update) {
+	if room := h.commandRoom(ctx, update.Message); room != nil {
+		h.sendRoomPGN(ctx, update.Message.Chat.ID, room)
+	}
+}
+
+// commandRoom resolves the room of a command like "/pgn <room_id>": the room given as argument or,
+// in a group chat linked to a room, that room. The sender must play in the room or write from its group chat.
+// It answers the user and returns nil if the room cannot be used.
+func (h *Handler) commandRoom(ctx context.Context, msg *tgbotapi.Message) *models.Room {
+	roomID := strings.TrimSpace(msg.CommandArguments())
+
+	var room *models.Room
+	var err error
+	switch {
+	case roomID != "":
+		room, err = h.RoomRepo.GetRoomByID(ctx, roomID)
+	case msg.Chat.IsGroup() || msg.Chat.IsSuperGroup():
+		room, err = h.RoomRepo.GetRoomByChatID(ctx, msg.Chat.ID)
+	default:
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf(
+			"Пожалуйста, укажите room_id, например:\n/%s 546e81dc-5aff-463a-9681-3e41627b8df2", msg.Command())))
+		return nil
+	}
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Комната не найдена. Проверьте идентификатор."))
+		return nil
+	}
+
+	if !isRoomParticipant(room, msg.From.ID) && (room.ChatID == nil || *room.ChatID != msg.Chat.ID) {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Вы не являетесь участником этой комнаты."))
+		return nil
+	}
+	return room
+}
+
+// handleExportPGNCallback is triggered by the "📄 PGN" button next to a room in the game list.
+func (h *Handler) handleExportPGNCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if !isRoomParticipant(room, query.From.ID) {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Вы не являетесь участником этой комнаты."))
+		return
+	}
+
+	h.sendRoomPGN(ctx, query.Message.Chat.ID, room)
+}
+
+// sendRoomPGN builds the PGN document of the room (finished or ongoing) and sends it as a .pgn file.
+func (h *Handler) sendRoomPGN(ctx context.Context, chatID int64, room *models.Room) {
+	pgn, err := h.buildRoomPGN(ctx, room)
+	if err != nil {
+		utils.Logger.Error("buildRoomPGN: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сформировать PGN: "+err.Error()))
+		return
+	}
+
+	file := tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("lvlchess_%s.pgn", shortRoomID(room.RoomID)),
+		Bytes: []byte(pgn),
+	}
+	doc := tgbotapi.NewDocument(chatID, file)
+	doc.Caption = fmt.Sprintf("PGN партии %s", room.RoomTitle)
+	if _, err = h.Bot.Send(doc); err != nil {
+		utils.Logger.Error("send PGN document: "+err.Error(), zap.Error(err))
+	}
+}
+
+// buildRoomPGN collects the moves, player names and result of a room and renders them as PGN.
+func (h *Handler) buildRoomPGN(ctx context.Context, room *models.Room) (string, error) {
+	moves, err := h.MoveRepo.GetMovesByRoomID(ctx, room.RoomID)
+	if err != nil {
+		return "", err
+	}
+
+	result := chess.NoOutcome.String()
+	if room.Result != models.ResultNone {
+		result = room.Result
+	}
+
+	header := game.PGNHeader{
+		Event:    "lvlChess: " + room.RoomTitle,
+		Site:     fmt.Sprintf("https://t.me/%s", h.Bot.Self.UserName),
+		Date:     room.CreatedAt,
+		White:    h.playerDisplayName(ctx, room.WhiteID),
+		Black:    h.playerDisplayName(ctx, room.BlackID),
+		Result:   result,
+		StartFEN: room.InitialFEN,
+	}
+	return game.BuildPGN(header, moves)
+}
+
+// playerDisplayName returns "@username" (or the first name if there is no username) for the given user ID.
+// A nil ID or an unknown user yields "?", the PGN placeholder for an unknown player.
+func (h *Handler) playerDisplayName(ctx context.Context, userID *int64) string {
+	if userID == nil {
+		return "?"
+	}
+	u, err := h.UserRepo.GetUserByID(ctx, *userID)
+	if err != nil {
+		return "?"
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	if u.FirstName != "" {
+		return u.FirstName
+	}
+	return "?"
+}
+
+// isRoomParticipant reports whether userID is Player1 or Player2 of the room.
+func isRoomParticipant(room *models.Room, userID int64) bool {
+	return room.Player1ID == userID || (room.Player2ID != nil && *room.Player2ID == userID)
+}
+
+// shortRoomID returns the first block of a UUID room ID, handy for file names.
+func shortRoomID(roomID string) string {
+	if i := strings.IndexByte(roomID, '-'); i > 0 {
+		return roomID[:i]
+	}
+	return roomID
+}
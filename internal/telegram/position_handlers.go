@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// maxPGNUploadSize limits how many bytes of an uploaded PGN file we are willing to download and parse.
+const maxPGNUploadSize = 512 * 1024
+
+// handleCreateFromPositionHint is called when user clicks "Комната из позиции" in the start menu.
+// It explains the two ways to start from a custom position: /fen <FEN> or uploading a .pgn file.
+func (h *Handler) handleCreateFromPositionHint(_ context.Context, query *tgbotapi.CallbackQuery) {
+	text := `Создать комнату из произвольной позиции можно двумя способами:
+1) Отправьте команду /fen <FEN>, например:
+/fen 8/8/4k3/8/2K5/8/3P4/8 w - - 0 1
+2) Пришлите файл .pgn — комната начнётся с финальной позиции первой партии в файле.
+
+Сторона, которая ходит в позиции, и права на рокировку берутся из FEN.`
+	h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, text))
+}
+
+// handleFENCommand processes "/fen <FEN>" in private chat: validates the position and creates a room from it.
+func (h *Handler) handleFENCommand(ctx context.Context, update tgbotapi.Update) {
+	msg := update.Message
+	args := strings.TrimSpace(msg.CommandArguments())
+	if args == "" {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+			"Пожалуйста, укажите позицию в формате FEN, например:\n/fen 8/8/4k3/8/2K5/8/3P4/8 w - - 0 1"))
+		return
+	}
+
+	h.createRoomFromPosition(ctx, update, args)
+}
+
+// handlePGNUpload is triggered when a user sends a document in private chat. If it looks like a PGN file,
+// we download it, take the final position of the first game and create a room from it.
+func (h *Handler) handlePGNUpload(ctx context.Context, update tgbotapi.Update) {
+	msg := update.Message
+	doc := msg.Document
+
+	if !strings.HasSuffix(strings.ToLower(doc.FileName), ".pgn") &&
+		doc.MimeType != "application/x-chess-pgn" && doc.MimeType != "application/vnd.chess-pgn" {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Я принимаю только файлы .pgn."))
+		return
+	}
+	if doc.FileSize > maxPGNUploadSize {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+			fmt.Sprintf("Файл слишком большой (максимум %d КБ).", maxPGNUploadSize/1024)))
+		return
+	}
+
+	fileURL, err := h.Bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		utils.Logger.Error("GetFileDirectURL: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Не удалось получить файл от Telegram."))
+		return
+	}
+
+	downloadCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(downloadCtx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Не удалось скачать файл."))
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		utils.Logger.Error("download PGN: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Не удалось скачать файл."))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Не удалось скачать файл."))
+		return
+	}
+
+	fen, err := game.PositionFromPGN(io.LimitReader(resp.Body, maxPGNUploadSize))
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Ошибка в PGN: "+err.Error()))
+		return
+	}
+
+	h.createRoomFromPosition(ctx, update, fen)
+}
+
+// createRoomFromPosition validates fen and, if the position is legal, creates a new room starting from it
+// for the message author, followed by the usual invite message and a preview of the position.
+func (h *Handler) createRoomFromPosition(ctx context.Context, update tgbotapi.Update, fen string) {
+	msg := update.Message
+
+	normalized, err := game.ValidateFEN(fen)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Недопустимая позиция: "+err.Error()))
+		return
+	}
+
+	// Make sure the creator exists in "users" (rooms.player1_id references it).
+	creator := &models.User{
+		ID:        msg.From.ID,
+		Username:  msg.From.UserName,
+		FirstName: msg.From.FirstName,
+		ChatID:    msg.Chat.ID,
+	}
+	if err = h.UserRepo.CreateOrUpdateUser(ctx, creator); err != nil {
+		utils.Logger.Error("CreateOrUpdateUser: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Не удалось создать комнату, попробуйте ещё раз."))
+		return
+	}
+
+	room, err := models.PrepareNewRoomFromFEN(creator.ID, h.MakeFinalTitle(ctx, nil), normalized)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "Недопустимая позиция: "+err.Error()))
+		return
+	}
+	if !h.createRoomAndInvite(ctx, msg.Chat.ID, room) {
+		return
+	}
+
+	preview, err := game.RenderASCIIBoardWhite(room.BoardState)
+	if err != nil {
+		utils.Logger.Error("game.RenderASCIIBoardWhite:"+err.Error(), zap.Error(err))
+		return
+	}
+	h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+		fmt.Sprintf("Стартовая позиция (ход %s):", getCurrentTurnUsername(room))))
+	previewMsg := tgbotapi.NewMessage(msg.Chat.ID, preview)
+	previewMsg.ParseMode = tgbotapi.ModeMarkdownV2
+	h.Bot.Send(previewMsg)
+}
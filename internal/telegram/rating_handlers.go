@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// handleToggleRatedCallback switches a waiting room between rated and casual ("rated_toggle:<roomID>").
+// Rooms started from a custom position are always casual.
+func (h *Handler) handleToggleRatedCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if room.Player1ID != query.From.ID || room.Status != models.RoomStatusWaiting {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Тип партии может изменить только создатель комнаты до начала игры."))
+		return
+	}
+	if room.InitialFEN != "" {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Партии из произвольной позиции всегда товарищеские."))
+		return
+	}
+
+	room.Rated = !room.Rated
+	if err = h.RoomRepo.UpdateRoom(ctx, room); err != nil {
+		utils.Logger.Error("UpdateRoom (rated): "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось изменить тип партии."))
+		return
+	}
+	h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Тип партии: "+ratedLabel(room)))
+}
+
+// leaderboardSize is how many players are listed in a leaderboard.
+const leaderboardSize = 10
+
+// handleTopCommand processes "/top [category]", e.g. "/top blitz". Without a category,
+// it offers one button per rating pool.
+func (h *Handler) handleTopCommand(ctx context.Context, update tgbotapi.Update) {
+	msg := update.Message
+	category := strings.ToLower(strings.TrimSpace(msg.CommandArguments()))
+	if category == "" {
+		h.sendLeaderboardMenu(msg.Chat.ID)
+		return
+	}
+	if !slices.Contains(models.RatingCategories, category) {
+		h.Bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+			"Неизвестная категория. Доступны: "+strings.Join(models.RatingCategories, ", ")))
+		return
+	}
+	h.sendLeaderboard(ctx, msg.Chat.ID, msg.From.ID, category)
+}
+
+// handleLeaderboardCallback is triggered by the "🏆 Рейтинг-лист" menu button ("leaderboard")
+// and by the category buttons ("leaderboard:<category>").
+func (h *Handler) handleLeaderboardCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	category := strings.TrimPrefix(strings.TrimPrefix(query.Data, Leaderboard), CommandDelimiter)
+	if category == "" {
+		h.sendLeaderboardMenu(query.Message.Chat.ID)
+		return
+	}
+	h.sendLeaderboard(ctx, query.Message.Chat.ID, query.From.ID, category)
+}
+
+// sendLeaderboardMenu offers one button per rating category.
+func (h *Handler) sendLeaderboardMenu(chatID int64) {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, category := range models.RatingCategories {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			categoryLabel(category), fmt.Sprintf("%s%s%s", Leaderboard, CommandDelimiter, category)))
+	}
+	msg := tgbotapi.NewMessage(chatID, "Выберите категорию рейтинга:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	h.Bot.Send(msg)
+}
+
+// sendLeaderboard lists the best established (non-provisional) players of a category,
+// followed by the requesting user's own rating in that category.
+func (h *Handler) sendLeaderboard(ctx context.Context, chatID, userID int64, category string) {
+	top, err := h.RatingRepo.GetLeaderboard(ctx, category, leaderboardSize)
+	if err != nil {
+		utils.Logger.Error("GetLeaderboard: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить рейтинг-лист."))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏆 Рейтинг-лист: %s\n", categoryLabel(category)))
+	if len(top) == 0 {
+		sb.WriteString(fmt.Sprintf("Пока нет игроков с %d+ рейтинговыми партиями в этой категории.\n",
+			models.ProvisionalGames))
+	}
+	for i, ur := range top {
+		sb.WriteString(fmt.Sprintf("%d. %s — %s (партий: %d)\n",
+			i+1, h.playerDisplayName(ctx, &ur.UserID), formatRating(&ur), ur.Games))
+	}
+
+	if own, err := h.RatingRepo.GetUserRating(ctx, userID, category); err == nil {
+		sb.WriteString(fmt.Sprintf("\nВаш рейтинг: %s (партий: %d)", formatRating(own), own.Games))
+		if own.IsProvisional() {
+			sb.WriteString(fmt.Sprintf(" — предварительный, до %d партий", models.ProvisionalGames))
+		}
+	}
+	h.Bot.Send(tgbotapi.NewMessage(chatID, sb.String()))
+}
+
+// MakeRatingHeader builds a MakeFinalTitle-style header with the ratings of the room's category,
+// e.g. "@white (1512) ⚔️ @black (1480?) · блиц, рейтинговая". "?" marks a provisional rating.
+func (h *Handler) MakeRatingHeader(ctx context.Context, room *models.Room) string {
+	category := models.RatingCategoryFor(room)
+	return fmt.Sprintf("%s ⚔️ %s · %s, %s",
+		h.playerWithRating(ctx, room.WhiteID, category), h.playerWithRating(ctx, room.BlackID, category),
+		categoryLabel(category), ratedLabel(room))
+}
+
+// ratingSummary describes how a finished rated game changed the ratings, e.g.
+// "📈 Рейтинг (блиц): @white 1512 (+12) ⚔️ @black 1480 (−12)". It returns "" for casual or unrated results.
+func (h *Handler) ratingSummary(ctx context.Context, room *models.Room) string {
+	if !room.Rated || room.Result == models.ResultNone {
+		return ""
+	}
+	changes, err := h.RatingRepo.GetRatingChangesByRoomID(ctx, room.RoomID)
+	if err != nil {
+		utils.Logger.Error("GetRatingChangesByRoomID: "+err.Error(), zap.Error(err))
+		return ""
+	}
+
+	byUser := make(map[int64]models.RatingChange, len(changes))
+	for _, c := range changes {
+		byUser[c.UserID] = c
+	}
+	describe := func(userID *int64) string {
+		if userID == nil {
+			return "?"
+		}
+		c, ok := byUser[*userID]
+		if !ok {
+			return h.playerDisplayName(ctx, userID)
+		}
+		return fmt.Sprintf("%s %d (%s)", h.playerDisplayName(ctx, userID), roundRating(c.RatingAfter), formatDelta(c.Delta()))
+	}
+	return fmt.Sprintf("📈 Рейтинг (%s): %s ⚔️ %s",
+		categoryLabel(models.RatingCategoryFor(room)), describe(room.WhiteID), describe(room.BlackID))
+}
+
+// playerWithRating returns "@username (1512)" with the rating of the given category
+// ("1500?" while provisional), or just the name if the rating cannot be loaded.
+func (h *Handler) playerWithRating(ctx context.Context, userID *int64, category string) string {
+	if userID == nil {
+		return "?"
+	}
+	ur, err := h.RatingRepo.GetUserRating(ctx, *userID, category)
+	if err != nil {
+		return h.playerDisplayName(ctx, userID)
+	}
+	return fmt.Sprintf("%s (%s)", h.playerDisplayName(ctx, userID), formatRating(ur))
+}
+
+// ratedLabel returns "рейтинговая" or "товарищеская".
+func ratedLabel(room *models.Room) string {
+	if room.Rated {
+		return "рейтинговая"
+	}
+	return "товарищеская"
+}
+
+// formatRating renders a rating for display, with "?" appended while it is provisional.
+func formatRating(ur *models.UserRating) string {
+	if ur.IsProvisional() {
+		return fmt.Sprintf("%d?", roundRating(ur.Rating))
+	}
+	return fmt.Sprintf("%d", roundRating(ur.Rating))
+}
+
+// categoryLabel returns the Russian name of a rating category; unknown categories (variants) are shown as is.
+func categoryLabel(category string) string {
+	switch category {
+	case models.RatingCategoryBullet:
+		return "пуля"
+	case models.RatingCategoryBlitz:
+		return "блиц"
+	case models.RatingCategoryRapid:
+		return "рапид"
+	case models.RatingCategoryClassical:
+		return "классика"
+	case models.RatingCategoryCorrespondence:
+		return "по переписке"
+	default:
+		return category
+	}
+}
+
+// roundRating rounds a Glicko-2 rating for display.
+func roundRating(r float64) int {
+	return int(math.Round(r))
+}
+
+// formatDelta renders a rating change with an explicit sign: "+12", "−7", "±0".
+func formatDelta(d float64) string {
+	n := roundRating(d)
+	switch {
+	case n > 0:
+		return fmt.Sprintf("+%d", n)
+	case n < 0:
+		return fmt.Sprintf("−%d", -n)
+	default:
+		return "±0"
+	}
+}
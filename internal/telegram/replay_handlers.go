@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	"lvlchess/config"
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// handleReplayCommand processes "/replay <room_id>" (the room_id may be omitted in a group chat linked to a room)
+// and sends the animated replay of the finished game to the same chat.
+func (h *Handler) handleReplayCommand(ctx context.Context, update tgbotapi.Update) {
+	if room := h.commandRoom(ctx, update.Message); room != nil {
+		h.sendReplay(ctx, update.Message.Chat.ID, room, update.Message.From.ID)
+	}
+}
+
+// handleReplayCallback is triggered by the "🎞" (replay) button of a finished game in the game list.
+func (h *Handler) handleReplayCallback(ctx context.Context, query *tgbotapi.CallbackQuery, roomID string) {
+	room, err := h.RoomRepo.GetRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Комната не найдена."))
+		return
+	}
+	if !isRoomParticipant(room, query.From.ID) {
+		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Вы не являетесь участником этой комнаты."))
+		return
+	}
+
+	h.sendReplay(ctx, query.Message.Chat.ID, room, query.From.ID)
+}
+
+// sendReplay renders the finished game of the room as an animated GIF, one frame per ply, and sends it to chatID.
+// The board is shown from the side of requesterID (White for spectators).
+func (h *Handler) sendReplay(ctx context.Context, chatID int64, room *models.Room, requesterID int64) {
+	if room.Status != models.RoomStatusFinished {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Повтор доступен только для завершённых партий."))
+		return
+	}
+	moves, err := h.MoveRepo.GetMovesByRoomID(ctx, room.RoomID)
+	if err != nil {
+		utils.Logger.Error("GetMovesByRoomID: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить ходы партии."))
+		return
+	}
+	if len(moves) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "В партии не было ходов — показывать нечего."))
+		return
+	}
+
+	orientation := chess.White
+	if room.BlackID != nil && *room.BlackID == requesterID {
+		orientation = chess.Black
+	}
+
+	h.Bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadVideo))
+	data, err := game.RenderReplayGIF(room.InitialFEN, moves, orientation, config.Cfg.ReplayFrameDelay)
+	if err != nil {
+		utils.Logger.Error("game.RenderReplayGIF: "+err.Error(), zap.Error(err))
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось подготовить повтор партии."))
+		return
+	}
+
+	anim := tgbotapi.NewAnimation(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("lvlchess_%s.gif", shortRoomID(room.RoomID)),
+		Bytes: data,
+	})
+	anim.Caption = fmt.Sprintf("🎞 %s\n%s", room.RoomTitle, game.FinalSummary(room))
+	if _, err = h.Bot.Send(anim); err != nil {
+		utils.Logger.Error("send replay animation: "+err.Error(), zap.Error(err))
+	}
+}
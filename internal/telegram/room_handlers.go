@@ -2,6 +2,7 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -20,22 +21,28 @@ import (
 func (h *Handler) handleCreateRoomCommand(ctx context.Context, query *tgbotapi.CallbackQuery) {
 	// Prepare a new room for the user who clicked the button.
 	room := models.PrepareNewRoom(query.From.ID, h.MakeFinalTitle(ctx, nil))
+	h.createRoomAndInvite(ctx, query.Message.Chat.ID, room)
+}
 
+// createRoomAndInvite stores a prepared room and replies to chatID with the invite link and the
+// follow-up buttons (invite, create group chat, delete). It returns false if the room was not created.
+func (h *Handler) createRoomAndInvite(ctx context.Context, chatID int64, room *models.Room) bool {
 	if err := h.RoomRepo.CreateRoom(ctx, room); err != nil {
 		// If there's a unique violation error, maybe a room with those two players already exists.
 		if err.Error() == repositories.ErrUniqueViolation {
 			// We could handle it, e.g. checkExistingRoom(...).
-			return
+			return false
 		}
 
-		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
 			"Ошибка создания комнаты: "+err.Error()))
-		return
+		return false
 	}
 
 	// Generate a standard link like t.me/BOTUSERNAME?start=room_<roomID>
 	inviteLink := fmt.Sprintf("https://t.me/%s?start=room_%s", h.Bot.Self.UserName, room.RoomID)
-	text := fmt.Sprintf("Комната создана!\n\nRoomID: %s\nСсылка: %s", room.RoomID, inviteLink)
+	text := fmt.Sprintf("Комната создана!\n\nRoomID: %s\nСсылка: %s\nПартия: %s",
+		room.RoomID, inviteLink, ratedLabel(room))
 
 	// Provide an inline button to "Create and go to Chat"
 	createChatButton := tgbotapi.NewInlineKeyboardButtonData(
@@ -50,18 +57,33 @@ func (h *Handler) handleCreateRoomCommand(ctx context.Context, query *tgbotapi.C
 	)
 	inviteButton := tgbotapi.NewInlineKeyboardButtonURL("Пригласить", shareURL)
 
-	// A third button "Удалить комнату"
+	// A third button "Контроль времени" (only before the game starts)
+	timeControlButton := tgbotapi.NewInlineKeyboardButtonData(
+		"⏱ Контроль времени",
+		fmt.Sprintf("%s%s%s", TimeControlMenu, CommandDelimiter, room.RoomID),
+	)
+
+	// A fourth button switching between a rated and a casual game
+	ratedButton := tgbotapi.NewInlineKeyboardButtonData(
+		"⚖ Рейтинговая / товарищеская",
+		fmt.Sprintf("%s%s%s", ToggleRated, CommandDelimiter, room.RoomID),
+	)
+
+	// A fifth button "Удалить комнату"
 	deleteButton := tgbotapi.NewInlineKeyboardButtonData("Удалить комнату", Delete+room.RoomID)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(inviteButton),
 		tgbotapi.NewInlineKeyboardRow(createChatButton),
+		tgbotapi.NewInlineKeyboardRow(timeControlButton),
+		tgbotapi.NewInlineKeyboardRow(ratedButton),
 		tgbotapi.NewInlineKeyboardRow(deleteButton),
 	)
 
-	msg := tgbotapi.NewMessage(query.Message.Chat.ID, text)
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
 	h.Bot.Send(msg)
+	return true
 }
 
 // handleJoinRoom is triggered in two scenarios:
@@ -109,13 +131,17 @@ func (h *Handler) handleJoinRoom(ctx context.Context, update tgbotapi.Update, ro
 	// Update the title to reflect both participants
 	room.RoomTitle = h.MakeFinalTitle(ctx, room)
 
-	// Notify that the game has started
-	h.notifyGameStarted(ctx, room)
-
-	if err = h.RoomRepo.UpdateRoom(ctx, room); err != nil {
+	if err = h.RoomRepo.UpdateRoom(ctx, room); errors.Is(err, repositories.ErrRoomVersionConflict) {
+		// Someone else joined (or the room was changed) at the same moment.
+		h.Bot.Send(tgbotapi.NewMessage(newPlayer.ChatID, "Комната только что изменилась, попробуйте присоединиться ещё раз."))
+		return
+	} else if err != nil {
 		h.Bot.Send(tgbotapi.NewMessage(newPlayer.ChatID, "Ошибка обновления комнаты: "+err.Error()))
 		return
 	}
+
+	// Notify that the game has started
+	h.notifyGameStarted(ctx, room)
 }
 
 // handleSetRoomCommand is used in a group chat to link that chat to a specific room via /setroom <roomID> command.
@@ -278,7 +304,7 @@ func (h *Handler) handleChooseRoom(ctx context.Context, query *tgbotapi.Callback
 		asciiBoard = "Ошибка формирования доски"
 	}
 
-	text := fmt.Sprintf("Войти в комнату_№%s (ход @...)?\n%s", room.RoomTitle)
+	text := fmt.Sprintf("Войти в комнату_№%s (ход @...)?\n%s", room.RoomTitle, asciiBoard)
 	h.sendMessageToUser(ctx, query.Message.Chat.ID, text, tgbotapi.ModeHTML)
 
 	callbackData := fmt.Sprintf("join_this_room:%s", room.RoomID)
@@ -307,6 +333,7 @@ func (h *Handler) handleJoinThisRoom(ctx context.Context, query *tgbotapi.Callba
 	text := fmt.Sprintf("Вы зашли в комнату %s. В личке теперь используете её для ходов.", room.RoomTitle)
 	h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, text))
 	if (room.IsWhiteTurn && *room.WhiteID == userID) || (!room.IsWhiteTurn && *room.BlackID == userID) {
+		h.forgetRoomMessages(ctx, room.RoomID, query.Message.Chat.ID)
 		h.prepareMoveButtons(ctx, room, userID)
 	}
 }
@@ -326,7 +353,7 @@ func (h *Handler) checkExistingRoom(ctx context.Context, p1ID, p2ID int64) bool
 		btn := tgbotapi.NewInlineKeyboardButtonData("Войти в комнату", callbackData)
 		kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(btn))
 
-		SendInlineKeyboard(h.Bot, existingRoom, text, kb)
+		h.showMoveKeyboard(ctx, existingRoom, text, kb)
 		return true
 	}
 	return false
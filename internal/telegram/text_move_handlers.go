@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// textMoveHint lists the notations accepted for typed moves.
+const textMoveHint = "Напишите ход, например: e4, Nf3, exd5, O-O, e8=Q, e2e4 или Кf3 (Кр, Ф, Л, С, К — русские буквы фигур)."
+
+// handleTextMove treats a plain text message as a move typed in chess notation: in a group chat it is a move
+// in the chat's room, in a private chat a move in the sender's game. It returns false when the sender has no game
+// in progress there, or when a group message is not written like a move (the players just chat), so the message
+// is not about a move at all.
+func (h *Handler) handleTextMove(ctx context.Context, msg *tgbotapi.Message) bool {
+	if msg.From == nil || msg.Text == "" {
+		return false
+	}
+
+	var room *models.Room
+	isGroup := msg.Chat.IsGroup() || msg.Chat.IsSuperGroup()
+	if isGroup {
+		room = h.groupTextMoveRoom(ctx, msg)
+	} else {
+		var handled bool
+		if room, handled = h.privateTextMoveRoom(ctx, msg); handled {
+			return true
+		}
+	}
+	if room == nil {
+		return false
+	}
+
+	if !game.LooksLikeMove(msg.Text) {
+		if isGroup {
+			return false
+		}
+		h.replyToMessage(msg, "Не понимаю такой ход. "+textMoveHint)
+		return true
+	}
+
+	decode := func(pos *chess.Position) (*chess.Move, error) {
+		return game.ParseMove(pos, msg.Text)
+	}
+	if text, played := h.playMove(ctx, room, msg.From.ID, decode); !played && text != "" {
+		h.replyToMessage(msg, "❌ "+capitalize(text))
+	}
+	return true
+}
+
+// groupTextMoveRoom returns the room of the group chat if a game is going on there and the sender plays in it.
+func (h *Handler) groupTextMoveRoom(ctx context.Context, msg *tgbotapi.Message) *models.Room {
+	room, err := h.RoomRepo.GetRoomByChatID(ctx, msg.Chat.ID)
+	if err != nil || room == nil {
+		return nil
+	}
+	if room.Status != models.RoomStatusPlaying || !isRoomParticipant(room, msg.From.ID) {
+		return nil
+	}
+	return room
+}
+
+// privateTextMoveRoom picks the game a move typed in private chat belongs to: the only game of the sender
+// where it is their turn, or their only game in progress. If it is the sender's turn in several games,
+// the move cannot be assigned: the sender is told so and handled is true.
+func (h *Handler) privateTextMoveRoom(ctx context.Context, msg *tgbotapi.Message) (room *models.Room, handled bool) {
+	rooms, err := h.RoomRepo.GetPlayingRoomsForUser(ctx, msg.From.ID)
+	if err != nil {
+		utils.Logger.Error("GetPlayingRoomsForUser: "+err.Error(), zap.Error(err))
+		return nil, false
+	}
+
+	var playing, myTurn []*models.Room
+	for i := range rooms {
+		if rooms[i].Status != models.RoomStatusPlaying {
+			continue
+		}
+		playing = append(playing, &rooms[i])
+		if h.sideToMoveID(&rooms[i]) == msg.From.ID {
+			myTurn = append(myTurn, &rooms[i])
+		}
+	}
+
+	switch {
+	case len(myTurn) == 1:
+		return myTurn[0], false
+	case len(myTurn) > 1:
+		titles := make([]string, len(myTurn))
+		for i, r := range myTurn {
+			titles[i] = "«" + r.RoomTitle + "»"
+		}
+		h.replyToMessage(msg, fmt.Sprintf("Сейчас ваш ход сразу в нескольких партиях: %s. "+
+			"Сделайте ход кнопками в нужной партии.", strings.Join(titles, ", ")))
+		return nil, true
+	case len(playing) > 0:
+		// Not the sender's turn anywhere: playMove explains that.
+		return playing[0], false
+	}
+	return nil, false
+}
+
+// replyToMessage answers a message in its chat, quoting it.
+func (h *Handler) replyToMessage(msg *tgbotapi.Message, text string) {
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyToMessageID = msg.MessageID
+	if _, err := h.Bot.Send(reply); err != nil {
+		utils.Logger.Error("reply to message: "+err.Error(), zap.Error(err))
+	}
+}
+
+// capitalize upper-cases the first letter of text.
+func capitalize(text string) string {
+	r := []rune(text)
+	if len(r) == 0 {
+		return text
+	}
+	return strings.ToUpper(string(r[0])) + string(r[1:])
+}
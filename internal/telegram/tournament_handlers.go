@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"lvlchess/internal/db"
 	"lvlchess/internal/db/models"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -14,7 +13,7 @@ import (
 // The actual logic to retrieve such data is not fully implemented here.
 func (h *Handler) handleTournamentList(ctx context.Context, query *tgbotapi.CallbackQuery) {
 	// userID := query.From.ID
-	// Possibly call db.GetTournamentsRepo().GetTournamentsByUser(userID), etc.
+	// Possibly call h.TournamentRepo.GetTournamentsByUser(userID), etc.
 	text := "Список ваших турниров: (заглушка)\n(тут вывести названия, статусы, кнопки 'Присоединиться', 'Старт')"
 	msg := tgbotapi.NewMessage(query.Message.Chat.ID, text)
 	h.Bot.Send(msg)
@@ -29,7 +28,7 @@ func (h *Handler) handleCreateTournament(ctx context.Context, query *tgbotapi.Ca
 		Players: []int64{query.From.ID}, // the initiator
 		Status:  models.TournamentStatusPlanned,
 	}
-	if err := db.GetTournamentsRepo().CreateTournament(ctx, t); err != nil {
+	if err := h.TournamentRepo.CreateTournament(ctx, t); err != nil {
 		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
 			"Ошибка создания турнира: "+err.Error()))
 		return
@@ -43,7 +42,7 @@ func (h *Handler) handleCreateTournament(ctx context.Context, query *tgbotapi.Ca
 // We add them to the "Players" array in that tournament record.
 func (h *Handler) handleJoinTournament(ctx context.Context, query *tgbotapi.CallbackQuery, tournamentID string) {
 	userID := query.From.ID
-	err := db.GetTournamentsRepo().AddPlayer(ctx, tournamentID, userID)
+	err := h.TournamentRepo.AddPlayer(ctx, tournamentID, userID)
 	if err != nil {
 		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Ошибка: "+err.Error()))
 		return
@@ -54,7 +53,7 @@ func (h *Handler) handleJoinTournament(ctx context.Context, query *tgbotapi.Call
 // handleStartTournament sets tournament status to active=1 and sets start_at=NOW() in DB.
 // Then we might create the first round of rooms, etc. (not fully shown here).
 func (h *Handler) handleStartTournament(ctx context.Context, query *tgbotapi.CallbackQuery, tournamentID string) {
-	err := db.GetTournamentsRepo().StartTournament(ctx, tournamentID)
+	err := h.TournamentRepo.StartTournament(ctx, tournamentID)
 	if err != nil {
 		h.Bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID, "Ошибка старта турнира: "+err.Error()))
 		return
@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"lvlchess/config"
+	"lvlchess/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// WebhookPathPrefix is the path of the webhook on the HTTP server, followed by the path secret.
+const WebhookPathPrefix = "/telegram/webhook/"
+
+// webhookSecretHeader carries the secret token Telegram was given in setWebhook.
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+const (
+	// webhookMaxBody limits the size of an update accepted by the webhook.
+	webhookMaxBody = 1 << 20
+	// webhookEnqueueTimeout is how long a request waits for room in a full queue before it is answered
+	// with 503, so that Telegram delivers the update again later.
+	webhookEnqueueTimeout = 5 * time.Second
+)
+
+/*
+WebhookReceiver is the HTTP handler of the webhook mode: it accepts the updates Telegram posts to
+WebhookPathPrefix+secrets.Path and hands them out through Updates, to be fed into Handler.HandleUpdate just like
+the long-polling updates. The queue is bounded: when the bot falls behind, requests wait a little and are
+then refused with 503, and Telegram retries them, instead of the updates piling up in memory.
+*/
+type WebhookReceiver struct {
+	secrets WebhookSecrets
+	updates chan tgbotapi.Update
+}
+
+// NewWebhookReceiver creates a receiver that accepts requests carrying secrets and queues up to queueSize updates.
+func NewWebhookReceiver(secrets WebhookSecrets, queueSize int) *WebhookReceiver {
+	return &WebhookReceiver{
+		secrets: secrets,
+		updates: make(chan tgbotapi.Update, queueSize),
+	}
+}
+
+// Updates returns the channel of the received updates.
+func (wr *WebhookReceiver) Updates() <-chan tgbotapi.Update {
+	return wr.updates
+}
+
+// ServeHTTP checks the path secret and the secret token header, decodes the update and queues it.
+func (wr *WebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !secretEqual(strings.TrimPrefix(r.URL.Path, WebhookPathPrefix), wr.secrets.Path) {
+		http.NotFound(w, r)
+		return
+	}
+	if !secretEqual(r.Header.Get(webhookSecretHeader), wr.secrets.Token) {
+		utils.Logger.Warn("webhook request with a wrong secret token", zap.String("remote", r.RemoteAddr))
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookMaxBody)).Decode(&update); err != nil {
+		utils.Logger.Warn("webhook: bad update: "+err.Error(), zap.Error(err))
+		http.Error(w, "bad update", http.StatusBadRequest)
+		return
+	}
+
+	timer := time.NewTimer(webhookEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case wr.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-timer.C:
+		utils.Logger.Warn("webhook queue is full, update refused", zap.Int("updateID", update.UpdateID))
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+// secretEqual compares secrets in constant time.
+func secretEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// WebhookSecrets are the two secrets of the webhook: Path ends its URL and Token is sent by Telegram in
+// the secret token header. They are different, so a URL leaked from a proxy or access log is not enough
+// to post updates.
+type WebhookSecrets struct {
+	Path  string
+	Token string
+}
+
+// NewWebhookSecrets derives the webhook secrets from the configured WEBHOOK_SECRET or, without it,
+// from the bot token, so they stay the same across restarts.
+func NewWebhookSecrets() WebhookSecrets {
+	base := config.Cfg.WebhookSecret
+	if base == "" {
+		base = config.Cfg.BotToken
+	}
+	derive := func(label string) string {
+		sum := sha256.Sum256([]byte("lvlchess webhook " + label + ":" + base))
+		return hex.EncodeToString(sum[:16])
+	}
+	return WebhookSecrets{Path: derive("path"), Token: derive("token")}
+}
+
+// SetWebhook tells Telegram to post the updates to baseURL+WebhookPathPrefix+secrets.Path with secrets.Token as
+// the secret token header. The setWebhook config of the library has no secret_token, so the request is made directly.
+func SetWebhook(bot *tgbotapi.BotAPI, baseURL string, secrets WebhookSecrets) error {
+	params := tgbotapi.Params{
+		"url":          strings.TrimRight(baseURL, "/") + WebhookPathPrefix + secrets.Path,
+		"secret_token": secrets.Token,
+	}
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("setWebhook: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhook removes the webhook, so that updates can be received with long polling again.
+func DeleteWebhook(bot *tgbotapi.BotAPI) error {
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("deleteWebhook: %w", err)
+	}
+	return nil
+}
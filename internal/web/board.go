@@ -0,0 +1,146 @@
+// Package web contains the HTTP endpoints of the bot that are not part of the Telegram API flow,
+// e.g. board pictures that can be embedded into web pages.
+package web
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"lvlchess/internal/db/models"
+	"lvlchess/internal/game"
+	"lvlchess/internal/utils"
+
+	"github.com/notnil/chess"
+	"go.uber.org/zap"
+)
+
+// BoardPathPrefix is the path under which BoardSVGHandler serves the boards: /board/<room_id>.svg
+const BoardPathPrefix = "/board/"
+
+// LinkSigner signs and checks the board links, so that only links handed out by the bot show a room
+// (implemented by callbackdata.Codec).
+type LinkSigner interface {
+	SignLink(resource string, expires time.Time) string
+	VerifyLink(resource string, expires time.Time, sig string) error
+}
+
+// BoardPath returns the signed path of the board picture of a room, valid for ttl:
+// /board/<room_id>.svg?exp=<unix seconds>&sig=<signature>.
+func BoardPath(links LinkSigner, roomID string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl)
+	query := url.Values{
+		"exp": {strconv.FormatInt(expires.Unix(), 10)},
+		"sig": {links.SignLink(roomID, expires)},
+	}
+	return BoardPathPrefix + url.PathEscape(roomID) + ".svg?" + query.Encode()
+}
+
+// RoomSource provides the rooms to the board endpoint.
+type RoomSource interface {
+	GetRoomByID(ctx context.Context, roomID string) (*models.Room, error)
+}
+
+// LastMoveSource provides the last move of a room, to highlight it on the board.
+type LastMoveSource interface {
+	GetLastMove(ctx context.Context, roomID string) (*models.RoomMove, error)
+}
+
+// markColors maps the color names accepted in the query string to the marker colors.
+var markColors = map[string]color.NRGBA{
+	"green":  game.GreenMark,
+	"red":    game.RedMark,
+	"blue":   game.BlueMark,
+	"yellow": game.YellowMark,
+}
+
+/*
+BoardSVGHandler serves the current position of a room as SVG at /board/<room_id>.svg,
+with the last move highlighted. The link must be signed (see BoardPath): the exp and sig parameters
+are checked with links, and a missing, wrong or expired signature is answered with 403.
+The query string can add to the picture:
+  - orientation=black  shows the board from Black's side;
+  - arrows=g1f3,e2e4:red  arrows in UCI notation, green unless a color follows the colon;
+  - markers=e4,d5:blue  marked squares, yellow unless a color follows the colon.
+
+Colors are green, red, blue and yellow.
+*/
+func BoardSVGHandler(rooms RoomSource, moves LastMoveSource, links LinkSigner) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, BoardPathPrefix)
+		roomID, ok := strings.CutSuffix(name, ".svg")
+		if !ok || roomID == "" || strings.Contains(roomID, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		exp, err := strconv.ParseInt(r.URL.Query().Get("exp"), 10, 64)
+		if err != nil || links.VerifyLink(roomID, time.Unix(exp, 0), r.URL.Query().Get("sig")) != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		room, err := rooms.GetRoomByID(r.Context(), roomID)
+		if err != nil || room == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		board := game.BoardImage{FEN: room.BoardState}
+		if r.URL.Query().Get("orientation") == "black" {
+			board.Orientation = chess.Black
+		}
+		if mv, err := moves.GetLastMove(r.Context(), roomID); err != nil {
+			utils.Logger.Warn("GetLastMove: "+err.Error(), zap.Error(err))
+		} else if mv != nil {
+			board.LastMove = mv.UCI
+		}
+		if board.Arrows, err = parseList(r.URL.Query().Get("arrows"), game.GreenMark, game.ParseArrow); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if board.Markers, err = parseList(r.URL.Query().Get("markers"), game.YellowMark, game.ParseMarker); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		svg, err := game.RenderBoardSVG(board)
+		if err != nil {
+			utils.Logger.Error("RenderBoardSVG: "+err.Error(), zap.Error(err))
+			http.Error(w, "cannot render the board", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(svg)
+	})
+}
+
+// parseList parses a comma-separated list of "item[:color]" entries with the given item parser.
+func parseList[T any](list string, defaultColor color.NRGBA, parse func(string, color.NRGBA) (T, error)) ([]T, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var result []T
+	for _, entry := range strings.Split(list, ",") {
+		item, colorName, hasColor := strings.Cut(strings.TrimSpace(entry), ":")
+		c := defaultColor
+		if hasColor {
+			var known bool
+			if c, known = markColors[colorName]; !known {
+				return nil, fmt.Errorf("unknown color %q", colorName)
+			}
+		}
+		parsed, err := parse(item, c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, parsed)
+	}
+	return result, nil
+}